@@ -7,21 +7,49 @@ import (
 	"strconv"
 	"syscall"
 	"time"
+	_ "time/tzdata" // embed the time zone database for users' time zones
 
-	"github.com/aliskhannn/delayed-notifier/pkg/email"
 	"github.com/go-playground/validator/v10"
 	"github.com/wb-go/wbf/dbpg"
 	"github.com/wb-go/wbf/zlog"
 
+	"github.com/aliskhannn/event-booker/internal/api/handler/apikey"
 	"github.com/aliskhannn/event-booker/internal/api/handler/auth"
 	"github.com/aliskhannn/event-booker/internal/api/handler/event"
+	"github.com/aliskhannn/event-booker/internal/api/handler/job"
+	"github.com/aliskhannn/event-booker/internal/api/handler/jwks"
+	"github.com/aliskhannn/event-booker/internal/api/handler/login"
+	"github.com/aliskhannn/event-booker/internal/api/handler/notification"
+	"github.com/aliskhannn/event-booker/internal/api/handler/outbox"
+	"github.com/aliskhannn/event-booker/internal/api/handler/retention"
+	"github.com/aliskhannn/event-booker/internal/api/handler/user"
 	"github.com/aliskhannn/event-booker/internal/api/router"
 	"github.com/aliskhannn/event-booker/internal/api/server"
 	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/jwtkeys"
+	notificationtmpl "github.com/aliskhannn/event-booker/internal/notification"
+	"github.com/aliskhannn/event-booker/internal/notification/email"
+	"github.com/aliskhannn/event-booker/internal/notification/telegram"
+	"github.com/aliskhannn/event-booker/internal/notification/webhook"
+	"github.com/aliskhannn/event-booker/internal/oidc"
+	"github.com/aliskhannn/event-booker/internal/ratelimit"
+	apikeyrepo "github.com/aliskhannn/event-booker/internal/repository/apikey"
 	eventrepo "github.com/aliskhannn/event-booker/internal/repository/event"
+	jobrepo "github.com/aliskhannn/event-booker/internal/repository/job"
+	lockrepo "github.com/aliskhannn/event-booker/internal/repository/lock"
+	loginrepo "github.com/aliskhannn/event-booker/internal/repository/login"
+	mfarepo "github.com/aliskhannn/event-booker/internal/repository/mfa"
+	outboxrepo "github.com/aliskhannn/event-booker/internal/repository/outbox"
+	retentionrepo "github.com/aliskhannn/event-booker/internal/repository/retention"
+	sessionrepo "github.com/aliskhannn/event-booker/internal/repository/session"
 	userrepo "github.com/aliskhannn/event-booker/internal/repository/user"
 	"github.com/aliskhannn/event-booker/internal/scheduler"
+	apikeyservice "github.com/aliskhannn/event-booker/internal/service/apikey"
 	eventservice "github.com/aliskhannn/event-booker/internal/service/event"
+	loginservice "github.com/aliskhannn/event-booker/internal/service/login"
+	outboxservice "github.com/aliskhannn/event-booker/internal/service/outbox"
+	retentionservice "github.com/aliskhannn/event-booker/internal/service/retention"
 	userservice "github.com/aliskhannn/event-booker/internal/service/user"
 )
 
@@ -48,11 +76,16 @@ func main() {
 		slaveDNSs = append(slaveDNSs, s.DSN())
 	}
 
-	db, err := dbpg.New(cfg.Database.Master.DSN(), slaveDNSs, opts)
+	pg, err := dbpg.New(cfg.Database.Master.DSN(), slaveDNSs, opts)
 	if err != nil {
 		zlog.Logger.Fatal().Err(err).Msg("failed to connect to database")
 	}
 
+	// Route reads to replicas that keep up with the master, and to the master
+	// for requests that must see their own writes.
+	db := database.New(pg, cfg.Database.MaxReplicaLag)
+	go db.MonitorReplicas(ctx, cfg.Database.ReplicaCheckInterval)
+
 	// Initialize email client for notifications.
 	smtpPort, err := strconv.Atoi(cfg.Email.SMTPPort)
 	if err != nil {
@@ -67,29 +100,156 @@ func main() {
 		cfg.Email.From,
 	)
 
+	// Parse embedded notification templates.
+	renderer, err := notificationtmpl.NewRenderer()
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to parse notification templates")
+	}
+	notificationHandler := notification.NewHandler(renderer)
+
+	// Register notification channels: email always, webhook and Telegram as configured.
+	notificationRouter := notificationtmpl.NewRouter()
+	notificationRouter.Register(notificationtmpl.ChannelEmail, emailClient)
+	notificationRouter.Register(
+		notificationtmpl.ChannelWebhook,
+		webhook.NewClient(cfg.Notifications.Webhook.Timeout, cfg.Notifications.Webhook.SigningSecret),
+	)
+	if cfg.Notifications.Telegram.BotToken != "" {
+		notificationRouter.Register(
+			notificationtmpl.ChannelTelegram,
+			telegram.NewClient(
+				cfg.Notifications.Telegram.BaseURL,
+				cfg.Notifications.Telegram.BotToken,
+				cfg.Notifications.Telegram.Timeout,
+			),
+		)
+	}
+
 	// Initialize user repository, service, and handler for auth endpoints.
+	// The login guard counts attempts in Postgres, shared by all instances, or in memory.
+	// Failed logins are always audited in Postgres.
+	// Guest booking requests are limited with counters in the same store.
+	loginRepo := loginrepo.NewRepository(db)
+	var (
+		loginService *loginservice.Service
+		guestLimiter *ratelimit.Limiter
+	)
+	switch cfg.LoginGuard.Store {
+	case config.LoginGuardStorePostgres, "":
+		loginService = loginservice.NewService(loginRepo, loginRepo, cfg.LoginGuard)
+		guestLimiter = ratelimit.New(loginRepo, cfg.Auth.GuestLimits.Window)
+	case config.LoginGuardStoreMemory:
+		loginService = loginservice.NewService(loginrepo.NewMemoryCounters(), loginRepo, cfg.LoginGuard)
+		guestLimiter = ratelimit.New(loginrepo.NewMemoryCounters(), cfg.Auth.GuestLimits.Window)
+	default:
+		zlog.Logger.Fatal().Str("store", cfg.LoginGuard.Store).Msg("unknown login guard store")
+	}
+	loginHandler := login.NewHandler(loginService)
+
+	// Access tokens are signed with the configured signing key and verified
+	// with any configured key, selected by kid; the public keys are published as a JWKS.
+	jwtKeys, err := jwtkeys.New(cfg.JWT)
+	if err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to load jwt keys")
+	}
+	jwksHandler := jwks.NewHandler(jwtKeys)
+
+	// Sessions hold refresh tokens, revoked access tokens and emailed one-time tokens.
+	// Account emails such as password resets are sent directly by email, not through the outbox.
 	userRepo := userrepo.NewRepository(db)
-	userService := userservice.NewService(userRepo, cfg)
+	sessionRepo := sessionrepo.NewRepository(db)
+	mfaRepo := mfarepo.NewRepository(db)
+	identityProvider := oidc.NewProvider(cfg.OIDC)
+	userService := userservice.NewService(userRepo, sessionRepo, mfaRepo, loginService, jwtKeys, identityProvider, renderer, emailClient, cfg)
 	authHandler := auth.NewHandler(userService, val)
+	userHandler := user.NewHandler(userService, val)
+
+	// Initialize API key repository, service, and handler for server-to-server integrations.
+	apiKeyService := apikeyservice.NewService(apikeyrepo.NewRepository(db))
+	apiKeyHandler := apikey.NewHandler(apiKeyService, val)
 
 	// Initialize event repository, service, and handler for event endpoints.
+	// New bookings are fed to the expiry queue, which releases them as soon as they expire.
+	expiryQueue := scheduler.NewExpiryQueue()
 	eventRepo := eventrepo.NewRepository(db)
-	eventService := eventservice.NewService(eventRepo)
-	eventHandler := event.NewHandler(eventService, val)
+	eventService := eventservice.NewService(eventRepo, expiryQueue, userService, guestLimiter, cfg.Auth.GuestLimits)
+	eventHandler := event.NewHandler(eventService, val, cfg.Auth.GuestCheckout, cfg.Events.MaxSeats)
 
-	// Initialize event repository, service, and handler for event endpoints.
-	job := scheduler.NewCancelExpiredBookingsJob(userService, eventService, emailClient)
+	// Rebuild the expiry queue from pending bookings and start it.
+	if err := expiryQueue.Start(ctx, eventService); err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("failed to start expiry queue")
+	}
 
-	// Create a new JobManager and register the job.
-	jm := scheduler.NewJobManager(ctx)
-	jm.RegisterJob(job)
+	// Initialize outbox repository, service, and handler for notification delivery.
+	outboxRepo := outboxrepo.NewRepository(db)
+	outboxService := outboxservice.NewService(outboxRepo, renderer, notificationRouter, userService, cfg.Outbox)
+	outboxHandler := outbox.NewHandler(outboxService)
 
-	// Start the job scheduler in a separate goroutine.
+	// Initialize retention repository, service, and handler for archiving old data.
+	retentionRepo := retentionrepo.NewRepository(db)
+	retentionService := retentionservice.NewService(retentionRepo, cfg.Retention)
+	retentionHandler := retention.NewHandler(retentionService)
+
+	// Initialize background jobs: cancel expired bookings, warn holders and remind attendees,
+	// deliver queued notifications, reconcile seat counts and archive old data.
+	cancelJob := scheduler.NewCancelExpiredBookingsJob(eventService)
+	holdWarningJob := scheduler.NewHoldExpiryWarningJob(
+		eventService, cfg.HoldWarnings.Lead, cfg.HoldWarnings.Fraction, cfg.App.BaseURL,
+	)
+	reminderJob := scheduler.NewEventReminderJob(eventService, cfg.Reminders.Offsets)
+	dispatchJob := scheduler.NewDispatchOutboxJob(outboxService)
+	reconcileJob := scheduler.NewSeatReconciliationJob(eventService, cfg.Reconcile.AutoCorrect)
+	retentionJob := scheduler.NewRetentionJob(retentionService)
+
+	// Create a new JobManager and register the jobs.
+	// Postgres advisory locks make sure each run happens on exactly one instance,
+	// and every run is recorded in the job_runs table.
+	jm := scheduler.NewJobManager(lockrepo.NewRepository(db), jobrepo.NewRepository(db))
+	// Schedules, timeouts and retries come from the scheduler config section.
+	jobs := []struct {
+		job scheduler.Job
+		cfg config.Job
+	}{
+		{cancelJob, cfg.Scheduler.CancelExpiredBookings},
+		{holdWarningJob, cfg.Scheduler.HoldExpiryWarning},
+		{reminderJob, cfg.Scheduler.EventReminder},
+		{dispatchJob, cfg.Scheduler.DispatchOutbox},
+		{reconcileJob, cfg.Scheduler.SeatReconciliation},
+		{retentionJob, cfg.Scheduler.Retention},
+	}
+	for _, j := range jobs {
+		if err := jm.RegisterJob(j.job, j.cfg); err != nil {
+			zlog.Logger.Fatal().Err(err).Msg("failed to register job")
+		}
+	}
+
+	// Start the job scheduler.
 	// The scheduler runs in the background and executes jobs according to their cron schedules.
-	go jm.StartScheduler()
+	jm.StartScheduler()
+	jobHandler := job.NewHandler(jm)
 
 	// Initialize API router and HTTP server.
-	r := router.New(authHandler, eventHandler, cfg)
+	r := router.New(router.Handlers{
+		Auth:         authHandler,
+		User:         userHandler,
+		Event:        eventHandler,
+		Notification: notificationHandler,
+		Outbox:       outboxHandler,
+		Job:          jobHandler,
+		Retention:    retentionHandler,
+		Login:        loginHandler,
+		JWKS:         jwksHandler,
+		APIKey:       apiKeyHandler,
+	}, router.Verifiers{
+		Keys:        jwtKeys,
+		Revocations: userService,
+		APIKeys:     apiKeyService,
+		Emails:      userService,
+	}, cfg)
+	// Client IPs key the login protection, so X-Forwarded-For is only believed from known proxies.
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		zlog.Logger.Fatal().Err(err).Msg("invalid trusted proxies")
+	}
 	s := server.New(cfg.Server.HTTPPort, r)
 
 	// Start HTTP server in a separate goroutine.
@@ -115,6 +275,28 @@ func main() {
 		zlog.Logger.Info().Msg("timeout exceeded, forcing shutdown")
 	}
 
+	// Stop the scheduler and the expiry queue before closing the databases,
+	// so no job or cancellation is left mid-transaction.
+	zlog.Logger.Print("stopping scheduler and waiting for running jobs...\n")
+	jobsCtx, cancelJobs := context.WithTimeout(context.Background(), cfg.Scheduler.ShutdownTimeout)
+	defer cancelJobs()
+
+	if err := jm.Stop(jobsCtx); err != nil {
+		zlog.Logger.Error().Err(err).Msg("running jobs cancelled")
+	}
+	if err := expiryQueue.Stop(jobsCtx); err != nil {
+		zlog.Logger.Error().Err(err).Msg("running booking cancellation cancelled")
+	}
+
+	// Account emails are sent outside the requests that were answered already.
+	zlog.Logger.Print("waiting for account emails being sent...\n")
+	emailsCtx, cancelEmails := context.WithTimeout(context.Background(), cfg.Scheduler.ShutdownTimeout)
+	defer cancelEmails()
+
+	if err := userService.Wait(emailsCtx); err != nil {
+		zlog.Logger.Error().Err(err).Msg("account emails not sent")
+	}
+
 	zlog.Logger.Print("closing master and slave databases...\n")
 
 	// Close master database connection.
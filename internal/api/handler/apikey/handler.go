@@ -0,0 +1,188 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
+	apikeyservice "github.com/aliskhannn/event-booker/internal/service/apikey"
+)
+
+// service defines the API key service interface used by the API key handler.
+type service interface {
+	// CreateAPIKey creates an API key and returns it with the key itself, which is shown only once.
+	CreateAPIKey(
+		ctx context.Context,
+		userID uuid.UUID,
+		name string,
+		scopes []string,
+		expiresAt *time.Time,
+	) (*model.NewAPIKey, error)
+
+	// GetAPIKeys returns the API keys of a user, without the keys themselves.
+	GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error)
+
+	// RevokeAPIKey revokes an API key of a user.
+	RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error
+}
+
+// Handler provides HTTP handlers for managing API keys.
+type Handler struct {
+	service   service
+	validator *validator.Validate
+}
+
+// NewHandler creates a new API key handler with the provided service and validator.
+func NewHandler(s service, v *validator.Validate) *Handler {
+	return &Handler{
+		service:   s,
+		validator: v,
+	}
+}
+
+// CreateRequest represents the JSON request body for creating an API key.
+type CreateRequest struct {
+	Name      string   `json:"name" validate:"required,max=100"`
+	Scopes    []string `json:"scopes" validate:"required,min=1,dive,oneof=events:read bookings:write attendees:read"`
+	ExpiresAt string   `json:"expires_at"` // RFC 3339; the key does not expire if empty
+}
+
+// CreateAPIKey handles requests to create an API key for the current user.
+// The response contains the key, which cannot be retrieved again.
+func (h *Handler) CreateAPIKey(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req CreateRequest
+
+	// Try to parse JSON from the request body into CreateRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Parse the optional expiry, which must be in the future.
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+		if err != nil || !t.After(time.Now()) {
+			response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid expires_at"))
+			return
+		}
+		expiresAt = &t
+	}
+
+	key, err := h.service.CreateAPIKey(c.Request.Context(), userID, req.Name, req.Scopes, expiresAt)
+	if err != nil {
+		// Missing or unknown scopes: return 400 Bad Request.
+		if errors.Is(err, apikeyservice.ErrNoScopes) || errors.Is(err, apikeyservice.ErrInvalidScope) {
+			zlog.Logger.Error().Err(err).Msg("invalid api key scopes")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to create api key")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the key; this is the only time it is shown.
+	response.Created(c, key)
+}
+
+// GetAPIKeys handles requests to list the API keys of the current user,
+// including revoked and expired ones.
+func (h *Handler) GetAPIKeys(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	keys, err := h.service.GetAPIKeys(c.Request.Context(), userID)
+	if err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get api keys")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return keys.
+	response.OK(c, map[string][]*model.APIKey{
+		"api_keys": keys,
+	})
+}
+
+// RevokeAPIKey handles requests to revoke an API key of the current user.
+func (h *Handler) RevokeAPIKey(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	keyID, err := uuid.Parse(c.Param("keyID"))
+	if err != nil || keyID == uuid.Nil {
+		zlog.Logger.Error().Err(err).Msg("missing or invalid key id")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid keyID"))
+		return
+	}
+
+	if err := h.service.RevokeAPIKey(c.Request.Context(), userID, keyID); err != nil {
+		// Unknown or already revoked: return 404 Not Found.
+		if errors.Is(err, apikeyservice.ErrAPIKeyNotFound) {
+			zlog.Logger.Error().Err(err).Msg("api key not found")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to revoke api key")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "api key revoked",
+	})
+}
+
+// getUserID extracts the userID from the request context.
+// Returns an error if the userID is missing or invalid.
+func getUserID(c *gin.Context) (uuid.UUID, error) {
+	val, exists := c.Get("userID")
+	if !exists {
+		return uuid.Nil, fmt.Errorf("userID not found in context")
+	}
+	userID, ok := val.(uuid.UUID)
+	if !ok || userID == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("invalid userID in context")
+	}
+	return userID, nil
+}
@@ -4,25 +4,65 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/wb-go/wbf/ginext"
 	"github.com/wb-go/wbf/zlog"
 
 	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
 	userrepo "github.com/aliskhannn/event-booker/internal/repository/user"
+	loginservice "github.com/aliskhannn/event-booker/internal/service/login"
 	userservice "github.com/aliskhannn/event-booker/internal/service/user"
 )
 
 // service defines the user service interface used by the auth handler.
 type service interface {
-	// Register creates a new user with the given email, name, and password.
-	Register(ctx context.Context, email, name, password string) (uuid.UUID, error)
+	// Register creates a new user with the given email, name, password, locale and time zone.
+	Register(ctx context.Context, email, name, password, locale, timezone string) (uuid.UUID, error)
 
-	// Login authenticates a user and returns a signed JWT token.
-	Login(ctx context.Context, email, password string) (string, error)
+	// Login authenticates a user coming from the client ip and returns an access and a refresh
+	// token, or a challenge if the user has two-factor authentication.
+	Login(ctx context.Context, email, password, ip string) (*model.LoginResult, error)
+
+	// CompleteMFALogin completes a challenged login with a TOTP or recovery code.
+	CompleteMFALogin(ctx context.Context, challengeToken, code, ip string) (*model.Tokens, error)
+
+	// StartOIDCLogin starts a login with the OpenID Connect provider and returns its URL.
+	StartOIDCLogin(ctx context.Context) (string, error)
+
+	// CompleteOIDCLogin completes a login with the OpenID Connect provider coming from the client ip.
+	CompleteOIDCLogin(ctx context.Context, state, code, ip string) (*model.LoginResult, error)
+
+	// RequestMagicLink emails a one-time login link if the email is registered.
+	RequestMagicLink(ctx context.Context, email string) error
+
+	// LoginWithMagicLink logs in with the token from an emailed link, coming from the client ip.
+	LoginWithMagicLink(ctx context.Context, token, ip string) (*model.LoginResult, error)
+
+	// Refresh exchanges a refresh token for a new pair of tokens.
+	Refresh(ctx context.Context, refreshToken string) (*model.Tokens, error)
+
+	// Logout revokes the session of the access token jti, or every session of the user.
+	Logout(ctx context.Context, userID, jti uuid.UUID, all bool) error
+
+	// ForgotPassword emails a password reset link if the email is registered.
+	ForgotPassword(ctx context.Context, email string) error
+
+	// ResetPassword sets a new password using a token from a password reset email.
+	ResetPassword(ctx context.Context, token, password string) error
+
+	// VerifyEmail marks the user's email address as verified using the token from a verification link,
+	// or confirms a pending email change using the token from an email change link.
+	VerifyEmail(ctx context.Context, token string) error
+
+	// ResendVerificationEmail sends a new verification link to the user.
+	ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error
 }
 
 // Handler provides HTTP handlers for authentication endpoints.
@@ -44,6 +84,8 @@ type RegisterRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Password string `json:"password" validate:"required"`
 	Name     string `json:"name"`
+	Locale   string `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone string `json:"timezone" validate:"omitempty,timezone"`
 }
 
 // LoginRequest represents the JSON request body for user login.
@@ -52,6 +94,49 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// MFALoginRequest represents the JSON request body for completing a login with a second factor.
+type MFALoginRequest struct {
+	ChallengeToken string `json:"challenge_token" validate:"required"`
+	Code           string `json:"code" validate:"required"`
+}
+
+// OIDCCallbackRequest represents the JSON request body for completing an OpenID Connect login.
+type OIDCCallbackRequest struct {
+	State string `json:"state" validate:"required"`
+	Code  string `json:"code" validate:"required"`
+}
+
+// MagicLinkRequest represents the JSON request body for requesting a login link.
+type MagicLinkRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// MagicLinkLoginRequest represents the JSON request body for logging in with a login link.
+type MagicLinkLoginRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// RefreshRequest represents the JSON request body for refreshing tokens.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// ForgotPasswordRequest represents the JSON request body for requesting a password reset.
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// ResetPasswordRequest represents the JSON request body for resetting a password.
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+// VerifyEmailRequest represents the JSON request body for verifying an email address.
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
 // Register handles user registration.
 // It validates the request body, calls the service layer to create a user,
 // and responds with the created user ID.
@@ -75,7 +160,7 @@ func (h *Handler) Register(c *ginext.Context) {
 	}
 
 	// Register a new user.
-	id, err := h.service.Register(c.Request.Context(), req.Email, req.Name, req.Password)
+	id, err := h.service.Register(c.Request.Context(), req.Email, req.Name, req.Password, req.Locale, req.Timezone)
 	if err != nil {
 		// If user already exists, return 409 Conflict.
 		if errors.Is(err, userservice.ErrUserAlreadyExists) {
@@ -98,9 +183,11 @@ func (h *Handler) Register(c *ginext.Context) {
 
 // Login handles user authentication.
 // It validates the request body, calls the service layer to authenticate the user,
-// and responds with a JWT token on success.
+// and responds with an access and a refresh token on success, or with a
+// challenge to complete at /login/2fa for users with two-factor authentication.
 // Returns 400 for invalid input, 401 for invalid credentials,
-// 404 if the user does not exist, and 500 for unexpected errors.
+// 404 if the user does not exist, 429 with Retry-After after too many
+// attempts, and 500 for unexpected errors.
 func (h *Handler) Login(c *ginext.Context) {
 	var req LoginRequest
 
@@ -118,9 +205,18 @@ func (h *Handler) Login(c *ginext.Context) {
 		return
 	}
 
-	// Authenticate the user and generate a JWT token.
-	token, err := h.service.Login(c.Request.Context(), req.Email, req.Password)
+	// Authenticate the user and generate the tokens or the challenge.
+	result, err := h.service.Login(c.Request.Context(), req.Email, req.Password, c.ClientIP())
 	if err != nil {
+		// Too many attempts: return 429 Too Many Requests with the time to wait.
+		var throttled *loginservice.ThrottledError
+		if errors.As(err, &throttled) {
+			zlog.Logger.Error().Err(err).Bool("locked", throttled.Locked).Msg("login throttled")
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			response.Fail(c, http.StatusTooManyRequests, err)
+			return
+		}
+
 		// Invalid credentials: return 401 Unauthorized.
 		if errors.Is(err, userservice.ErrInvalidCredentials) {
 			zlog.Logger.Error().Err(err).Msg("invalid credentials")
@@ -141,8 +237,469 @@ func (h *Handler) Login(c *ginext.Context) {
 		return
 	}
 
-	// On success, return 200 OK with the JWT token.
+	// On success, return 200 OK with the tokens or the challenge.
+	response.OK(c, result)
+}
+
+// CompleteMFALogin handles the second step of a login with two-factor authentication.
+// It checks the TOTP or recovery code for the challenge returned by Login and
+// responds with an access and a refresh token on success.
+// Returns 400 for invalid input, 401 for an invalid or expired challenge or a
+// wrong code, 429 with Retry-After after too many attempts, and 500 for unexpected errors.
+func (h *Handler) CompleteMFALogin(c *ginext.Context) {
+	var req MFALoginRequest
+
+	// Try to parse JSON from the request body into MFALoginRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Check the second factor and generate the tokens.
+	tokens, err := h.service.CompleteMFALogin(c.Request.Context(), req.ChallengeToken, req.Code, c.ClientIP())
+	if err != nil {
+		// Too many attempts: return 429 Too Many Requests with the time to wait.
+		var throttled *loginservice.ThrottledError
+		if errors.As(err, &throttled) {
+			zlog.Logger.Error().Err(err).Bool("locked", throttled.Locked).Msg("login throttled")
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			response.Fail(c, http.StatusTooManyRequests, err)
+			return
+		}
+
+		// Invalid challenge or code: return 401 Unauthorized.
+		if errors.Is(err, userservice.ErrInvalidChallenge) || errors.Is(err, userservice.ErrInvalidMFACode) {
+			zlog.Logger.Error().Err(err).Msg("invalid second factor")
+			response.Fail(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to complete login")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// On success, return 200 OK with the tokens.
+	response.OK(c, tokens)
+}
+
+// StartOIDCLogin handles the start of a login with the OpenID Connect provider.
+// It responds with the provider URL the web UI must send the user to.
+// Returns 404 if OpenID Connect login is not enabled and 500 for unexpected
+// errors, including an unreachable provider.
+func (h *Handler) StartOIDCLogin(c *ginext.Context) {
+	authURL, err := h.service.StartOIDCLogin(c.Request.Context())
+	if err != nil {
+		// Not enabled: return 404 Not Found.
+		if errors.Is(err, userservice.ErrOIDCDisabled) {
+			zlog.Logger.Error().Err(err).Msg("oidc login disabled")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to start oidc login")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the provider URL.
+	response.OK(c, map[string]string{
+		"authorization_url": authURL,
+	})
+}
+
+// CompleteOIDCLogin handles the return from the OpenID Connect provider.
+// The web UI posts the state and code the provider added to its redirect URL;
+// the response is the same as for a password login.
+// Returns 400 for invalid input or an invalid or expired state, 401 if the
+// provider rejects the login, 403 if it has not verified the email of a new
+// account, 404 if OpenID Connect login is not enabled and 500 for unexpected errors.
+func (h *Handler) CompleteOIDCLogin(c *ginext.Context) {
+	var req OIDCCallbackRequest
+
+	// Try to parse JSON from the request body into OIDCCallbackRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Authenticate with the provider and generate the tokens or the challenge.
+	result, err := h.service.CompleteOIDCLogin(c.Request.Context(), req.State, req.Code, c.ClientIP())
+	if err != nil {
+		// Invalid, used or expired state: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidOIDCState) {
+			zlog.Logger.Error().Err(err).Msg("invalid oidc state")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Rejected by the provider: return 401 Unauthorized.
+		if errors.Is(err, userservice.ErrOIDCFailed) {
+			zlog.Logger.Error().Err(err).Msg("oidc login failed")
+			response.Fail(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		// Unverified email: return 403 Forbidden.
+		if errors.Is(err, userservice.ErrOIDCEmailNotVerified) {
+			zlog.Logger.Error().Err(err).Msg("oidc email not verified")
+			response.Fail(c, http.StatusForbidden, err)
+			return
+		}
+
+		// Not enabled: return 404 Not Found.
+		if errors.Is(err, userservice.ErrOIDCDisabled) {
+			zlog.Logger.Error().Err(err).Msg("oidc login disabled")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to complete oidc login")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// On success, return 200 OK with the tokens or the challenge.
+	response.OK(c, result)
+}
+
+// RequestMagicLink handles requests for a passwordless login link.
+// It emails a one-time login link to the user and responds the same way
+// whether or not the email is registered.
+func (h *Handler) RequestMagicLink(c *ginext.Context) {
+	var req MagicLinkRequest
+
+	// Try to parse JSON from the request body into MagicLinkRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Send the login link.
+	if err := h.service.RequestMagicLink(c.Request.Context(), req.Email); err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to request magic link")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the same message whether or not the email is registered.
+	response.Accepted(c, map[string]string{
+		"message": "if the email is registered, a login link has been sent to it",
+	})
+}
+
+// LoginWithMagicLink handles logins with the token from an emailed login or
+// booking link. It returns the tokens, or a challenge for users with
+// two-factor authentication.
+func (h *Handler) LoginWithMagicLink(c *ginext.Context) {
+	var req MagicLinkLoginRequest
+
+	// Try to parse JSON from the request body into MagicLinkLoginRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Use up the link and generate the tokens or the challenge.
+	result, err := h.service.LoginWithMagicLink(c.Request.Context(), req.Token, c.ClientIP())
+	if err != nil {
+		// Invalid, used or expired link: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidMagicLink) {
+			zlog.Logger.Error().Err(err).Msg("invalid magic link")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to login with magic link")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// On success, return 200 OK with the tokens or the challenge.
+	response.OK(c, result)
+}
+
+// Refresh handles access token renewal.
+// It exchanges a valid refresh token for a new access and refresh token;
+// the presented refresh token cannot be used again.
+// Returns 400 for invalid input, 401 for an invalid, expired or reused
+// refresh token, and 500 for unexpected errors.
+func (h *Handler) Refresh(c *ginext.Context) {
+	var req RefreshRequest
+
+	// Try to parse JSON from the request body into RefreshRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Rotate the refresh token.
+	tokens, err := h.service.Refresh(c.Request.Context(), req.RefreshToken)
+	if err != nil {
+		// Invalid refresh token: return 401 Unauthorized.
+		if errors.Is(err, userservice.ErrInvalidRefreshToken) {
+			zlog.Logger.Error().Err(err).Msg("invalid refresh token")
+			response.Fail(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to refresh tokens")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the new tokens.
+	response.OK(c, tokens)
+}
+
+// Logout handles session termination.
+// It revokes the current access token and its refresh tokens,
+// or every session of the user when the "all" query parameter is true.
+func (h *Handler) Logout(c *ginext.Context) {
+	userID, err := getContextUUID(c, "userID")
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	jti, err := getContextUUID(c, "jti")
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	all := c.Query("all") == "true"
+
+	// Revoke the session(s).
+	if err := h.service.Logout(c.Request.Context(), userID, jti, all); err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to logout")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "logged out",
+	})
+}
+
+// ForgotPassword handles password reset requests.
+// It emails a single-use reset link if the email is registered and always
+// responds with 202 Accepted, so the response does not reveal whether it is.
+// Returns 400 for invalid input and 500 for unexpected errors.
+func (h *Handler) ForgotPassword(c *ginext.Context) {
+	var req ForgotPasswordRequest
+
+	// Try to parse JSON from the request body into ForgotPasswordRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Send the reset link.
+	if err := h.service.ForgotPassword(c.Request.Context(), req.Email); err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to request password reset")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the same message whether or not the email is registered.
+	response.Accepted(c, map[string]string{
+		"message": "if the email is registered, a password reset link has been sent to it",
+	})
+}
+
+// ResetPassword handles password changes with a token from a reset email.
+// On success all sessions of the user are revoked.
+// Returns 400 for invalid input or an invalid, used or expired token,
+// and 500 for unexpected errors.
+func (h *Handler) ResetPassword(c *ginext.Context) {
+	var req ResetPasswordRequest
+
+	// Try to parse JSON from the request body into ResetPasswordRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Reset the password.
+	if err := h.service.ResetPassword(c.Request.Context(), req.Token, req.Password); err != nil {
+		// Invalid, used or expired token: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidResetToken) {
+			zlog.Logger.Error().Err(err).Msg("invalid reset token")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to reset password")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return success.
 	response.OK(c, map[string]string{
-		"token": token,
+		"message": "password has been reset",
 	})
 }
+
+// VerifyEmail handles email verification with the token from a verification
+// link, and confirmation of an email change with the token from its link.
+// Returns 400 for invalid input or an invalid or expired link, 409 if the new
+// address was taken in the meantime and 500 for unexpected errors.
+func (h *Handler) VerifyEmail(c *ginext.Context) {
+	var req VerifyEmailRequest
+
+	// Try to parse JSON from the request body into VerifyEmailRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Verify the email address.
+	if err := h.service.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		// Invalid or expired link: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidVerifyToken) {
+			zlog.Logger.Error().Err(err).Msg("invalid verification token")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// New address taken: return 409 Conflict.
+		if errors.Is(err, userservice.ErrUserAlreadyExists) {
+			zlog.Logger.Error().Err(err).Msg("email already taken")
+			response.Fail(c, http.StatusConflict, fmt.Errorf("email is already taken"))
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to verify email")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "email address verified",
+	})
+}
+
+// ResendVerificationEmail handles requests for a new verification link
+// for the authenticated user.
+// Returns 409 if the email address is already verified and 500 for unexpected errors.
+func (h *Handler) ResendVerificationEmail(c *ginext.Context) {
+	userID, err := getContextUUID(c, "userID")
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	// Send a new link.
+	if err := h.service.ResendVerificationEmail(c.Request.Context(), userID); err != nil {
+		// Already verified: return 409 Conflict.
+		if errors.Is(err, userservice.ErrEmailAlreadyVerified) {
+			zlog.Logger.Error().Err(err).Msg("email already verified")
+			response.Fail(c, http.StatusConflict, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to resend verification email")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return accepted: the email is sent in the background.
+	response.Accepted(c, map[string]string{
+		"message": "verification email sent",
+	})
+}
+
+// getContextUUID extracts a UUID set by the auth middleware from the request context.
+// Returns an error if the value is missing or invalid.
+func getContextUUID(c *gin.Context, key string) (uuid.UUID, error) {
+	val, exists := c.Get(key)
+	if !exists {
+		return uuid.Nil, fmt.Errorf("%s not found in context", key)
+	}
+	id, ok := val.(uuid.UUID)
+	if !ok || id == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("invalid %s in context", key)
+	}
+	return id, nil
+}
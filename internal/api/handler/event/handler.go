@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,43 +17,79 @@ import (
 
 	"github.com/aliskhannn/event-booker/internal/api/response"
 	"github.com/aliskhannn/event-booker/internal/model"
+	"github.com/aliskhannn/event-booker/internal/ratelimit"
 	eventrepo "github.com/aliskhannn/event-booker/internal/repository/event"
 	eventservice "github.com/aliskhannn/event-booker/internal/service/event"
+	userservice "github.com/aliskhannn/event-booker/internal/service/user"
 )
 
 // service defines the event-related business logic interface
 // that the handler depends on.
 type service interface {
-	// CreateEvent creates new event.
-	CreateEvent(ctx context.Context, title string, date time.Time, totalSeats, availableSeats int, bookingTTL time.Duration) (uuid.UUID, error)
+	// CreateEvent creates new event organized by the user organizerID.
+	CreateEvent(
+		ctx context.Context,
+		organizerID uuid.UUID,
+		title string,
+		date time.Time,
+		totalSeats, availableSeats int,
+		bookingTTL time.Duration,
+		seatStrategy string,
+	) (uuid.UUID, error)
 
 	// BookEvent reserves seats for a user at an event.
 	BookEvent(ctx context.Context, userID, eventID uuid.UUID) (uuid.UUID, error)
 
+	// BookEventAsGuest reserves a seat for a guest and emails them a link to confirm it, limiting requests
+	// per client and email.
+	BookEventAsGuest(ctx context.Context, email, name, client string, eventID uuid.UUID) error
+
+	// ConfirmGuestBooking confirms a guest's booking with the token of a guest booking link and logs the guest in.
+	ConfirmGuestBooking(ctx context.Context, token, ip string, eventID uuid.UUID) (*model.GuestBooking, error)
+
 	// GetEvents retrieves all events.
 	GetEvents(ctx context.Context) ([]*model.Event, error)
 
 	// GetEventByID returns event info with available seats.
 	GetEventByID(ctx context.Context, eventID uuid.UUID) (*model.Event, error)
 
-	// ConfirmBookingPayment confirms the payment of a booking.
-	ConfirmBookingPayment(ctx context.Context, bookingID uuid.UUID) error
+	// GetAttendees returns the users holding pending or confirmed bookings for an event the actor manages.
+	GetAttendees(ctx context.Context, actor *model.Actor, eventID uuid.UUID) ([]*model.Attendee, error)
+
+	// ConfirmBookingPayment confirms the payment of a booking the actor manages.
+	ConfirmBookingPayment(ctx context.Context, actor *model.Actor, bookingID uuid.UUID) error
 
-	// CancelBooking cancels a booking (by user or background job).
-	CancelBooking(ctx context.Context, bookingID uuid.UUID) error
+	// CancelBooking cancels a booking the actor manages.
+	CancelBooking(ctx context.Context, actor *model.Actor, bookingID uuid.UUID) error
+
+	// GetSeatDrifts returns the events whose available seats drifted from their bookings.
+	GetSeatDrifts(ctx context.Context) ([]*model.SeatDrift, error)
 }
 
+// defaultMaxSeats is used when the configured most seats of an event is not positive.
+const defaultMaxSeats = 10000
+
 // Handler provides HTTP endpoints for event management and bookings.
 type Handler struct {
-	service   service
-	validator *validator.Validate
+	service       service
+	validator     *validator.Validate
+	guestCheckout bool
+	maxSeats      int
 }
 
-// NewHandler creates a new event handler with the provided service and validator.
-func NewHandler(s service, v *validator.Validate) *Handler {
+// NewHandler creates a new event handler with the provided service and
+// validator. guestCheckout allows bookings for guests who are not logged in,
+// and maxSeats is the most seats a new event may have.
+func NewHandler(s service, v *validator.Validate, guestCheckout bool, maxSeats int) *Handler {
+	if maxSeats <= 0 {
+		maxSeats = defaultMaxSeats
+	}
+
 	return &Handler{
-		service:   s,
-		validator: v,
+		service:       s,
+		validator:     v,
+		guestCheckout: guestCheckout,
+		maxSeats:      maxSeats,
 	}
 }
 
@@ -59,15 +97,23 @@ func NewHandler(s service, v *validator.Validate) *Handler {
 type CreateRequest struct {
 	Title          string `json:"title" validate:"required"`
 	Date           string `json:"date" validate:"required"`
-	TotalSeats     int    `json:"total_seats" validate:"required"`
-	AvailableSeats int    `json:"available_seats" validate:"required"`
+	TotalSeats     int    `json:"total_seats" validate:"gt=0"`
+	AvailableSeats int    `json:"available_seats" validate:"gte=0,eqfield=TotalSeats"`
 	BookingTTL     string `json:"booking_ttl"`
+	SeatStrategy   string `json:"seat_strategy" validate:"omitempty,oneof=counter slots"`
 }
 
 // CreateEvent handles event creation requests.
 // It parses and validates the input, converts date and TTL fields,
 // calls the service layer, and returns the created event ID.
 func (h *Handler) CreateEvent(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
 	var req CreateRequest
 
 	// Try to parse JSON from the request body into CreateRequest struct.
@@ -84,6 +130,13 @@ func (h *Handler) CreateEvent(c *ginext.Context) {
 		return
 	}
 
+	// Limit the seats, which slot events create a row for each.
+	if req.TotalSeats > h.maxSeats {
+		zlog.Logger.Error().Int("total_seats", req.TotalSeats).Msg("too many seats")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: total_seats must be at most %d", h.maxSeats))
+		return
+	}
+
 	// Parse event date.
 	eventDate, err := time.Parse(time.RFC3339, req.Date)
 	if err != nil {
@@ -105,8 +158,15 @@ func (h *Handler) CreateEvent(c *ginext.Context) {
 
 	// Create a new event.
 	id, err := h.service.CreateEvent(
-		c.Request.Context(), req.Title, eventDate, req.TotalSeats, req.AvailableSeats, bookingTTL)
+		c.Request.Context(), userID, req.Title, eventDate, req.TotalSeats, req.AvailableSeats, bookingTTL, req.SeatStrategy)
 	if err != nil {
+		// If not all seats are available, return 400 Bad Request.
+		if errors.Is(err, eventservice.ErrInvalidSeats) {
+			zlog.Logger.Error().Err(err).Msg("invalid seats")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
 		zlog.Logger.Error().Err(err).Msg("failed to create event")
 		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 		return
@@ -118,14 +178,108 @@ func (h *Handler) CreateEvent(c *ginext.Context) {
 	})
 }
 
+// GuestBookingRequest represents the JSON request body of a booking by a guest who is not logged in.
+type GuestBookingRequest struct {
+	Email string `json:"email" validate:"required,email"`
+	Name  string `json:"name" validate:"max=50"`
+}
+
 // BookEvent handles event booking requests.
-// It validates user authorization, parses the event ID,
-// and attempts to reserve a seat for the user.
+// It parses the event ID and attempts to reserve a seat for the logged-in
+// user. Without a token, a seat is held for a guest, who is emailed a link to
+// confirm it with, see ConfirmGuestBooking, and gets 202 Accepted.
+// Integrations using an API key book for their customers like guests do, so
+// only if guest checkout is enabled. Guest requests get 429 with Retry-After
+// when too many are sent from one client or for one email, and 409 if too
+// many guest bookings are pending for the event.
 func (h *Handler) BookEvent(c *ginext.Context) {
-	userID, err := getUserID(c)
+	eventID, err := parseUUIDParam(c, "eventID")
 	if err != nil {
-		zlog.Logger.Error().Err(err).Msg("unauthorized")
-		response.Fail(c, http.StatusUnauthorized, err)
+		zlog.Logger.Error().Err(err).Msg("missing or invalid event id")
+		response.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	_, authenticated := c.Get("userID")
+	apiKeyID, viaAPIKey := c.Get("apiKeyID")
+
+	if authenticated && !viaAPIKey {
+		userID, err := getUserID(c)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("unauthorized")
+			response.Fail(c, http.StatusUnauthorized, err)
+			return
+		}
+
+		// Book a seat.
+		id, err := h.service.BookEvent(c.Request.Context(), userID, eventID)
+		if err != nil {
+			h.failBooking(c, err)
+			return
+		}
+
+		// Return success message.
+		response.OK(c, map[string]string{
+			"id":      id.String(),
+			"message": "booking created",
+		})
+		return
+	}
+
+	if !h.guestCheckout {
+		zlog.Logger.Error().Msg("guest checkout is disabled")
+		response.Fail(c, http.StatusForbidden, fmt.Errorf("guest checkout is disabled"))
+		return
+	}
+
+	var req GuestBookingRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	// Integrations are limited per API key, not per the address of their servers.
+	client := "ip:" + c.ClientIP()
+	if viaAPIKey {
+		client = fmt.Sprintf("apikey:%v", apiKeyID)
+	}
+
+	// Hold a seat and email the guest the link to confirm it with.
+	err = h.service.BookEventAsGuest(c.Request.Context(), req.Email, req.Name, client, eventID)
+	if err != nil {
+		h.failBooking(c, err)
+		return
+	}
+
+	// The response is the same for addresses with an account, which are emailed a login link.
+	response.Accepted(c, map[string]string{
+		"message": "follow the link sent to your email to confirm your booking",
+	})
+}
+
+// ConfirmGuestBookingRequest represents the JSON request body of the confirmation of a guest booking.
+type ConfirmGuestBookingRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+// ConfirmGuestBooking handles the guest booking links emailed by BookEvent.
+// It confirms the booking held for the guest, verifying their email, and
+// responds with the booking id and the guest's access and refresh tokens.
+// Returns 400 for an invalid or expired link, 403 if guest checkout is
+// disabled, 409 if the booking was already confirmed or its hold expired,
+// and 500 for unexpected errors.
+func (h *Handler) ConfirmGuestBooking(c *ginext.Context) {
+	if !h.guestCheckout {
+		zlog.Logger.Error().Msg("guest checkout is disabled")
+		response.Fail(c, http.StatusForbidden, fmt.Errorf("guest checkout is disabled"))
 		return
 	}
 
@@ -136,34 +290,80 @@ func (h *Handler) BookEvent(c *ginext.Context) {
 		return
 	}
 
-	// Book a seat.
-	id, err := h.service.BookEvent(c.Request.Context(), userID, eventID)
+	var req ConfirmGuestBookingRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	booking, err := h.service.ConfirmGuestBooking(c.Request.Context(), req.Token, c.ClientIP(), eventID)
 	if err != nil {
-		// If  event not found, return 404 Not Found.
-		if errors.Is(err, eventservice.ErrEventNotFound) {
-			zlog.Logger.Error().Err(err).Msg("event not found")
-			response.Fail(c, http.StatusNotFound, err)
+		// If the link is invalid or expired, return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidGuestBooking) {
+			zlog.Logger.Error().Err(err).Msg("invalid guest booking link")
+			response.Fail(c, http.StatusBadRequest, userservice.ErrInvalidGuestBooking)
 			return
 		}
 
-		// If no seats available, return 409 Conflict.
-		if errors.Is(err, eventservice.ErrNoSeatsAvailable) {
-			zlog.Logger.Error().Err(err).Msg("no seats available")
+		// If the booking is no longer held, return 409 Conflict.
+		if errors.Is(err, eventservice.ErrGuestHoldEnded) {
+			zlog.Logger.Error().Err(err).Msg("guest booking no longer held")
 			response.Fail(c, http.StatusConflict, err)
 			return
 		}
 
 		// Internal Server Error.
-		zlog.Logger.Error().Err(err).Msg("failed to book event")
+		zlog.Logger.Error().Err(err).Msg("failed to confirm guest booking")
 		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 		return
 	}
 
-	// Return success message.
-	response.OK(c, map[string]string{
-		"id":      id.String(),
-		"message": "booking created",
-	})
+	response.OK(c, booking)
+}
+
+// failBooking responds to a failed booking or guest booking request.
+func (h *Handler) failBooking(c *ginext.Context, err error) {
+	// If too many guest booking requests were sent, return 429 Too Many Requests.
+	var limited *ratelimit.LimitedError
+	if errors.As(err, &limited) {
+		zlog.Logger.Error().Err(err).Msg("guest booking requests limited")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(limited.RetryAfter.Seconds()))))
+		response.Fail(c, http.StatusTooManyRequests, err)
+		return
+	}
+
+	// If  event not found, return 404 Not Found.
+	if errors.Is(err, eventservice.ErrEventNotFound) {
+		zlog.Logger.Error().Err(err).Msg("event not found")
+		response.Fail(c, http.StatusNotFound, err)
+		return
+	}
+
+	// If no seats available, return 409 Conflict.
+	if errors.Is(err, eventservice.ErrNoSeatsAvailable) {
+		zlog.Logger.Error().Err(err).Msg("no seats available")
+		response.Fail(c, http.StatusConflict, err)
+		return
+	}
+
+	// If too many guest bookings are pending, return 409 Conflict.
+	if errors.Is(err, eventservice.ErrTooManyGuests) {
+		zlog.Logger.Error().Err(err).Msg("too many pending guest bookings")
+		response.Fail(c, http.StatusConflict, err)
+		return
+	}
+
+	// Internal Server Error.
+	zlog.Logger.Error().Err(err).Msg("failed to book event")
+	response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
 }
 
 // ListEvents handles GET /events to list all events.
@@ -214,6 +414,51 @@ func (h *Handler) GetEvent(c *ginext.Context) {
 	})
 }
 
+// GetAttendees handles requests to list the attendees of an event:
+// the users holding pending or confirmed bookings.
+func (h *Handler) GetAttendees(c *ginext.Context) {
+	eventID, err := parseUUIDParam(c, "eventID")
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("missing or invalid event id")
+		response.Fail(c, http.StatusBadRequest, err)
+		return
+	}
+
+	actor, err := getActor(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	attendees, err := h.service.GetAttendees(c.Request.Context(), actor, eventID)
+	if err != nil {
+		// If  event not found, return 404 Not Found.
+		if errors.Is(err, eventservice.ErrEventNotFound) {
+			zlog.Logger.Error().Err(err).Msg("event not found")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Not the event's organizer: return 403 Forbidden.
+		if errors.Is(err, eventservice.ErrForbidden) {
+			zlog.Logger.Error().Err(err).Msg("forbidden")
+			response.Fail(c, http.StatusForbidden, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get attendees")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return attendees.
+	response.OK(c, map[string][]*model.Attendee{
+		"attendees": attendees,
+	})
+}
+
 // ConfirmBooking handles booking confirmation requests.
 // It validates user authorization, event ID, and booking ID,
 // then calls the service to confirm the booking payment.
@@ -225,11 +470,25 @@ func (h *Handler) ConfirmBooking(c *ginext.Context) {
 		return
 	}
 
+	actor, err := getActor(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
 	// Confirm booking payment.
-	err = h.service.ConfirmBookingPayment(c.Request.Context(), bookingID)
+	err = h.service.ConfirmBookingPayment(c.Request.Context(), actor, bookingID)
 	if err != nil {
+		// Not the booking's holder or the event's organizer: return 403 Forbidden.
+		if errors.Is(err, eventservice.ErrForbidden) {
+			zlog.Logger.Error().Err(err).Msg("forbidden")
+			response.Fail(c, http.StatusForbidden, err)
+			return
+		}
+
 		// If booking not found or already confirmed, return 404 Not Found.
-		if errors.Is(err, eventrepo.ErrBookingNotFoundOrAlreadyConfirmed) {
+		if errors.Is(err, eventservice.ErrBookingNotFound) || errors.Is(err, eventrepo.ErrBookingNotFoundOrAlreadyConfirmed) {
 			zlog.Logger.Error().Err(err).Msg("booking not found or already confirmed")
 			response.Fail(c, http.StatusNotFound, err)
 			return
@@ -258,11 +517,25 @@ func (h *Handler) CancelBooking(c *ginext.Context) {
 		return
 	}
 
+	actor, err := getActor(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
 	// Cancel booking.
-	err = h.service.CancelBooking(c.Request.Context(), bookingID)
+	err = h.service.CancelBooking(c.Request.Context(), actor, bookingID)
 	if err != nil {
+		// Not the booking's holder or the event's organizer: return 403 Forbidden.
+		if errors.Is(err, eventservice.ErrForbidden) {
+			zlog.Logger.Error().Err(err).Msg("forbidden")
+			response.Fail(c, http.StatusForbidden, err)
+			return
+		}
+
 		// If booking not found or already cancelled, return 404 Not Found.
-		if errors.Is(err, eventrepo.ErrBookingNotFoundOrAlreadyCancelled) {
+		if errors.Is(err, eventservice.ErrBookingNotFound) || errors.Is(err, eventrepo.ErrBookingNotFoundOrAlreadyCancelled) {
 			zlog.Logger.Error().Err(err).Msg("booking not found or already cancelled")
 			response.Fail(c, http.StatusNotFound, err)
 			return
@@ -280,6 +553,23 @@ func (h *Handler) CancelBooking(c *ginext.Context) {
 	})
 }
 
+// GetSeatDrifts handles requests to report events whose available seats
+// do not match their total seats minus active bookings.
+func (h *Handler) GetSeatDrifts(c *ginext.Context) {
+	drifts, err := h.service.GetSeatDrifts(c.Request.Context())
+	if err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get seat drifts")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return drifts.
+	response.OK(c, map[string][]*model.SeatDrift{
+		"drifts": drifts,
+	})
+}
+
 // getUserID extracts the userID from the request context.
 // Returns an error if the userID is missing or invalid.
 func getUserID(c *gin.Context) (uuid.UUID, error) {
@@ -294,6 +584,23 @@ func getUserID(c *gin.Context) (uuid.UUID, error) {
 	return userID, nil
 }
 
+// getActor returns the caller of the request: the user and role from the
+// token, or the owner of the API key used.
+func getActor(c *gin.Context) (*model.Actor, error) {
+	userID, err := getUserID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	_, viaAPIKey := c.Get("apiKeyID")
+
+	return &model.Actor{
+		UserID: userID,
+		Role:   c.GetString("role"),
+		APIKey: viaAPIKey,
+	}, nil
+}
+
 // ParseUUIDParam parses a UUID from the URL parameters and logs errors if invalid.
 // Returns the UUID and an error if parsing fails.
 func parseUUIDParam(c *ginext.Context, param string) (uuid.UUID, error) {
@@ -0,0 +1,151 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
+	"github.com/aliskhannn/event-booker/internal/scheduler"
+)
+
+// defaultLimit and maxLimit bound the number of runs returned by GetJobRuns.
+const (
+	defaultLimit = 20
+	maxLimit     = 200
+)
+
+// manager defines the job manager interface used by the job handler.
+type manager interface {
+	// Jobs returns all registered jobs with their pause state and next run time.
+	Jobs(ctx context.Context) ([]*model.Job, error)
+
+	// Runs returns the most recent runs of a job.
+	Runs(ctx context.Context, name string, limit int) ([]*model.JobRun, error)
+
+	// Pause stops scheduled runs of a job until it is resumed.
+	Pause(ctx context.Context, name string) error
+
+	// Resume re-enables scheduled runs of a paused job.
+	Resume(ctx context.Context, name string) error
+
+	// Trigger starts a run of a job immediately in the background.
+	Trigger(name string) error
+}
+
+// Handler provides HTTP handlers for scheduler job administration endpoints.
+type Handler struct {
+	manager manager
+}
+
+// NewHandler creates a new job handler.
+func NewHandler(m manager) *Handler {
+	return &Handler{manager: m}
+}
+
+// GetJobs handles requests to list scheduler jobs.
+func (h *Handler) GetJobs(c *ginext.Context) {
+	jobs, err := h.manager.Jobs(c.Request.Context())
+	if err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get jobs")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return jobs.
+	response.OK(c, map[string][]*model.Job{
+		"jobs": jobs,
+	})
+}
+
+// GetJobRuns handles requests to list the most recent runs of a job.
+func (h *Handler) GetJobRuns(c *ginext.Context) {
+	limit := defaultLimit
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxLimit {
+			response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	runs, err := h.manager.Runs(c.Request.Context(), c.Param("name"), limit)
+	if err != nil {
+		h.fail(c, err, "failed to get job runs")
+		return
+	}
+
+	// Return runs.
+	response.OK(c, map[string][]*model.JobRun{
+		"runs": runs,
+	})
+}
+
+// PauseJob handles requests to pause a job.
+func (h *Handler) PauseJob(c *ginext.Context) {
+	if err := h.manager.Pause(c.Request.Context(), c.Param("name")); err != nil {
+		h.fail(c, err, "failed to pause job")
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "job paused",
+	})
+}
+
+// ResumeJob handles requests to resume a paused job.
+func (h *Handler) ResumeJob(c *ginext.Context) {
+	if err := h.manager.Resume(c.Request.Context(), c.Param("name")); err != nil {
+		h.fail(c, err, "failed to resume job")
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "job resumed",
+	})
+}
+
+// TriggerJob handles requests to run a job immediately.
+// The job runs in the background; its outcome shows up in the job's runs.
+func (h *Handler) TriggerJob(c *ginext.Context) {
+	if err := h.manager.Trigger(c.Param("name")); err != nil {
+		h.fail(c, err, "failed to trigger job")
+		return
+	}
+
+	// Return 202 Accepted.
+	response.Accepted(c, map[string]string{
+		"message": "job triggered",
+	})
+}
+
+// fail writes the error response for job manager errors.
+func (h *Handler) fail(c *ginext.Context, err error, msg string) {
+	// If job not found, return 404 Not Found.
+	if errors.Is(err, scheduler.ErrJobNotFound) {
+		zlog.Logger.Error().Err(err).Msg("job not found")
+		response.Fail(c, http.StatusNotFound, err)
+		return
+	}
+
+	// If job is disabled in the config or the scheduler is shutting down, return 409 Conflict.
+	if errors.Is(err, scheduler.ErrJobDisabled) || errors.Is(err, scheduler.ErrStopped) {
+		zlog.Logger.Error().Err(err).Msg("job cannot be run")
+		response.Fail(c, http.StatusConflict, err)
+		return
+	}
+
+	// Internal Server Error.
+	zlog.Logger.Error().Err(err).Msg(msg)
+	response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+}
@@ -0,0 +1,34 @@
+package jwks
+
+import (
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/jwtkeys"
+)
+
+// keySet defines the key set interface used by the JWKS handler.
+type keySet interface {
+	// JWKS returns the public keys that verify access tokens.
+	JWKS() *jwtkeys.JWKS
+}
+
+// Handler provides the HTTP handler publishing the token verification keys.
+type Handler struct {
+	keys keySet
+}
+
+// NewHandler creates a new JWKS handler.
+func NewHandler(keys keySet) *Handler {
+	return &Handler{keys: keys}
+}
+
+// GetKeys handles requests for the JSON Web Key Set that other services use
+// to verify access tokens. The set is returned as is, not wrapped in "result",
+// as JWKS clients expect, and may be cached for a few minutes.
+func (h *Handler) GetKeys(c *ginext.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	response.JSON(c, http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,63 @@
+package login
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// defaultLimit and maxLimit bound the number of records returned by GetFailures.
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// service defines the login guard service interface used by the login handler.
+type service interface {
+	// GetFailures returns the most recent failed login attempts, optionally only those for email.
+	GetFailures(ctx context.Context, email string, limit int) ([]*model.LoginFailure, error)
+}
+
+// Handler provides HTTP handlers for login audit endpoints.
+type Handler struct {
+	service service
+}
+
+// NewHandler creates a new login handler.
+func NewHandler(s service) *Handler {
+	return &Handler{service: s}
+}
+
+// GetFailures handles requests to list failed login attempts, most recent first.
+// The optional "email" query parameter selects the attempts on one account.
+func (h *Handler) GetFailures(c *ginext.Context) {
+	limit := defaultLimit
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxLimit {
+			response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	failures, err := h.service.GetFailures(c.Request.Context(), c.Query("email"), limit)
+	if err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get login failures")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return failures.
+	response.OK(c, map[string][]*model.LoginFailure{
+		"failures": failures,
+	})
+}
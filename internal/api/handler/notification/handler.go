@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	notificationtmpl "github.com/aliskhannn/event-booker/internal/notification"
+)
+
+// renderer defines the template rendering interface used by the notification handler.
+type renderer interface {
+	// Preview renders the named template for the given locale with sample data.
+	Preview(name, locale string) (*notificationtmpl.Message, error)
+
+	// Templates returns the names of all templates with the locales available for each.
+	Templates() map[string][]string
+}
+
+// Handler provides HTTP handlers for notification administration endpoints.
+type Handler struct {
+	renderer renderer
+}
+
+// NewHandler creates a new notification handler.
+func NewHandler(r renderer) *Handler {
+	return &Handler{renderer: r}
+}
+
+// GetTemplates handles requests to list all notification templates and their locales.
+func (h *Handler) GetTemplates(c *ginext.Context) {
+	response.OK(c, map[string]map[string][]string{
+		"templates": h.renderer.Templates(),
+	})
+}
+
+// PreviewTemplate handles requests to render a notification template with sample data.
+// The locale is taken from the "locale" query parameter and defaults to the template default.
+// With "format=html" the HTML part is returned as a page instead of JSON.
+func (h *Handler) PreviewTemplate(c *ginext.Context) {
+	name := c.Param("name")
+	locale := c.DefaultQuery("locale", notificationtmpl.DefaultLocale)
+
+	msg, err := h.renderer.Preview(name, locale)
+	if err != nil {
+		// If template not found, return 404 Not Found.
+		if errors.Is(err, notificationtmpl.ErrTemplateNotFound) {
+			zlog.Logger.Error().Err(err).Str("template", name).Msg("template not found")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Str("template", name).Msg("failed to render template")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	if c.Query("format") == "html" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+		return
+	}
+
+	// Return rendered message.
+	response.OK(c, map[string]*notificationtmpl.Message{
+		"message": msg,
+	})
+}
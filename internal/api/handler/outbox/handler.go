@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
+	outboxrepo "github.com/aliskhannn/event-booker/internal/repository/outbox"
+)
+
+// defaultLimit and maxLimit bound the number of messages returned by GetMessages.
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// service defines the outbox service interface used by the outbox handler.
+type service interface {
+	// GetMessages returns the most recently updated messages with the given status.
+	GetMessages(ctx context.Context, status string, limit int) ([]*model.OutboxMessage, error)
+
+	// ReplayMessage schedules a dead message for immediate redelivery.
+	ReplayMessage(ctx context.Context, messageID uuid.UUID) error
+}
+
+// Handler provides HTTP handlers for outbox administration endpoints.
+type Handler struct {
+	service service
+}
+
+// NewHandler creates a new outbox handler.
+func NewHandler(s service) *Handler {
+	return &Handler{service: s}
+}
+
+// GetMessages handles requests to list outbox messages.
+// The "status" query parameter selects pending, sent or dead messages
+// and defaults to dead, i.e. failed deliveries.
+func (h *Handler) GetMessages(c *ginext.Context) {
+	status := c.DefaultQuery("status", model.OutboxStatusDead)
+	switch status {
+	case model.OutboxStatusPending, model.OutboxStatusSent, model.OutboxStatusDead:
+	default:
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid status"))
+		return
+	}
+
+	limit := defaultLimit
+	if l := c.Query("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > maxLimit {
+			response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid limit"))
+			return
+		}
+		limit = n
+	}
+
+	messages, err := h.service.GetMessages(c.Request.Context(), status, limit)
+	if err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get outbox messages")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return messages.
+	response.OK(c, map[string][]*model.OutboxMessage{
+		"messages": messages,
+	})
+}
+
+// ReplayMessage handles requests to redeliver a dead outbox message.
+func (h *Handler) ReplayMessage(c *ginext.Context) {
+	messageID, err := uuid.Parse(c.Param("messageID"))
+	if err != nil || messageID == uuid.Nil {
+		zlog.Logger.Error().Err(err).Msg("missing or invalid message id")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid messageID"))
+		return
+	}
+
+	err = h.service.ReplayMessage(c.Request.Context(), messageID)
+	if err != nil {
+		// If message not found or not dead, return 404 Not Found.
+		if errors.Is(err, outboxrepo.ErrMessageNotFoundOrNotDead) {
+			zlog.Logger.Error().Err(err).Msg("message not found or not dead")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to replay message")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "message scheduled for redelivery",
+	})
+}
@@ -0,0 +1,45 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// service defines the retention service interface used by the retention handler.
+type service interface {
+	// GetReport returns the archived volumes.
+	GetReport(ctx context.Context) (*model.RetentionReport, error)
+}
+
+// Handler provides HTTP handlers for data retention endpoints.
+type Handler struct {
+	service service
+}
+
+// NewHandler creates a new retention handler.
+func NewHandler(s service) *Handler {
+	return &Handler{service: s}
+}
+
+// GetReport handles requests to report the archived volumes.
+func (h *Handler) GetReport(c *ginext.Context) {
+	report, err := h.service.GetReport(c.Request.Context())
+	if err != nil {
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get retention report")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return report.
+	response.OK(c, map[string]*model.RetentionReport{
+		"report": report,
+	})
+}
@@ -0,0 +1,590 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
+	userrepo "github.com/aliskhannn/event-booker/internal/repository/user"
+	loginservice "github.com/aliskhannn/event-booker/internal/service/login"
+	userservice "github.com/aliskhannn/event-booker/internal/service/user"
+)
+
+// service defines the user service interface used by the user handler.
+type service interface {
+	// GetProfile returns the account and notification preferences of a user.
+	GetProfile(ctx context.Context, userID uuid.UUID) (*model.Profile, error)
+
+	// UpdateProfile applies a partial update to the profile of a user and returns the updated profile.
+	UpdateProfile(ctx context.Context, userID uuid.UUID, update *model.ProfileUpdate) (*model.Profile, error)
+
+	// ChangePassword sets a new password after checking the current one and revokes all sessions.
+	ChangePassword(ctx context.Context, userID uuid.UUID, current, password, ip string) error
+
+	// ChangeEmail requests a new email address after checking the password; it changes once confirmed.
+	ChangeEmail(ctx context.Context, userID uuid.UUID, password, email, ip string) (*model.User, error)
+
+	// GetNotificationPreferences returns the notification preferences of a user.
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error)
+
+	// UpdateNotificationPreferences replaces the notification preferences of a user.
+	UpdateNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) error
+
+	// EnrollMFA starts two-factor enrollment and returns the new TOTP secret.
+	EnrollMFA(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollment, error)
+
+	// ConfirmMFA verifies a pending enrollment with a code and returns the recovery codes.
+	ConfirmMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error)
+
+	// DisableMFA turns off two-factor authentication after checking a code.
+	DisableMFA(ctx context.Context, userID uuid.UUID, code string) error
+}
+
+// Handler provides HTTP handlers for the current user's account endpoints.
+type Handler struct {
+	service   service
+	validator *validator.Validate
+}
+
+// NewHandler creates a new user handler.
+func NewHandler(s service, v *validator.Validate) *Handler {
+	return &Handler{
+		service:   s,
+		validator: v,
+	}
+}
+
+// ProfileRequest represents the JSON request body for updating the current
+// user's profile. Omitted fields are left unchanged.
+type ProfileRequest struct {
+	Name                    *string                         `json:"name" validate:"omitempty,min=1,max=50"`
+	Locale                  *string                         `json:"locale" validate:"omitempty,bcp47_language_tag"`
+	Timezone                *string                         `json:"timezone" validate:"omitempty,timezone"`
+	NotificationPreferences *NotificationPreferencesRequest `json:"notification_preferences"`
+}
+
+// ChangePasswordRequest represents the JSON request body for changing the password.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+// ChangeEmailRequest represents the JSON request body for changing the email address.
+type ChangeEmailRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required"`
+}
+
+// NotificationPreferencesRequest represents the JSON request body for updating notification preferences.
+type NotificationPreferencesRequest struct {
+	Channels           []string `json:"channels" validate:"required,min=1,dive,oneof=email webhook telegram"`
+	WebhookURL         string   `json:"webhook_url" validate:"omitempty,http_url,startswith=https://"`
+	TelegramChatID     string   `json:"telegram_chat_id"`
+	OptOutNonEssential bool     `json:"opt_out_non_essential"`
+}
+
+// MFACodeRequest represents the JSON request body carrying a TOTP or recovery code.
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+// GetProfile handles requests to fetch the current user's account and notification preferences.
+func (h *Handler) GetProfile(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	profile, err := h.service.GetProfile(c.Request.Context(), userID)
+	if err != nil {
+		// If user not found, return 404 Not Found.
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			zlog.Logger.Error().Err(err).Msg("user not found")
+			response.Fail(c, http.StatusNotFound, fmt.Errorf("user not found"))
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get profile")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the profile.
+	response.OK(c, profile)
+}
+
+// UpdateProfile handles requests to update the current user's name, locale,
+// time zone and notification preferences. Only the fields present are changed.
+// Returns 400 for invalid input, 404 if the user does not exist and 500 for
+// unexpected errors.
+func (h *Handler) UpdateProfile(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req ProfileRequest
+
+	// Try to parse JSON from the request body into ProfileRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields (locale, timezone, notification preferences).
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	update := &model.ProfileUpdate{
+		Name:     req.Name,
+		Locale:   req.Locale,
+		Timezone: req.Timezone,
+	}
+	if p := req.NotificationPreferences; p != nil {
+		update.NotificationPreferences = &model.NotificationPreferences{
+			Channels:           p.Channels,
+			WebhookURL:         p.WebhookURL,
+			TelegramChatID:     p.TelegramChatID,
+			OptOutNonEssential: p.OptOutNonEssential,
+		}
+	}
+
+	profile, err := h.service.UpdateProfile(c.Request.Context(), userID, update)
+	if err != nil {
+		// Missing channel address: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrWebhookURLRequired) || errors.Is(err, userservice.ErrTelegramChatIDRequired) {
+			zlog.Logger.Error().Err(err).Msg("invalid notification preferences")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Blank name: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidName) {
+			zlog.Logger.Error().Err(err).Msg("invalid name")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// If user not found, return 404 Not Found.
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			zlog.Logger.Error().Err(err).Msg("user not found")
+			response.Fail(c, http.StatusNotFound, fmt.Errorf("user not found"))
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to update profile")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the updated profile.
+	response.OK(c, profile)
+}
+
+// ChangePassword handles requests to change the current user's password. The
+// current password must be given, and all sessions are revoked.
+// Returns 400 for invalid input, 403 if the current password is wrong, 409 if
+// the account has no password, 429 with Retry-After after too many attempts
+// and 500 for unexpected errors.
+func (h *Handler) ChangePassword(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req ChangePasswordRequest
+
+	// Try to parse JSON from the request body into ChangePasswordRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	err = h.service.ChangePassword(c.Request.Context(), userID, req.CurrentPassword, req.NewPassword, c.ClientIP())
+	if err != nil {
+		if !h.failPasswordCheck(c, err) {
+			// Internal Server Error.
+			zlog.Logger.Error().Err(err).Msg("failed to change password")
+			response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "password changed, all sessions have been logged out",
+	})
+}
+
+// ChangeEmail handles requests to change the current user's email address.
+// The password must be given. The new address is pending until it is confirmed
+// with the link emailed to it. It responds with the user and pending address.
+// Returns 400 for invalid input or an unchanged address, 403 if the password
+// is wrong, 409 if the account has no password or the address is taken, 429
+// with Retry-After after too many attempts and 500 for unexpected errors.
+func (h *Handler) ChangeEmail(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req ChangeEmailRequest
+
+	// Try to parse JSON from the request body into ChangeEmailRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields (email, password).
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	user, err := h.service.ChangeEmail(c.Request.Context(), userID, req.Password, req.Email, c.ClientIP())
+	if err != nil {
+		// Same address: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrEmailUnchanged) {
+			zlog.Logger.Error().Err(err).Msg("email unchanged")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Address taken: return 409 Conflict.
+		if errors.Is(err, userservice.ErrUserAlreadyExists) {
+			zlog.Logger.Error().Err(err).Msg("email already taken")
+			response.Fail(c, http.StatusConflict, fmt.Errorf("email is already taken"))
+			return
+		}
+
+		if !h.failPasswordCheck(c, err) {
+			// Internal Server Error.
+			zlog.Logger.Error().Err(err).Msg("failed to change email")
+			response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		}
+		return
+	}
+
+	// Return the user with the pending address.
+	response.OK(c, map[string]*model.User{
+		"user": user,
+	})
+}
+
+// GetNotificationPreferences handles requests to fetch the current user's notification preferences.
+func (h *Handler) GetNotificationPreferences(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	prefs, err := h.service.GetNotificationPreferences(c.Request.Context(), userID)
+	if err != nil {
+		// If user not found, return 404 Not Found.
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			zlog.Logger.Error().Err(err).Msg("user not found")
+			response.Fail(c, http.StatusNotFound, fmt.Errorf("user not found"))
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to get notification preferences")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return preferences.
+	response.OK(c, map[string]*model.NotificationPreferences{
+		"preferences": prefs,
+	})
+}
+
+// UpdateNotificationPreferences handles requests to replace the current user's notification preferences.
+func (h *Handler) UpdateNotificationPreferences(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	var req NotificationPreferencesRequest
+
+	// Try to parse JSON from the request body into NotificationPreferencesRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return
+	}
+
+	// Validate the request fields (channels, webhook url).
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return
+	}
+
+	prefs := &model.NotificationPreferences{
+		UserID:             userID,
+		Channels:           req.Channels,
+		WebhookURL:         req.WebhookURL,
+		TelegramChatID:     req.TelegramChatID,
+		OptOutNonEssential: req.OptOutNonEssential,
+	}
+
+	err = h.service.UpdateNotificationPreferences(c.Request.Context(), prefs)
+	if err != nil {
+		// Missing channel address: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrWebhookURLRequired) || errors.Is(err, userservice.ErrTelegramChatIDRequired) {
+			zlog.Logger.Error().Err(err).Msg("invalid notification preferences")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to update notification preferences")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return updated preferences.
+	response.OK(c, map[string]*model.NotificationPreferences{
+		"preferences": prefs,
+	})
+}
+
+// EnrollMFA handles requests to start two-factor enrollment for the current user.
+// It responds with the TOTP secret and the provisioning URI to show as a QR code.
+// Returns 409 if two-factor authentication is already enabled and 500 for unexpected errors.
+func (h *Handler) EnrollMFA(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	enrollment, err := h.service.EnrollMFA(c.Request.Context(), userID)
+	if err != nil {
+		// Already enabled: return 409 Conflict.
+		if errors.Is(err, userservice.ErrMFAAlreadyEnabled) {
+			zlog.Logger.Error().Err(err).Msg("mfa already enabled")
+			response.Fail(c, http.StatusConflict, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to enroll mfa")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the secret.
+	response.OK(c, enrollment)
+}
+
+// ConfirmMFA handles requests to verify the current user's enrollment with a
+// code from the authenticator app. It responds with the recovery codes, which
+// are shown only once.
+// Returns 400 for invalid input or a wrong code, 404 if there is no pending
+// enrollment, 409 if it is already enabled and 500 for unexpected errors.
+func (h *Handler) ConfirmMFA(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	req, ok := h.bindMFACode(c)
+	if !ok {
+		return
+	}
+
+	codes, err := h.service.ConfirmMFA(c.Request.Context(), userID, req.Code)
+	if err != nil {
+		// Wrong code: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidMFACode) {
+			zlog.Logger.Error().Err(err).Msg("invalid mfa code")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// No pending enrollment: return 404 Not Found.
+		if errors.Is(err, userservice.ErrMFANotEnrolled) {
+			zlog.Logger.Error().Err(err).Msg("mfa not enrolled")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Already enabled: return 409 Conflict.
+		if errors.Is(err, userservice.ErrMFAAlreadyEnabled) {
+			zlog.Logger.Error().Err(err).Msg("mfa already enabled")
+			response.Fail(c, http.StatusConflict, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to confirm mfa")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return the recovery codes.
+	response.OK(c, map[string][]string{
+		"recovery_codes": codes,
+	})
+}
+
+// DisableMFA handles requests to turn off two-factor authentication for the
+// current user with a TOTP or recovery code. All sessions are revoked.
+// Returns 400 for invalid input or a wrong code, 404 if it is not enabled
+// and 500 for unexpected errors.
+func (h *Handler) DisableMFA(c *ginext.Context) {
+	userID, err := getUserID(c)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("unauthorized")
+		response.Fail(c, http.StatusUnauthorized, err)
+		return
+	}
+
+	req, ok := h.bindMFACode(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DisableMFA(c.Request.Context(), userID, req.Code); err != nil {
+		// Wrong code: return 400 Bad Request.
+		if errors.Is(err, userservice.ErrInvalidMFACode) {
+			zlog.Logger.Error().Err(err).Msg("invalid mfa code")
+			response.Fail(c, http.StatusBadRequest, err)
+			return
+		}
+
+		// Not enabled: return 404 Not Found.
+		if errors.Is(err, userservice.ErrMFANotEnrolled) {
+			zlog.Logger.Error().Err(err).Msg("mfa not enrolled")
+			response.Fail(c, http.StatusNotFound, err)
+			return
+		}
+
+		// Internal Server Error.
+		zlog.Logger.Error().Err(err).Msg("failed to disable mfa")
+		response.Fail(c, http.StatusInternalServerError, fmt.Errorf("internal server error"))
+		return
+	}
+
+	// Return success.
+	response.OK(c, map[string]string{
+		"message": "two-factor authentication disabled, all sessions have been logged out",
+	})
+}
+
+// bindMFACode parses and validates an MFACodeRequest, responding with 400 if it is invalid.
+func (h *Handler) bindMFACode(c *ginext.Context) (*MFACodeRequest, bool) {
+	var req MFACodeRequest
+
+	// Try to parse JSON from the request body into MFACodeRequest struct.
+	if err := c.ShouldBindJSON(&req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to bind json")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("invalid request body"))
+		return nil, false
+	}
+
+	// Validate the request fields.
+	if err := h.validator.Struct(req); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to validate request")
+		response.Fail(c, http.StatusBadRequest, fmt.Errorf("validation error: %s", err.Error()))
+		return nil, false
+	}
+
+	return &req, true
+}
+
+// failPasswordCheck responds to errors of the password check before a
+// sensitive change and reports whether err was one of them.
+func (h *Handler) failPasswordCheck(c *ginext.Context, err error) bool {
+	// Too many attempts: return 429 Too Many Requests with the time to wait.
+	var throttled *loginservice.ThrottledError
+	if errors.As(err, &throttled) {
+		zlog.Logger.Error().Err(err).Bool("locked", throttled.Locked).Msg("password check throttled")
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		response.Fail(c, http.StatusTooManyRequests, err)
+		return true
+	}
+
+	// Wrong password: return 403 Forbidden.
+	if errors.Is(err, userservice.ErrWrongPassword) {
+		zlog.Logger.Error().Err(err).Msg("wrong password")
+		response.Fail(c, http.StatusForbidden, err)
+		return true
+	}
+
+	// No password to check: return 409 Conflict.
+	if errors.Is(err, userservice.ErrPasswordNotSet) {
+		zlog.Logger.Error().Err(err).Msg("password not set")
+		response.Fail(c, http.StatusConflict, err)
+		return true
+	}
+
+	// If user not found, return 404 Not Found.
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		zlog.Logger.Error().Err(err).Msg("user not found")
+		response.Fail(c, http.StatusNotFound, fmt.Errorf("user not found"))
+		return true
+	}
+
+	return false
+}
+
+// getUserID extracts the userID from the request context.
+// Returns an error if the userID is missing or invalid.
+func getUserID(c *gin.Context) (uuid.UUID, error) {
+	val, exists := c.Get("userID")
+	if !exists {
+		return uuid.Nil, fmt.Errorf("userID not found in context")
+	}
+	userID, ok := val.(uuid.UUID)
+	if !ok || userID == uuid.Nil {
+		return uuid.Nil, fmt.Errorf("invalid userID in context")
+	}
+	return userID, nil
+}
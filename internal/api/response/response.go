@@ -29,6 +29,11 @@ func Created(c *ginext.Context, result interface{}) {
 	JSON(c, http.StatusCreated, Success{Result: result})
 }
 
+// Accepted sends a 202 Accepted response
+func Accepted(c *ginext.Context, result interface{}) {
+	JSON(c, http.StatusAccepted, Success{Result: result})
+}
+
 // Fail sends an error response with a given status code
 func Fail(c *ginext.Context, status int, err error) {
 	JSON(c, status, Error{Message: err.Error()})
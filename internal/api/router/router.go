@@ -1,17 +1,51 @@
 package router
 
 import (
+	"expvar"
+
 	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
 	"github.com/wb-go/wbf/ginext"
 
+	"github.com/aliskhannn/event-booker/internal/api/handler/apikey"
 	"github.com/aliskhannn/event-booker/internal/api/handler/auth"
 	"github.com/aliskhannn/event-booker/internal/api/handler/event"
+	"github.com/aliskhannn/event-booker/internal/api/handler/job"
+	"github.com/aliskhannn/event-booker/internal/api/handler/jwks"
+	"github.com/aliskhannn/event-booker/internal/api/handler/login"
+	"github.com/aliskhannn/event-booker/internal/api/handler/notification"
+	"github.com/aliskhannn/event-booker/internal/api/handler/outbox"
+	"github.com/aliskhannn/event-booker/internal/api/handler/retention"
+	"github.com/aliskhannn/event-booker/internal/api/handler/user"
 	"github.com/aliskhannn/event-booker/internal/config"
 	"github.com/aliskhannn/event-booker/internal/middleware"
+	"github.com/aliskhannn/event-booker/internal/model"
 )
 
+// Handlers holds the handlers of the API routes.
+type Handlers struct {
+	Auth         *auth.Handler
+	User         *user.Handler
+	Event        *event.Handler
+	Notification *notification.Handler
+	Outbox       *outbox.Handler
+	Job          *job.Handler
+	Retention    *retention.Handler
+	Login        *login.Handler
+	JWKS         *jwks.Handler
+	APIKey       *apikey.Handler
+}
+
+// Verifiers holds what the authentication middlewares check requests against.
+type Verifiers struct {
+	Keys        middleware.KeySet         // keys verifying access tokens
+	Revocations middleware.RevocationList // revoked access tokens
+	APIKeys     middleware.APIKeyVerifier // API keys of server-to-server integrations
+	Emails      middleware.EmailVerifier  // whether users verified their email address
+}
+
 // New creates a new Gin engine and sets up routes for the API.
-func New(authHandler *auth.Handler, eventHandler *event.Handler, cfg *config.Config) *ginext.Engine {
+func New(h Handlers, v Verifiers, cfg *config.Config) *ginext.Engine {
 	// Create a new Gin engine using the extended gin wrapper.
 	e := ginext.New()
 
@@ -26,33 +60,155 @@ func New(authHandler *auth.Handler, eventHandler *event.Handler, cfg *config.Con
 	}))
 	e.Use(ginext.Logger())
 	e.Use(ginext.Recovery())
+	e.Use(middleware.ReadYourWrites(cfg.Database.ReadAfterWriteWindow))
+
+	// Public keys for other services to verify access tokens
+	e.GET("/.well-known/jwks.json", h.JWKS.GetKeys)
+
+	// Every protected route validates the access token and its revocation.
+	// API keys are rejected unless the route requires scopes the key has.
+	// Privileged roles may have to sign in with a second factor to use them.
+	requireAuth := middleware.Auth(v.Keys, cfg.JWT.TTL, v.Revocations, v.APIKeys)
+	requireScope := func(scopes ...string) ginext.HandlerFunc {
+		return middleware.Auth(v.Keys, cfg.JWT.TTL, v.Revocations, v.APIKeys, scopes...)
+	}
+	requireMFA := middleware.RequireMFA(cfg.Auth.RequireMFARoles...)
+
+	// Runtime and scheduler metrics (expvar), for admins only: they include the command line
+	e.GET("/debug/vars", requireAuth, requireMFA, middleware.RequireRole(model.RoleAdmin), gin.WrapH(expvar.Handler()))
 
 	// --- Auth routes ---
 	authGroup := e.Group("/api/auth")
 	{
 		// Register a new user
-		authGroup.POST("/register", authHandler.Register)
+		authGroup.POST("/register", h.Auth.Register)
+
+		// Login user and return access and refresh tokens, or a two-factor challenge
+		authGroup.POST("/login", h.Auth.Login)
+		authGroup.POST("/login/2fa", h.Auth.CompleteMFALogin)
 
-		// Login user and return JWT token
-		authGroup.POST("/login", authHandler.Login)
+		// Login with the OpenID Connect provider: get its URL, then post the code it returned
+		authGroup.GET("/oidc/authorize", h.Auth.StartOIDCLogin)
+		authGroup.POST("/oidc/callback", h.Auth.CompleteOIDCLogin)
+
+		// Exchange a refresh token for a new pair of tokens
+		authGroup.POST("/refresh", h.Auth.Refresh)
+
+		// Revoke the current session, or all sessions with ?all=true
+		authGroup.POST("/logout", requireAuth, h.Auth.Logout)
+
+		// Email a password reset link and reset the password with it
+		authGroup.POST("/password/forgot", h.Auth.ForgotPassword)
+		authGroup.POST("/password/reset", h.Auth.ResetPassword)
+
+		// Email a one-time login link and log in with it
+		authGroup.POST("/magic-link", h.Auth.RequestMagicLink)
+		authGroup.POST("/magic-link/verify", h.Auth.LoginWithMagicLink)
+
+		// Verify the email address with the emailed link, or send a new link
+		authGroup.POST("/verify-email", h.Auth.VerifyEmail)
+		authGroup.POST("/verify-email/resend", requireAuth, h.Auth.ResendVerificationEmail)
 	}
 
+	// --- Two-factor authentication routes ---
+	// Reachable without a second factor, so that privileged users can enroll.
+	mfaGroup := e.Group("/api/me/2fa", requireAuth)
+	{
+		mfaGroup.POST("/enroll", h.User.EnrollMFA)
+		mfaGroup.POST("/verify", h.User.ConfirmMFA)
+		mfaGroup.POST("/disable", h.User.DisableMFA)
+	}
+
+	// --- Current user routes ---
+	meGroup := e.Group("/api/me", requireAuth, requireMFA)
+	{
+		// Profile: name, locale, time zone and notification preferences
+		meGroup.GET("", h.User.GetProfile)
+		meGroup.PATCH("", h.User.UpdateProfile)
+
+		// Change the password or the email address, confirming with the current password
+		meGroup.POST("/password", h.User.ChangePassword)
+		meGroup.POST("/email", h.User.ChangeEmail)
+
+		// Notification channels and opt-out
+		meGroup.GET("/notifications", h.User.GetNotificationPreferences)
+		meGroup.PUT("/notifications", h.User.UpdateNotificationPreferences)
+
+		// API keys for server-to-server integrations, managed by organizers
+		apiKeyGroup := meGroup.Group("/api-keys", middleware.RequireRole(model.RoleOrganizer, model.RoleAdmin))
+		apiKeyGroup.GET("", h.APIKey.GetAPIKeys)
+		apiKeyGroup.POST("", h.APIKey.CreateAPIKey)
+		apiKeyGroup.DELETE("/:keyID", h.APIKey.RevokeAPIKey)
+	}
+
+	// Bookings may require a verified email address. Guests, if allowed, book
+	// without a token and confirm with a link emailed to them.
+	bookEvent := []ginext.HandlerFunc{requireScope(model.ScopeBookingsWrite), requireMFA}
+	if cfg.Auth.GuestCheckout {
+		bookEvent[0] = middleware.OptionalAuth(v.Keys, cfg.JWT.TTL, v.Revocations, v.APIKeys, model.ScopeBookingsWrite)
+	}
+	if cfg.Auth.RequireVerifiedEmail {
+		requireVerified := middleware.RequireVerifiedEmail(v.Emails)
+		if cfg.Auth.GuestCheckout {
+			requireVerified = middleware.IfAuthenticated(requireVerified)
+		}
+		bookEvent = append(bookEvent, requireVerified)
+	}
+	bookEvent = append(bookEvent, h.Event.BookEvent)
+
 	// --- Event routes ---
 	eventGroup := e.Group("/api/events")
 	{
-		// Public routes: anyone can view event details
-		eventGroup.GET("", eventHandler.GetEvents)
-
-		eventGroup.GET("/:eventID", eventHandler.GetEvent)
-
-		// Protected routes: require auth
-		eventGroup.Use(middleware.Auth(cfg.JWT.Secret, cfg.JWT.TTL))
-		{
-			eventGroup.POST("", eventHandler.CreateEvent)
-			eventGroup.POST("/:eventID/book", eventHandler.BookEvent)
-			eventGroup.POST("/:eventID/booking/:bookingID/confirm", eventHandler.ConfirmBooking)
-			eventGroup.POST("/:eventID/booking/:bookingID/cancel", eventHandler.CancelBooking)
+		// Public routes: anyone can view event details; API keys need events:read
+		readEvents := middleware.OptionalAPIKey(v.APIKeys, model.ScopeEventsRead)
+		eventGroup.GET("", readEvents, h.Event.GetEvents)
+		eventGroup.GET("/:eventID", readEvents, h.Event.GetEvent)
+
+		// Protected routes: require auth, or an API key with the route's scope
+		eventGroup.POST("", requireAuth, requireMFA,
+			middleware.RequireRole(model.RoleOrganizer, model.RoleAdmin), h.Event.CreateEvent)
+		eventGroup.POST("/:eventID/book", bookEvent...)
+		if cfg.Auth.GuestCheckout {
+			// Guests confirm with the link emailed to them, which needs no login
+			eventGroup.POST("/:eventID/book/confirm", h.Event.ConfirmGuestBooking)
 		}
+
+		writeBookings := requireScope(model.ScopeBookingsWrite)
+		eventGroup.POST("/:eventID/booking/:bookingID/confirm", writeBookings, requireMFA, h.Event.ConfirmBooking)
+		eventGroup.POST("/:eventID/booking/:bookingID/cancel", writeBookings, requireMFA, h.Event.CancelBooking)
+
+		// Organizers and their integrations see who booked
+		eventGroup.GET("/:eventID/attendees",
+			requireScope(model.ScopeAttendeesRead), requireMFA,
+			middleware.RequireRole(model.RoleOrganizer, model.RoleAdmin), h.Event.GetAttendees)
+	}
+
+	// --- Admin routes ---
+	adminGroup := e.Group("/api/admin", requireAuth, requireMFA, middleware.RequireRole(model.RoleAdmin))
+	{
+		// Notification templates
+		adminGroup.GET("/notifications/templates", h.Notification.GetTemplates)
+		adminGroup.GET("/notifications/templates/:name/preview", h.Notification.PreviewTemplate)
+
+		// Notification outbox: failed deliveries and replay
+		adminGroup.GET("/outbox", h.Outbox.GetMessages)
+		adminGroup.POST("/outbox/:messageID/replay", h.Outbox.ReplayMessage)
+
+		// Seat-count consistency report
+		adminGroup.GET("/seats/drifts", h.Event.GetSeatDrifts)
+
+		// Archived events and bookings
+		adminGroup.GET("/retention", h.Retention.GetReport)
+
+		// Failed login audit
+		adminGroup.GET("/logins/failures", h.Login.GetFailures)
+
+		// Scheduler jobs: status, run history, pause/resume and manual trigger
+		adminGroup.GET("/jobs", h.Job.GetJobs)
+		adminGroup.GET("/jobs/:name/runs", h.Job.GetJobRuns)
+		adminGroup.POST("/jobs/:name/pause", h.Job.PauseJob)
+		adminGroup.POST("/jobs/:name/resume", h.Job.ResumeJob)
+		adminGroup.POST("/jobs/:name/trigger", h.Job.TriggerJob)
 	}
 
 	return e
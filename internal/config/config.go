@@ -10,15 +10,38 @@ import (
 
 // Config holds the main configuration for the application.
 type Config struct {
+	App      App      `mapstructure:"app"`
 	Server   Server   `mapstructure:"server"`
 	Database Database `mapstructure:"database"`
 	JWT      JWT      `mapstructure:"jwt"`
-	Email    Email    `mapstructure:"email"`
+	Auth     Auth     `mapstructure:"auth"`
+	OIDC     OIDC     `mapstructure:"oidc"`
+
+	LoginGuard LoginGuard `mapstructure:"login_guard"`
+	Email      Email      `mapstructure:"email"`
+	Outbox     Outbox     `mapstructure:"outbox"`
+
+	Notifications Notifications `mapstructure:"notifications"`
+	Reminders     Reminders     `mapstructure:"reminders"`
+	HoldWarnings  HoldWarnings  `mapstructure:"hold_warnings"`
+	Scheduler     Scheduler     `mapstructure:"scheduler"`
+	Events        Events        `mapstructure:"events"`
+	Reconcile     Reconcile     `mapstructure:"reconcile"`
+	Retention     Retention     `mapstructure:"retention"`
+}
+
+// App holds application-wide configuration.
+type App struct {
+	BaseURL string `mapstructure:"base_url"` // public URL of the web UI, used in links sent to users
 }
 
 // Server holds HTTP server-related configuration.
 type Server struct {
 	HTTPPort string `mapstructure:"http_port"` // HTTP port to listen on
+
+	// TrustedProxies lists the addresses or CIDRs of reverse proxies whose
+	// X-Forwarded-For header is believed. Empty: the connection's address is the client IP.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
 }
 
 // Database holds database master and slave configuration.
@@ -29,6 +52,10 @@ type Database struct {
 	MaxOpenConns    int           `mapstructure:"max_open_conns"`
 	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
 	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+
+	ReadAfterWriteWindow time.Duration `mapstructure:"read_after_write_window"` // how long a client's reads go to the master after it writes
+	MaxReplicaLag        time.Duration `mapstructure:"max_replica_lag"`         // replicas lagging more than this are excluded from reads
+	ReplicaCheckInterval time.Duration `mapstructure:"replica_check_interval"`  // how often replica lag is checked
 }
 
 // DatabaseNode holds connection parameters for a single database node.
@@ -43,8 +70,94 @@ type DatabaseNode struct {
 
 // JWT holds JWT-related configuration.
 type JWT struct {
-	Secret string        `mapstructure:"secret"`
-	TTL    time.Duration `mapstructure:"ttl"`
+	Secret     string        `mapstructure:"secret"`      // HS256 key of tokens without a kid; disabled if empty
+	SigningKey string        `mapstructure:"signing_key"` // kid of the key signing new tokens; the secret if empty
+	Keys       []JWTKey      `mapstructure:"keys"`        // keys selected by kid
+	TTL        time.Duration `mapstructure:"ttl"`         // access token lifetime
+	RefreshTTL time.Duration `mapstructure:"refresh_ttl"` // refresh token lifetime
+}
+
+// JWT signing algorithms.
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+// JWTKey holds a JWT key. Keys without a private key or secret only verify
+// tokens, e.g. while they are being rotated in or out.
+type JWTKey struct {
+	ID             string `mapstructure:"id"`               // kid in token headers and the JWKS
+	Algorithm      string `mapstructure:"algorithm"`        // HS256, RS256 or EdDSA
+	Secret         string `mapstructure:"secret"`           // HS256 only
+	PrivateKeyFile string `mapstructure:"private_key_file"` // PEM (PKCS #8, or PKCS #1 for RSA)
+	PublicKeyFile  string `mapstructure:"public_key_file"`  // PEM (PKIX); derived from the private key if empty
+}
+
+// Auth holds account security configuration.
+type Auth struct {
+	PasswordResetTTL time.Duration `mapstructure:"password_reset_ttl"` // how long a password reset link is valid
+
+	LinkSecret           string        `mapstructure:"link_secret"`            // key signing email verification links
+	EmailVerificationTTL time.Duration `mapstructure:"email_verification_ttl"` // how long an email verification link is valid
+	RequireVerifiedEmail bool          `mapstructure:"require_verified_email"` // block bookings by users with unverified emails
+
+	MFAIssuer       string        `mapstructure:"mfa_issuer"`        // name shown in authenticator apps
+	MFAChallengeTTL time.Duration `mapstructure:"mfa_challenge_ttl"` // how long a login waits for the second factor
+	RequireMFARoles []string      `mapstructure:"require_mfa_roles"` // roles that must sign in with a second factor
+
+	MagicLinkTTL  time.Duration `mapstructure:"magic_link_ttl"` // how long an emailed login link is valid
+	GuestCheckout bool          `mapstructure:"guest_checkout"` // allow booking with just an email address
+	GuestLimits   GuestLimits   `mapstructure:"guest_limits"`
+}
+
+// GuestLimits holds the limits of guest checkout, which needs no login.
+// Zero disables a limit.
+type GuestLimits struct {
+	Window     time.Duration `mapstructure:"window"`      // a client's or email's count starts over after a window without requests
+	PerClient  int           `mapstructure:"per_client"`  // booking requests per client IP or API key
+	PerEmail   int           `mapstructure:"per_email"`   // booking requests per email address
+	MaxPending int           `mapstructure:"max_pending"` // pending guest bookings per event
+}
+
+// OIDC holds configuration of login with an OpenID Connect provider.
+type OIDC struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Issuer       string        `mapstructure:"issuer"`        // provider URL; its metadata is discovered below /.well-known/openid-configuration
+	ClientID     string        `mapstructure:"client_id"`     // client registered at the provider
+	ClientSecret string        `mapstructure:"client_secret"` // empty for public clients, which rely on PKCE alone
+	RedirectURL  string        `mapstructure:"redirect_url"`  // page of the web UI the provider returns to
+	Scopes       []string      `mapstructure:"scopes"`        // requested scopes; must include openid and email
+	StateTTL     time.Duration `mapstructure:"state_ttl"`     // how long a started login may take at the provider
+	Timeout      time.Duration `mapstructure:"timeout"`       // timeout of requests to the provider
+}
+
+// Login guard counter stores.
+const (
+	LoginGuardStorePostgres = "postgres"
+	LoginGuardStoreMemory   = "memory"
+)
+
+// LoginGuard holds login brute-force protection configuration.
+//
+// Attempts are counted per account and per client IP. Once a counter exceeds
+// its free attempts, each further attempt must wait BaseDelay, doubling up to
+// MaxDelay, after the previous one. At its lockout, the account or IP is locked
+// out until Window passes without attempts. Rejected attempts are not counted.
+// Successful logins clear the account's count.
+type LoginGuard struct {
+	Store     string        `mapstructure:"store"`  // "postgres" (shared by all instances) or "memory"
+	Window    time.Duration `mapstructure:"window"` // counts start over after this long without attempts
+	BaseDelay time.Duration `mapstructure:"base_delay"`
+	MaxDelay  time.Duration `mapstructure:"max_delay"`
+	Account   LoginLimit    `mapstructure:"account"`
+	IP        LoginLimit    `mapstructure:"ip"`
+}
+
+// LoginLimit holds the limits of a login attempt counter.
+type LoginLimit struct {
+	FreeAttempts int `mapstructure:"free_attempts"` // attempts allowed without delay
+	Lockout      int `mapstructure:"lockout"`       // attempts that lock out; 0 disables the lockout
 }
 
 // Email holds SMTP configuration for sending emails.
@@ -56,6 +169,91 @@ type Email struct {
 	From     string `mapstructure:"from"`
 }
 
+// Outbox holds notification outbox delivery configuration.
+type Outbox struct {
+	BatchSize   int           `mapstructure:"batch_size"`   // messages claimed per dispatch run
+	MaxAttempts int           `mapstructure:"max_attempts"` // attempts before a message is dead-lettered
+	BaseBackoff time.Duration `mapstructure:"base_backoff"` // delay after the first failed attempt
+	MaxBackoff  time.Duration `mapstructure:"max_backoff"`  // upper bound for the retry delay
+	Lease       time.Duration `mapstructure:"lease"`        // how long a claimed message is hidden from other dispatchers
+}
+
+// Notifications holds configuration of the non-email notification channels.
+type Notifications struct {
+	Webhook  Webhook  `mapstructure:"webhook"`
+	Telegram Telegram `mapstructure:"telegram"`
+}
+
+// Webhook holds configuration of the outbound HTTP webhook channel.
+type Webhook struct {
+	Timeout       time.Duration `mapstructure:"timeout"`        // request timeout
+	SigningSecret string        `mapstructure:"signing_secret"` // HMAC secret for the signature header; unsigned if empty
+}
+
+// Telegram holds configuration of the Telegram bot channel.
+// The channel is disabled if BotToken is empty.
+type Telegram struct {
+	BaseURL  string        `mapstructure:"base_url"`  // Bot API root URL
+	BotToken string        `mapstructure:"bot_token"` // bot token
+	Timeout  time.Duration `mapstructure:"timeout"`   // request timeout
+}
+
+// Reminders holds configuration of event reminders sent to attendees.
+type Reminders struct {
+	Offsets []time.Duration `mapstructure:"offsets"` // how long before an event reminders are sent
+}
+
+// HoldWarnings holds configuration of warnings sent before a pending booking expires.
+type HoldWarnings struct {
+	Lead     time.Duration `mapstructure:"lead"`     // warn when this much time is left
+	Fraction float64       `mapstructure:"fraction"` // if set, warn when this fraction of the booking TTL is left instead
+}
+
+// Events holds the limits of events created through the API.
+type Events struct {
+	MaxSeats int `mapstructure:"max_seats"` // most seats an event may have
+}
+
+// Reconcile holds configuration of the seat-count reconciliation job.
+type Reconcile struct {
+	AutoCorrect bool `mapstructure:"auto_correct"` // reset drifted available_seats counters instead of only reporting them
+}
+
+// Retention holds the data retention policy for old events and bookings.
+type Retention struct {
+	ArchiveEventsAfter   time.Duration `mapstructure:"archive_events_after"`   // archive events this long after they took place; never if zero
+	DeleteCancelledAfter time.Duration `mapstructure:"delete_cancelled_after"` // delete bookings this long after they were cancelled; never if zero
+	DeleteLoginsAfter    time.Duration `mapstructure:"delete_logins_after"`    // delete failed login records and idle attempt counters this old; never if zero
+	DeleteJobRunsAfter   time.Duration `mapstructure:"delete_job_runs_after"`  // delete finished job runs this long after they started; never if zero
+	BatchSize            int           `mapstructure:"batch_size"`             // events or bookings moved per statement
+}
+
+// Scheduler holds configuration of the background jobs.
+type Scheduler struct {
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"` // how long shutdown waits for running jobs before cancelling them
+
+	CancelExpiredBookings Job `mapstructure:"cancel_expired_bookings"`
+	HoldExpiryWarning     Job `mapstructure:"hold_expiry_warning"`
+	EventReminder         Job `mapstructure:"event_reminder"`
+	DispatchOutbox        Job `mapstructure:"dispatch_outbox"`
+	SeatReconciliation    Job `mapstructure:"seat_reconciliation"`
+	Retention             Job `mapstructure:"retention"`
+}
+
+// Job holds configuration of a single background job.
+type Job struct {
+	Enabled      *bool         `mapstructure:"enabled"`       // whether the job is scheduled; defaults to true
+	Schedule     string        `mapstructure:"schedule"`      // cron spec with seconds; the job's built-in schedule if empty
+	Timeout      time.Duration `mapstructure:"timeout"`       // deadline of a single attempt; none if zero
+	Retries      int           `mapstructure:"retries"`       // extra attempts after a failed one
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"` // delay before the first retry, doubled for each next one
+}
+
+// IsEnabled reports whether the job is enabled.
+func (j Job) IsEnabled() bool {
+	return j.Enabled == nil || *j.Enabled
+}
+
 // DSN returns the PostgreSQL DSN string for connecting to this database node.
 func (n DatabaseNode) DSN() string {
 	return fmt.Sprintf(
@@ -69,6 +267,8 @@ func (n DatabaseNode) DSN() string {
 // It panics if any environment variable cannot be bound.
 func mustBindEnv() {
 	bindings := map[string]string{
+		"app.base_url": "APP_BASE_URL",
+
 		"database.master.host": "DB_HOST",
 		"database.master.port": "DB_PORT",
 		"database.master.user": "DB_USER",
@@ -77,11 +277,20 @@ func mustBindEnv() {
 
 		"jwt.secret": "JWT_SECRET",
 
+		"auth.link_secret": "AUTH_LINK_SECRET",
+
+		"oidc.issuer":        "OIDC_ISSUER",
+		"oidc.client_id":     "OIDC_CLIENT_ID",
+		"oidc.client_secret": "OIDC_CLIENT_SECRET",
+
 		"email.smtp_host": "SMTP_HOST",
 		"email.smtp_port": "SMTP_PORT",
 		"email.username":  "SMTP_USER",
 		"email.password":  "SMTP_PASS",
 		"email.from":      "SMTP_FROM",
+
+		"notifications.webhook.signing_secret": "WEBHOOK_SIGNING_SECRET",
+		"notifications.telegram.bot_token":     "TELEGRAM_BOT_TOKEN",
 	}
 
 	for key, env := range bindings {
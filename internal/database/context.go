@@ -0,0 +1,21 @@
+package database
+
+import (
+	"context"
+)
+
+// masterKey is the context key that marks reads which must go to the master.
+type masterKey struct{}
+
+// WithMaster returns a context whose reads go to the master, e.g. because the
+// caller has just written and must see its own write, or because the read
+// decides whether a write may happen.
+func WithMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, masterKey{}, true)
+}
+
+// usesMaster reports whether reads with ctx must go to the master.
+func usesMaster(ctx context.Context) bool {
+	v, _ := ctx.Value(masterKey{}).(bool)
+	return v
+}
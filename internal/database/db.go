@@ -0,0 +1,145 @@
+// Package database routes queries between the PostgreSQL master and its read replicas.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"expvar"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/wb-go/wbf/dbpg"
+	"github.com/wb-go/wbf/zlog"
+)
+
+// replicaLag exports the last measured lag of each replica in seconds, keyed by its index.
+// A replica that could not be checked is reported as -1.
+var replicaLag = expvar.NewMap("db_replica_lag_seconds")
+
+// lagQuery returns how far a replica is behind the master in seconds.
+// A replica that has replayed everything it received is not lagging,
+// however long ago the last transaction was.
+const lagQuery = `
+	SELECT CASE
+		WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE COALESCE(EXTRACT(EPOCH FROM NOW() - pg_last_xact_replay_timestamp()), 0)
+	END::FLOAT8;
+`
+
+// DB wraps dbpg.DB and routes reads to the master when the request needs
+// to see its own writes, and to the replicas that are not lagging otherwise.
+// Writes and explicit r.db.Master calls behave as with dbpg.DB.
+type DB struct {
+	*dbpg.DB
+
+	maxLag  time.Duration
+	healthy atomic.Pointer[[]*sql.DB] // replicas within maxLag
+	next    atomic.Uint64             // round-robin position among healthy replicas
+}
+
+// New wraps db. Until the first lag check, all replicas are considered healthy.
+// Replicas lagging more than maxLag are excluded from reads.
+func New(db *dbpg.DB, maxLag time.Duration) *DB {
+	d := &DB{DB: db, maxLag: maxLag}
+
+	replicas := append([]*sql.DB(nil), db.Slaves...)
+	d.healthy.Store(&replicas)
+
+	return d
+}
+
+// QueryContext runs a query on the master if ctx requires it or no replica is healthy,
+// and on a healthy replica otherwise.
+func (d *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return d.reader(ctx).QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext runs a query expected to return one row, routed like QueryContext.
+func (d *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return d.reader(ctx).QueryRowContext(ctx, query, args...)
+}
+
+// MonitorReplicas checks the lag of every replica each interval until ctx is done.
+// It returns immediately if there are no replicas.
+func (d *DB) MonitorReplicas(ctx context.Context, interval time.Duration) {
+	if len(d.Slaves) == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.checkReplicas(ctx, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// reader returns the database a read should go to.
+func (d *DB) reader(ctx context.Context) *sql.DB {
+	if usesMaster(ctx) {
+		return d.Master
+	}
+
+	replicas := *d.healthy.Load()
+	if len(replicas) == 0 {
+		return d.Master
+	}
+
+	return replicas[(d.next.Add(1)-1)%uint64(len(replicas))]
+}
+
+// checkReplicas measures the lag of all replicas in parallel and keeps the healthy ones.
+func (d *DB) checkReplicas(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ok := make([]bool, len(d.Slaves))
+
+	var wg sync.WaitGroup
+	for i, replica := range d.Slaves {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var lag float64
+			if err := replica.QueryRowContext(ctx, lagQuery).Scan(&lag); err != nil {
+				replicaLag.Set(strconv.Itoa(i), floatVar(-1))
+				zlog.Logger.Warn().Err(err).Int("replica", i).Msg("failed to check replica lag, excluding it from reads")
+				return
+			}
+
+			replicaLag.Set(strconv.Itoa(i), floatVar(lag))
+
+			if lag > d.maxLag.Seconds() {
+				zlog.Logger.Warn().Int("replica", i).Float64("lag_seconds", lag).Msg("replica is lagging, excluding it from reads")
+				return
+			}
+
+			ok[i] = true
+		}()
+	}
+	wg.Wait()
+
+	healthy := make([]*sql.DB, 0, len(d.Slaves))
+	for i, replica := range d.Slaves {
+		if ok[i] {
+			healthy = append(healthy, replica)
+		}
+	}
+	d.healthy.Store(&healthy)
+}
+
+// floatVar returns v as an expvar value.
+func floatVar(v float64) *expvar.Float {
+	f := new(expvar.Float)
+	f.Set(v)
+	return f
+}
@@ -0,0 +1,308 @@
+// Package jwtkeys holds the keys that sign and verify access tokens. Keys
+// are selected by the kid token header, so several can be active at once and
+// a new key can be rotated in without invalidating tokens signed by the old one.
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+)
+
+// minRSABits is the smallest RSA key size accepted.
+const minRSABits = 2048
+
+var (
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrAlgMismatch    = errors.New("token algorithm does not match its key")
+	ErrNoSigningKey   = errors.New("no signing key configured")
+	ErrVerifyOnlyKey  = errors.New("signing key has no private key or secret")
+	ErrUnsupportedAlg = errors.New("unsupported algorithm")
+)
+
+// key is a single signing or verification key.
+type key struct {
+	id        string
+	method    jwt.SigningMethod
+	signKey   any // []byte, *rsa.PrivateKey or ed25519.PrivateKey; nil if the key only verifies
+	verifyKey any // []byte, *rsa.PublicKey or ed25519.PublicKey
+}
+
+// Set is a set of keys that sign new tokens with one of them and verify
+// tokens signed with any of them.
+type Set struct {
+	keys    map[string]*key // by kid; "" is the HS256 secret of tokens without a kid
+	signing *key
+}
+
+// New loads the keys of cfg. The HS256 secret, if set, verifies tokens
+// without a kid and signs new tokens unless cfg.SigningKey names another key.
+func New(cfg config.JWT) (*Set, error) {
+	s := &Set{keys: make(map[string]*key)}
+
+	if cfg.Secret != "" {
+		s.keys[""] = &key{
+			method:    jwt.SigningMethodHS256,
+			signKey:   []byte(cfg.Secret),
+			verifyKey: []byte(cfg.Secret),
+		}
+	}
+
+	for _, kc := range cfg.Keys {
+		if kc.ID == "" {
+			return nil, errors.New("jwt key without id")
+		}
+		if _, ok := s.keys[kc.ID]; ok {
+			return nil, fmt.Errorf("duplicate jwt key id %q", kc.ID)
+		}
+
+		k, err := loadKey(kc)
+		if err != nil {
+			return nil, fmt.Errorf("load jwt key %q: %w", kc.ID, err)
+		}
+		s.keys[kc.ID] = k
+	}
+
+	signing, ok := s.keys[cfg.SigningKey]
+	if !ok {
+		if cfg.SigningKey == "" {
+			return nil, ErrNoSigningKey
+		}
+
+		return nil, fmt.Errorf("signing key %q: %w", cfg.SigningKey, ErrUnknownKey)
+	}
+	if signing.signKey == nil {
+		return nil, fmt.Errorf("signing key %q: %w", cfg.SigningKey, ErrVerifyOnlyKey)
+	}
+	s.signing = signing
+
+	return s, nil
+}
+
+// Sign signs claims with the signing key and sets its kid in the token header.
+func (s *Set) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(s.signing.method, claims)
+	if s.signing.id != "" {
+		token.Header["kid"] = s.signing.id
+	}
+
+	return token.SignedString(s.signing.signKey)
+}
+
+// Keyfunc returns the key to verify token with, selected by its kid header,
+// for use with jwt.Parse. The token's algorithm must be the key's, so that a
+// public key can never be used as an HMAC secret.
+func (s *Set) Keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, ErrAlgMismatch
+	}
+
+	return k.verifyKey, nil
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`   // RSA modulus
+	E         string `json:"e,omitempty"`   // RSA public exponent
+	Curve     string `json:"crv,omitempty"` // OKP curve
+	X         string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys of the set, including verification-only ones,
+// so that other services can verify tokens. HMAC secrets are never published.
+func (s *Set) JWKS() *JWKS {
+	set := &JWKS{Keys: make([]JWK, 0, len(s.keys))}
+
+	for _, k := range s.keys {
+		switch pub := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     k.id,
+				Use:       "sig",
+				Algorithm: k.method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     k.id,
+				Use:       "sig",
+				Algorithm: k.method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	slices.SortFunc(set.Keys, func(a, b JWK) int { return strings.Compare(a.KeyID, b.KeyID) })
+
+	return set
+}
+
+// loadKey loads a key from its configuration.
+func loadKey(kc config.JWTKey) (*key, error) {
+	k := &key{id: kc.ID}
+
+	switch kc.Algorithm {
+	case config.JWTAlgorithmHS256:
+		if kc.Secret == "" {
+			return nil, errors.New("HS256 key without secret")
+		}
+
+		k.method = jwt.SigningMethodHS256
+		k.signKey = []byte(kc.Secret)
+		k.verifyKey = []byte(kc.Secret)
+
+		return k, nil
+	case config.JWTAlgorithmRS256:
+		k.method = jwt.SigningMethodRS256
+	case config.JWTAlgorithmEdDSA:
+		k.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedAlg, kc.Algorithm)
+	}
+
+	if kc.PrivateKeyFile != "" {
+		priv, err := readPrivateKey(kc.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		k.signKey = priv
+		k.verifyKey = priv.Public()
+	}
+
+	if kc.PublicKeyFile != "" {
+		pub, err := readPublicKey(kc.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		// A public key given alongside the private key must be its own.
+		if priv, ok := k.signKey.(crypto.Signer); ok {
+			if own, ok := priv.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !own.Equal(pub) {
+				return nil, errors.New("public key does not match the private key")
+			}
+		}
+
+		k.verifyKey = pub
+	}
+
+	if k.verifyKey == nil {
+		return nil, errors.New("neither private_key_file nor public_key_file set")
+	}
+
+	if err := checkKeyType(k.method, k.verifyKey); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+// checkKeyType checks that a public key fits the algorithm.
+func checkKeyType(method jwt.SigningMethod, pub any) error {
+	switch method {
+	case jwt.SigningMethodRS256:
+		rsaKey, ok := pub.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 needs an RSA key")
+		}
+		if rsaKey.N.BitLen() < minRSABits {
+			return fmt.Errorf("RSA key must have at least %d bits", minRSABits)
+		}
+	case jwt.SigningMethodEdDSA:
+		if _, ok := pub.(ed25519.PublicKey); !ok {
+			return errors.New("EdDSA needs an Ed25519 key")
+		}
+	}
+
+	return nil
+}
+
+// readPrivateKey reads a PEM-encoded PKCS #8 or PKCS #1 private key.
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if priv, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := priv.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", priv)
+		}
+
+		return signer, nil
+	}
+
+	priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse private key %s: %w", path, err)
+	}
+
+	return priv, nil
+}
+
+// readPublicKey reads a PEM-encoded PKIX or PKCS #1 public key.
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if pub, err := x509.ParsePKIXPublicKey(block.Bytes); err == nil {
+		return pub, nil
+	}
+
+	pub, err := x509.ParsePKCS1PublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %s: %w", path, err)
+	}
+
+	return pub, nil
+}
+
+// readPEM reads the first PEM block of a file.
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+
+	return block, nil
+}
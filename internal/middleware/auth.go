@@ -1,8 +1,10 @@
 package middleware
 
 import (
+	"context"
 	"errors"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -10,8 +12,10 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/wb-go/wbf/ginext"
+	"github.com/wb-go/wbf/zlog"
 
 	"github.com/aliskhannn/event-booker/internal/api/response"
+	"github.com/aliskhannn/event-booker/internal/model"
 )
 
 var (
@@ -19,70 +23,302 @@ var (
 	ErrInvalidToken       = errors.New("invalid token")
 	ErrInvalidTokenFormat = errors.New("invalid token format")
 	ErrExpiredToken       = errors.New("token had expired")
+	ErrRevokedToken       = errors.New("token has been revoked")
+	ErrEmailNotVerified   = errors.New("email address is not verified")
+	ErrMFARequired        = errors.New("two-factor authentication required")
+	ErrInvalidAPIKey      = errors.New("invalid api key")
+	ErrAPIKeyNotAllowed   = errors.New("api keys cannot be used for this route")
+	ErrInsufficientScope  = errors.New("api key lacks the required scope")
+	ErrForbidden          = errors.New("forbidden")
 )
 
-// Auth returns a Gin middleware that validates JWT tokens.
-// It expects the token in the "Authorization" header in the format "Bearer <token>".
-// If the token is missing, malformed, invalid, or expired, it aborts the request with 401 Unauthorized.
-// On success, the middleware sets "userID" in the Gin context for downstream handlers.
-func Auth(secret string, ttl time.Duration) ginext.HandlerFunc {
+// RevocationList reports whether an access token was revoked, e.g. on logout.
+type RevocationList interface {
+	// IsAccessTokenRevoked checks whether the access token jti was revoked.
+	IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+}
+
+// EmailVerifier reports whether a user's email address is verified.
+type EmailVerifier interface {
+	// IsEmailVerified checks whether the email address of the user is verified.
+	IsEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// KeySet provides the keys that verify access tokens.
+type KeySet interface {
+	// Keyfunc returns the key to verify the token with, selected by its header.
+	Keyfunc(token *jwt.Token) (any, error)
+}
+
+// APIKeyVerifier checks the API keys of server-to-server integrations.
+type APIKeyVerifier interface {
+	// VerifyAPIKey returns the active API key presented from ip and records its use, or nil for invalid keys.
+	VerifyAPIKey(ctx context.Context, key, ip string) (*model.APIKey, error)
+}
+
+// claims holds the values extracted from a validated JWT token.
+type claims struct {
+	UserID uuid.UUID
+	Role   string
+	JTI    uuid.UUID
+	MFA    bool // the login was completed with a second factor
+}
+
+// Auth returns a Gin middleware that validates JWT tokens and API keys.
+// It expects the "Authorization" header in the format "Bearer <token>" or "ApiKey <key>".
+// If the token or key is missing, malformed, invalid, expired or revoked, it aborts the request with 401 Unauthorized.
+// API keys are only accepted if scopes are given and the key has all of them;
+// otherwise the request is aborted with 403 Forbidden.
+// On success, the middleware sets "userID" and "role" in the Gin context for downstream handlers,
+// along with "jti" and "mfa" for tokens and "apiKeyID" and "scopes" for API keys.
+func Auth(
+	keys KeySet, ttl time.Duration, revoked RevocationList, apiKeys APIKeyVerifier, scopes ...string,
+) ginext.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenStr := c.GetHeader("Authorization")
-		if tokenStr == "" {
+		if c.GetHeader("Authorization") == "" {
 			response.FailAbort(c, http.StatusUnauthorized, ErrNoToken)
 			return
 		}
 
-		parts := strings.Split(tokenStr, " ") // Bearer <token>
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			response.FailAbort(c, http.StatusUnauthorized, ErrInvalidTokenFormat)
+		if authenticate(c, keys, revoked, apiKeys, scopes) {
+			c.Next()
+		}
+	}
+}
+
+// OptionalAuth returns a Gin middleware like Auth that lets requests without
+// an "Authorization" header through anonymously, for routes that also serve
+// guests. A token or key that is sent must be valid.
+func OptionalAuth(
+	keys KeySet, ttl time.Duration, revoked RevocationList, apiKeys APIKeyVerifier, scopes ...string,
+) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
 			return
 		}
 
-		userID, err := validateToken(parts[1], secret)
-		if err != nil {
-			response.FailAbort(c, http.StatusUnauthorized, ErrInvalidToken)
+		if authenticate(c, keys, revoked, apiKeys, scopes) {
+			c.Next()
+		}
+	}
+}
+
+// OptionalAPIKey returns a Gin middleware for public routes that validates
+// "ApiKey <key>" headers like Auth, so that integrations are held to their
+// scopes there too. Other requests pass through as before.
+func OptionalAPIKey(apiKeys APIKeyVerifier, scopes ...string) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		key, ok := strings.CutPrefix(c.GetHeader("Authorization"), "ApiKey ")
+		if !ok {
+			c.Next()
+			return
+		}
+
+		if authenticateAPIKey(c, key, apiKeys, scopes) {
+			c.Next()
+		}
+	}
+}
+
+// IfAuthenticated wraps a middleware so that it only runs for requests
+// authenticated by OptionalAuth; anonymous requests skip it.
+func IfAuthenticated(h ginext.HandlerFunc) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("userID"); !ok {
+			c.Next()
+			return
+		}
+
+		h(c)
+	}
+}
+
+// IsAPIKeyRequest reports whether the request was authenticated with an API key.
+func IsAPIKeyRequest(c *gin.Context) bool {
+	_, ok := c.Get("apiKeyID")
+	return ok
+}
+
+// authenticate validates the token or API key in the "Authorization" header
+// and sets its values in the Gin context. Otherwise, it aborts the request and returns false.
+func authenticate(c *gin.Context, keys KeySet, revoked RevocationList, apiKeys APIKeyVerifier, scopes []string) bool {
+	parts := strings.Split(c.GetHeader("Authorization"), " ") // Bearer <token> or ApiKey <key>
+	if len(parts) != 2 {
+		response.FailAbort(c, http.StatusUnauthorized, ErrInvalidTokenFormat)
+		return false
+	}
+
+	if parts[0] == "ApiKey" {
+		return authenticateAPIKey(c, parts[1], apiKeys, scopes)
+	}
+
+	if parts[0] != "Bearer" {
+		response.FailAbort(c, http.StatusUnauthorized, ErrInvalidTokenFormat)
+		return false
+	}
+
+	cl, err := validateToken(parts[1], keys)
+	if err != nil {
+		response.FailAbort(c, http.StatusUnauthorized, ErrInvalidToken)
+		return false
+	}
+
+	isRevoked, err := revoked.IsAccessTokenRevoked(c.Request.Context(), cl.JTI)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to check token revocation")
+		response.FailAbort(c, http.StatusInternalServerError, errors.New("internal server error"))
+		return false
+	}
+	if isRevoked {
+		response.FailAbort(c, http.StatusUnauthorized, ErrRevokedToken)
+		return false
+	}
+
+	c.Set("userID", cl.UserID)
+	c.Set("role", cl.Role)
+	c.Set("jti", cl.JTI)
+	c.Set("mfa", cl.MFA)
+
+	return true
+}
+
+// authenticateAPIKey validates an API key and checks that it has the route's
+// scopes. Otherwise, it aborts the request and returns false.
+func authenticateAPIKey(c *gin.Context, key string, apiKeys APIKeyVerifier, scopes []string) bool {
+	k, err := apiKeys.VerifyAPIKey(c.Request.Context(), key, c.ClientIP())
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to verify api key")
+		response.FailAbort(c, http.StatusInternalServerError, errors.New("internal server error"))
+		return false
+	}
+	if k == nil {
+		response.FailAbort(c, http.StatusUnauthorized, ErrInvalidAPIKey)
+		return false
+	}
+
+	if len(scopes) == 0 {
+		response.FailAbort(c, http.StatusForbidden, ErrAPIKeyNotAllowed)
+		return false
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(k.Scopes, scope) {
+			response.FailAbort(c, http.StatusForbidden, ErrInsufficientScope)
+			return false
+		}
+	}
+
+	c.Set("userID", k.UserID)
+	c.Set("role", k.OwnerRole)
+	c.Set("apiKeyID", k.ID)
+	c.Set("scopes", k.Scopes)
+
+	return true
+}
+
+// RequireRole returns a Gin middleware that only lets through users whose role,
+// as set by Auth, is one of the given roles. Otherwise, it aborts with 403 Forbidden.
+func RequireRole(roles ...string) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, r := range roles {
+			if role == r {
+				c.Next()
+				return
+			}
+		}
+
+		response.FailAbort(c, http.StatusForbidden, ErrForbidden)
+	}
+}
+
+// RequireMFA returns a Gin middleware that aborts the request with 403 Forbidden
+// if the user's role, as set by Auth, is one of the given roles and the login
+// was not completed with a second factor. It must run after Auth.
+// API keys pass: they are created in sessions that passed this check.
+func RequireMFA(roles ...string) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		if slices.Contains(roles, c.GetString("role")) && !c.GetBool("mfa") && !IsAPIKeyRequest(c) {
+			response.FailAbort(c, http.StatusForbidden, ErrMFARequired)
 			return
 		}
 
-		c.Set("userID", userID)
 		c.Next()
 	}
 }
 
-// validateToken verifies a JWT token and returns the claims.
-func validateToken(tokenStr string, secret string) (uuid.UUID, error) {
-	// Parse the token.
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method.
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, ErrInvalidToken
+// RequireVerifiedEmail returns a Gin middleware that aborts the request with
+// 403 Forbidden unless the authenticated user's email address is verified.
+// It must run after Auth.
+func RequireVerifiedEmail(verifier EmailVerifier) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("userID")
+		if !ok {
+			response.FailAbort(c, http.StatusUnauthorized, ErrNoToken)
+			return
+		}
+
+		verified, err := verifier.IsEmailVerified(c.Request.Context(), userID.(uuid.UUID))
+		if err != nil {
+			zlog.Logger.Error().Err(err).Msg("failed to check email verification")
+			response.FailAbort(c, http.StatusInternalServerError, errors.New("internal server error"))
+			return
+		}
+		if !verified {
+			response.FailAbort(c, http.StatusForbidden, ErrEmailNotVerified)
+			return
 		}
 
-		return []byte(secret), nil
-	})
+		c.Next()
+	}
+}
+
+// validateToken verifies a JWT token with the key named by its kid header and returns the claims.
+func validateToken(tokenStr string, keys KeySet) (*claims, error) {
+	// Parse the token; the key set checks the signing method against the key.
+	token, err := jwt.Parse(tokenStr, keys.Keyfunc)
 	if err != nil {
 		if errors.Is(err, jwt.ErrTokenExpired) {
-			return uuid.Nil, ErrExpiredToken
+			return nil, ErrExpiredToken
 		}
 
-		return uuid.Nil, err
+		return nil, err
 	}
 
-	claims, ok := token.Claims.(jwt.MapClaims)
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
 	if !ok || !token.Valid {
-		return uuid.Nil, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
-	userIDStr, ok := claims["user_id"].(string)
+	userIDStr, ok := mapClaims["user_id"].(string)
 	if !ok {
-		return uuid.Nil, ErrInvalidToken
+		return nil, ErrInvalidToken
 	}
 
 	userID, err := uuid.Parse(userIDStr)
 	if err != nil {
-		return uuid.Nil, ErrInvalidToken
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens without a jti cannot be revoked, so they are not accepted.
+	jtiStr, ok := mapClaims["jti"].(string)
+	if !ok {
+		return nil, ErrInvalidToken
 	}
 
-	return userID, nil
+	jti, err := uuid.Parse(jtiStr)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	// Tokens issued before roles were introduced carry no role claim.
+	role, _ := mapClaims["role"].(string)
+	if role == "" {
+		role = model.RoleUser
+	}
+
+	// Tokens issued before two-factor authentication carry no mfa claim.
+	mfa, _ := mapClaims["mfa"].(bool)
+
+	return &claims{UserID: userID, Role: role, JTI: jti, MFA: mfa}, nil
 }
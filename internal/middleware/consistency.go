@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/wb-go/wbf/ginext"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+)
+
+// readMasterCookie holds the time, in Unix milliseconds, until which the
+// client's reads go to the master.
+const readMasterCookie = "eb_read_master_until"
+
+// ReadYourWrites returns a Gin middleware that gives clients read-after-write
+// consistency when reads are served by replicas.
+//
+// A request that may write (any method other than GET, HEAD and OPTIONS) reads
+// from the master and sets a cookie that sends the client's reads to the
+// master for the next window, so a user who just booked does not see a
+// replica's stale seat count. If window is zero, the middleware does nothing.
+func ReadYourWrites(window time.Duration) ginext.HandlerFunc {
+	return func(c *gin.Context) {
+		if window <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			v, err := c.Cookie(readMasterCookie)
+			if err != nil {
+				break
+			}
+
+			until, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || now.UnixMilli() >= until {
+				break
+			}
+
+			c.Request = c.Request.WithContext(database.WithMaster(c.Request.Context()))
+		default:
+			c.Request = c.Request.WithContext(database.WithMaster(c.Request.Context()))
+
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     readMasterCookie,
+				Value:    strconv.FormatInt(now.Add(window).UnixMilli(), 10),
+				Path:     "/",
+				Expires:  now.Add(window),
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		c.Next()
+	}
+}
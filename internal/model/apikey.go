@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// API key scopes.
+const (
+	ScopeEventsRead    = "events:read"    // list and view events
+	ScopeBookingsWrite = "bookings:write" // book, confirm and cancel seats
+	ScopeAttendeesRead = "attendees:read" // list the attendees of an event
+)
+
+// APIKeyScopes lists all API key scopes.
+var APIKeyScopes = []string{ScopeEventsRead, ScopeBookingsWrite, ScopeAttendeesRead}
+
+// APIKey is a key that lets an integration call the API on behalf of the
+// organizer who created it, limited to its scopes. Only the key's hash is kept.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // start of the key, to tell keys apart
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	OwnerRole string `json:"-"` // role of the user the key belongs to, loaded when it authenticates
+}
+
+// NewAPIKey is a newly created API key together with the key itself,
+// which is returned only once.
+type NewAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
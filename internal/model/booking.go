@@ -12,7 +12,26 @@ type Booking struct {
 	EventID   uuid.UUID `json:"event_id"`
 	UserID    uuid.UUID `json:"user_id"`
 	Status    string    `json:"status"`
+	Guest     bool      `json:"guest"` // made through guest checkout
 	ExpiresAt time.Time `json:"expires_at"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
+
+// GuestBooking is a booking made through a guest booking link, with the
+// tokens of the guest's session.
+type GuestBooking struct {
+	*LoginResult
+
+	BookingID uuid.UUID `json:"booking_id"`
+}
+
+// Attendee is a user holding an active (pending or confirmed) booking for an event.
+type Attendee struct {
+	BookingID uuid.UUID `json:"booking_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Email     string    `json:"email"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	BookedAt  time.Time `json:"booked_at"`
+}
@@ -6,6 +6,17 @@ import (
 	"github.com/google/uuid"
 )
 
+// Seat allocation strategies.
+const (
+	// SeatStrategyCounter books seats by decrementing events.available_seats,
+	// so all bookings of an event serialize on its row.
+	SeatStrategyCounter = "counter"
+
+	// SeatStrategySlots books seats by claiming pre-created seat_slots rows
+	// with SKIP LOCKED, so concurrent bookings of a hot event do not wait on each other.
+	SeatStrategySlots = "slots"
+)
+
 // Event represents an event that users can book seats for.
 type Event struct {
 	ID             uuid.UUID     `json:"id"`
@@ -14,6 +25,17 @@ type Event struct {
 	TotalSeats     int           `json:"total_seats"`
 	AvailableSeats int           `json:"available_seats"`
 	BookingTTL     time.Duration `json:"booking_ttl"`
+	SeatStrategy   string        `json:"seat_strategy"`
 	CreatedAt      time.Time     `json:"created_at"`
 	UpdatedAt      time.Time     `json:"updated_at"`
+
+	OrganizerID *uuid.UUID `json:"organizer_id,omitempty"` // the user who created the event; nil for older events
+}
+
+// Actor is the caller managing an event or a booking: a user with a token,
+// or an integration with an API key, acting for the key's owner.
+type Actor struct {
+	UserID uuid.UUID
+	Role   string
+	APIKey bool // the request was made with an API key
 }
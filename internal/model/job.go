@@ -0,0 +1,47 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Job run triggers.
+const (
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+)
+
+// Job run statuses.
+const (
+	JobRunStatusRunning   = "running"
+	JobRunStatusSucceeded = "succeeded"
+	JobRunStatusFailed    = "failed"
+)
+
+// Job describes a scheduled background job.
+type Job struct {
+	Name     string     `json:"name"`
+	Schedule string     `json:"schedule"`
+	Enabled  bool       `json:"enabled"`
+	Paused   bool       `json:"paused"`
+	Timeout  string     `json:"timeout,omitempty"`
+	Retries  int        `json:"retries"`
+	NextRun  *time.Time `json:"next_run,omitempty"`
+	PrevRun  *time.Time `json:"prev_run,omitempty"`
+}
+
+// JobRun represents a single execution of a background job.
+type JobRun struct {
+	ID             uuid.UUID  `json:"id"`
+	JobName        string     `json:"job_name"`
+	Instance       string     `json:"instance"`
+	Trigger        string     `json:"trigger"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ItemsProcessed int        `json:"items_processed"`
+	Error          string     `json:"error,omitempty"`
+	StartedAt      time.Time  `json:"started_at"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"`
+	DurationMS     *int64     `json:"duration_ms,omitempty"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Login failure reasons.
+const (
+	LoginFailureInvalidCredentials = "invalid_credentials"
+	LoginFailureInvalidMFACode     = "invalid_mfa_code"
+	LoginFailureThrottled          = "throttled" // attempted before the progressive delay elapsed
+	LoginFailureLocked             = "locked"    // attempted while the account or IP was locked out
+)
+
+// LoginFailure is an audit record of a failed login attempt.
+type LoginFailure struct {
+	ID        uuid.UUID  `json:"id"`
+	Email     string     `json:"email"`
+	UserID    *uuid.UUID `json:"user_id,omitempty"` // nil if no user has the email
+	IP        string     `json:"ip"`
+	Reason    string     `json:"reason"`
+	CreatedAt time.Time  `json:"created_at"`
+}
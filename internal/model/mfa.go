@@ -0,0 +1,36 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MFA holds a user's TOTP second factor.
+type MFA struct {
+	UserID      uuid.UUID
+	Secret      string
+	ConfirmedAt *time.Time // nil until enrollment is verified with a code
+	LastStep    *int64     // last TOTP time step used
+}
+
+// Enabled reports whether the second factor is verified and required at login.
+func (m *MFA) Enabled() bool {
+	return m != nil && m.ConfirmedAt != nil
+}
+
+// MFAEnrollment is returned when a user starts TOTP enrollment.
+type MFAEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"` // otpauth:// URI to show as a QR code
+}
+
+// LoginResult is the outcome of a password login: either tokens or, for users
+// with two-factor authentication, a challenge to complete with a code.
+type LoginResult struct {
+	*Tokens
+
+	MFARequired        bool   `json:"mfa_required,omitempty"`
+	ChallengeToken     string `json:"challenge_token,omitempty"`
+	ChallengeExpiresIn int64  `json:"challenge_expires_in,omitempty"` // seconds
+}
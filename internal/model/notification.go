@@ -0,0 +1,12 @@
+package model
+
+import "github.com/google/uuid"
+
+// NotificationPreferences holds how a user wants to receive notifications.
+type NotificationPreferences struct {
+	UserID             uuid.UUID `json:"-"`
+	Channels           []string  `json:"channels"`
+	WebhookURL         string    `json:"webhook_url"`
+	TelegramChatID     string    `json:"telegram_chat_id"`
+	OptOutNonEssential bool      `json:"opt_out_non_essential"`
+}
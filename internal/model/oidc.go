@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OIDCState is a started OpenID Connect login awaiting the provider's response.
+type OIDCState struct {
+	StateHash    string
+	Nonce        string
+	CodeVerifier string // PKCE secret, sent with the authorization code
+	ExpiresAt    time.Time
+}
+
+// UserIdentity links a user to an account at an OpenID Connect provider.
+type UserIdentity struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
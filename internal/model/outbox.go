@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Outbox message statuses.
+const (
+	OutboxStatusPending = "pending"
+	OutboxStatusSent    = "sent"
+	OutboxStatusDead    = "dead"
+)
+
+// OutboxMessage represents a notification waiting to be delivered.
+// It is written in the same transaction as the state change it reports.
+type OutboxMessage struct {
+	ID                uuid.UUID       `json:"id"`
+	UserID            uuid.UUID       `json:"user_id"`
+	Recipient         string          `json:"recipient"`
+	Template          string          `json:"template"`
+	Locale            string          `json:"locale"`
+	Payload           json.RawMessage `json:"payload"`
+	Status            string          `json:"status"`
+	Attempts          int             `json:"attempts"`
+	DeliveredChannels []string        `json:"delivered_channels"`
+	LastError         string          `json:"last_error,omitempty"`
+	NextAttemptAt     time.Time       `json:"next_attempt_at"`
+	SentAt            *time.Time      `json:"sent_at,omitempty"`
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+}
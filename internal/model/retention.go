@@ -0,0 +1,13 @@
+package model
+
+import (
+	"time"
+)
+
+// RetentionReport summarizes the data moved out of the hot tables.
+type RetentionReport struct {
+	ArchivedEvents   int64      `json:"archived_events"`
+	ArchivedBookings int64      `json:"archived_bookings"`
+	ArchiveBytes     int64      `json:"archive_bytes"` // on-disk size of the archive tables, indexes included
+	LastArchivedAt   *time.Time `json:"last_archived_at,omitempty"`
+}
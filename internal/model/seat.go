@@ -0,0 +1,17 @@
+package model
+
+import (
+	"github.com/google/uuid"
+)
+
+// SeatDrift describes an event whose available_seats counter does not match
+// its total seats minus its active (pending or confirmed) bookings.
+type SeatDrift struct {
+	EventID        uuid.UUID `json:"event_id"`
+	EventTitle     string    `json:"event_title"`
+	TotalSeats     int       `json:"total_seats"`
+	AvailableSeats int       `json:"available_seats"`
+	ActiveBookings int       `json:"active_bookings"`
+	ExpectedSeats  int       `json:"expected_seats"` // negative if the event is oversold
+	Drift          int       `json:"drift"`          // available_seats minus expected_seats
+}
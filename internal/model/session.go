@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tokens is the pair of tokens issued on login and refresh.
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// RefreshToken is a stored refresh token. Only the token's hash is kept.
+//
+// Tokens issued from the same login share a family: each refresh replaces
+// the presented token with a new one in the same family.
+type RefreshToken struct {
+	ID              uuid.UUID
+	UserID          uuid.UUID
+	FamilyID        uuid.UUID
+	TokenHash       string
+	AccessJTI       uuid.UUID // jti of the access token issued with this refresh token
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	RevokedAt       *time.Time
+	ReplacedBy      *uuid.UUID
+	MFA             bool // the session was completed with a second factor
+}
+
+// User token purposes.
+const (
+	TokenPurposePasswordReset = "password_reset"
+	TokenPurposeMFAChallenge  = "mfa_challenge"
+	TokenPurposeMagicLink     = "magic_link"
+)
+
+// UserToken is a single-use token emailed to a user, e.g. in a password reset
+// link. Only the token's hash is kept.
+type UserToken struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	Purpose   string
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
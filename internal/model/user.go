@@ -6,11 +6,44 @@ import (
 	"github.com/google/uuid"
 )
 
+// User roles.
+const (
+	RoleUser      = "user"
+	RoleOrganizer = "organizer"
+	RoleAdmin     = "admin"
+)
+
+// Defaults assigned to users who did not choose a locale or time zone.
+const (
+	DefaultLocale   = "en"
+	DefaultTimezone = "UTC"
+)
+
 // User represents a registered user of the EventBooker system.
 type User struct {
 	ID        uuid.UUID `json:"id"`
 	Email     string    `json:"email"`
-	Password  string    `json:"password_hash"`
+	Password  string    `json:"-"` // bcrypt hash, never serialized
 	Name      string    `json:"name"`
+	Locale    string    `json:"locale"`
+	Timezone  string    `json:"timezone"`
+	Role      string    `json:"role"`
 	CreatedAt time.Time `json:"created_at"`
+
+	VerifiedAt   *time.Time `json:"verified_at,omitempty"`   // nil until the email address is verified
+	PendingEmail *string    `json:"pending_email,omitempty"` // new address awaiting confirmation, if any
+}
+
+// Profile is the current user's account with their notification preferences.
+type Profile struct {
+	User                    *User                    `json:"user"`
+	NotificationPreferences *NotificationPreferences `json:"notification_preferences"`
+}
+
+// ProfileUpdate is a partial update of the current user's profile. Nil fields are left unchanged.
+type ProfileUpdate struct {
+	Name                    *string
+	Locale                  *string
+	Timezone                *string
+	NotificationPreferences *NotificationPreferences
 }
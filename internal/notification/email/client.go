@@ -0,0 +1,49 @@
+// Package email provides an SMTP client for sending multi-part notification emails.
+package email
+
+import (
+	"context"
+	"errors"
+
+	"gopkg.in/mail.v2"
+
+	"github.com/aliskhannn/event-booker/internal/notification"
+)
+
+var ErrNoEmail = errors.New("recipient has no email address")
+
+// Client represents an email client used to send notifications via SMTP.
+type Client struct {
+	dialer *mail.Dialer // smtp dialer
+	from   string       // sender email address
+}
+
+// NewClient creates a new Client instance with the given SMTP configuration.
+func NewClient(smtpHost string, smtpPort int, username, password, from string) *Client {
+	return &Client{
+		dialer: mail.NewDialer(smtpHost, smtpPort, username, password),
+		from:   from,
+	}
+}
+
+// Send sends a rendered notification to the recipient's email address.
+//
+// The text part is always sent; the HTML part is attached as an alternative if present.
+func (c *Client) Send(_ context.Context, to notification.Recipient, msg *notification.Message) error {
+	if to.Email == "" {
+		return ErrNoEmail
+	}
+
+	message := mail.NewMessage()
+
+	message.SetHeader("From", c.from)
+	message.SetHeader("To", to.Email)
+	message.SetHeader("Subject", msg.Subject)
+
+	message.SetBody("text/plain", msg.Text)
+	if msg.HTML != "" {
+		message.AddAlternative("text/html", msg.HTML)
+	}
+
+	return c.dialer.DialAndSend(message)
+}
@@ -0,0 +1,27 @@
+// Package notification renders user-facing notifications from embedded,
+// localized templates.
+package notification
+
+import "github.com/google/uuid"
+
+// Notification channels.
+const (
+	ChannelEmail    = "email"
+	ChannelWebhook  = "webhook"
+	ChannelTelegram = "telegram"
+)
+
+// Message is a rendered notification ready to be delivered.
+type Message struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html"`
+}
+
+// Recipient holds the addresses of a user on every notification channel.
+type Recipient struct {
+	UserID         uuid.UUID
+	Email          string
+	WebhookURL     string
+	TelegramChatID string
+}
@@ -0,0 +1,346 @@
+package notification
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// DefaultLocale is used when a template has no variant for the requested locale.
+const DefaultLocale = "en"
+
+// Notification template names.
+const (
+	TemplateBookingExpired    = "booking_expired"
+	TemplateEmailChange       = "email_change"
+	TemplateEmailChangeNotice = "email_change_notice"
+	TemplateEventReminder     = "event_reminder"
+	TemplateGuestBooking      = "guest_booking"
+	TemplateHoldExpiring      = "hold_expiring"
+	TemplateMagicLink         = "magic_link"
+	TemplatePasswordReset     = "password_reset"
+	TemplateVerifyEmail       = "verify_email"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+//go:embed templates
+var templatesFS embed.FS
+
+// definition describes a notification template.
+type definition struct {
+	essential bool // essential notifications ignore the user's opt-out
+	sample    any  // data used to preview the template
+}
+
+// definitions lists all known notification templates.
+var definitions = map[string]definition{
+	TemplateBookingExpired: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":   "Jane Doe",
+			"EventTitle": "Go Meetup",
+			"EventDate":  time.Date(2025, time.October, 1, 19, 0, 0, 0, time.UTC),
+		},
+	},
+	TemplateEmailChange: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":   "Jane Doe",
+			"ConfirmURL": "http://localhost:3000/verify-email?token=sample-token",
+			"ExpiresAt":  time.Date(2025, time.October, 4, 12, 30, 0, 0, time.UTC),
+			"Timezone":   "Europe/Moscow",
+		},
+	},
+	TemplateEmailChangeNotice: {
+		essential: true,
+		sample: map[string]any{
+			"UserName": "Jane Doe",
+			"NewEmail": "jane.doe@example.org",
+		},
+	},
+	TemplateEventReminder: {
+		essential: false,
+		sample: map[string]any{
+			"UserName":   "Jane Doe",
+			"EventTitle": "Go Meetup",
+			"EventDate":  time.Date(2025, time.October, 1, 19, 0, 0, 0, time.UTC),
+			"Timezone":   "Europe/Moscow",
+		},
+	},
+	TemplateGuestBooking: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":   "Jane Doe",
+			"EventTitle": "Go Meetup",
+			"ExpiresAt":  time.Date(2025, time.October, 1, 12, 30, 0, 0, time.UTC),
+			"Timezone":   "Europe/Moscow",
+			"ConfirmURL": "http://localhost:3000/guest-booking?event=00000000-0000-0000-0000-000000000000&token=sample-token",
+		},
+	},
+	TemplateHoldExpiring: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":   "Jane Doe",
+			"EventTitle": "Go Meetup",
+			"ExpiresAt":  time.Date(2025, time.October, 1, 12, 30, 0, 0, time.UTC),
+			"Timezone":   "Europe/Moscow",
+			"ConfirmURL": "http://localhost:3000/events/00000000-0000-0000-0000-000000000000?booking=00000000-0000-0000-0000-000000000000",
+		},
+	},
+	TemplateMagicLink: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":  "Jane Doe",
+			"LoginURL":  "http://localhost:3000/magic-link?token=sample-token",
+			"ExpiresAt": time.Date(2025, time.October, 1, 12, 30, 0, 0, time.UTC),
+			"Timezone":  "Europe/Moscow",
+		},
+	},
+	TemplatePasswordReset: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":  "Jane Doe",
+			"ResetURL":  "http://localhost:3000/reset-password?token=sample-token",
+			"ExpiresAt": time.Date(2025, time.October, 1, 12, 30, 0, 0, time.UTC),
+			"Timezone":  "Europe/Moscow",
+		},
+	},
+	TemplateVerifyEmail: {
+		essential: true,
+		sample: map[string]any{
+			"UserName":  "Jane Doe",
+			"VerifyURL": "http://localhost:3000/verify-email?token=sample-token",
+			"ExpiresAt": time.Date(2025, time.October, 4, 12, 30, 0, 0, time.UTC),
+			"Timezone":  "Europe/Moscow",
+		},
+	},
+}
+
+// IsEssential reports whether the named template is an essential notification
+// that is delivered even to users who opted out of non-essential messages.
+// Unknown templates are treated as essential.
+func IsEssential(name string) bool {
+	d, ok := definitions[name]
+	return !ok || d.essential
+}
+
+// funcs are the helper functions available in all templates.
+var funcs = map[string]any{
+	"date":   formatDate,
+	"inZone": inZone,
+}
+
+// variant holds the parsed parts of a template for a single locale.
+type variant struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Renderer renders notification templates into multi-part messages.
+type Renderer struct {
+	variants map[string]map[string]*variant // template name -> locale -> variant
+}
+
+// NewRenderer parses all embedded templates.
+//
+// Templates are stored as templates/<locale>/<name>.{subject,txt,html}.tmpl.
+// Every template must have a variant for DefaultLocale.
+func NewRenderer() (*Renderer, error) {
+	r := &Renderer{variants: make(map[string]map[string]*variant)}
+
+	locales, err := fs.ReadDir(templatesFS, "templates")
+	if err != nil {
+		return nil, fmt.Errorf("read templates: %w", err)
+	}
+
+	for _, l := range locales {
+		if !l.IsDir() {
+			continue
+		}
+
+		for name := range definitions {
+			v, err := parseVariant(l.Name(), name)
+			if err != nil {
+				return nil, fmt.Errorf("parse template %s/%s: %w", l.Name(), name, err)
+			}
+			if v == nil {
+				continue
+			}
+
+			if r.variants[name] == nil {
+				r.variants[name] = make(map[string]*variant)
+			}
+			r.variants[name][l.Name()] = v
+		}
+	}
+
+	for name := range definitions {
+		if _, ok := r.variants[name][DefaultLocale]; !ok {
+			return nil, fmt.Errorf("template %s has no %s variant", name, DefaultLocale)
+		}
+	}
+
+	return r, nil
+}
+
+// Render renders the named template for the given locale with data.
+//
+// If there is no variant for the locale, its base language and then
+// DefaultLocale are tried.
+func (r *Renderer) Render(name, locale string, data any) (*Message, error) {
+	v, err := r.lookup(name, locale)
+	if err != nil {
+		return nil, err
+	}
+
+	var subject, text, html bytes.Buffer
+
+	if err := v.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("render subject: %w", err)
+	}
+
+	if err := v.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("render text: %w", err)
+	}
+
+	if v.html != nil {
+		if err := v.html.Execute(&html, data); err != nil {
+			return nil, fmt.Errorf("render html: %w", err)
+		}
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// Preview renders the named template for the given locale with sample data.
+func (r *Renderer) Preview(name, locale string) (*Message, error) {
+	d, ok := definitions[name]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+
+	return r.Render(name, locale, d.sample)
+}
+
+// Templates returns the names of all templates with the locales available for each.
+func (r *Renderer) Templates() map[string][]string {
+	res := make(map[string][]string, len(r.variants))
+	for name, byLocale := range r.variants {
+		locales := make([]string, 0, len(byLocale))
+		for l := range byLocale {
+			locales = append(locales, l)
+		}
+		sort.Strings(locales)
+		res[name] = locales
+	}
+
+	return res
+}
+
+// lookup finds the best matching variant of a template for the locale.
+func (r *Renderer) lookup(name, locale string) (*variant, error) {
+	byLocale, ok := r.variants[name]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	base, _, _ := strings.Cut(locale, "-")
+
+	for _, l := range []string{locale, base, DefaultLocale} {
+		if v, ok := byLocale[l]; ok {
+			return v, nil
+		}
+	}
+
+	return nil, ErrTemplateNotFound
+}
+
+// parseVariant parses the parts of a template for a locale.
+// It returns nil if the locale has no such template.
+// The HTML part is optional.
+func parseVariant(locale, name string) (*variant, error) {
+	base := path.Join("templates", locale, name)
+
+	if _, err := fs.Stat(templatesFS, base+".subject.tmpl"); err != nil {
+		return nil, nil
+	}
+
+	subject, err := texttemplate.New(name+".subject.tmpl").Funcs(funcs).ParseFS(templatesFS, base+".subject.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := texttemplate.New(name+".txt.tmpl").Funcs(funcs).ParseFS(templatesFS, base+".txt.tmpl")
+	if err != nil {
+		return nil, err
+	}
+
+	v := &variant{subject: subject, text: text}
+
+	if _, err := fs.Stat(templatesFS, base+".html.tmpl"); err == nil {
+		v.html, err = htmltemplate.New(name+".html.tmpl").Funcs(funcs).ParseFS(templatesFS, base+".html.tmpl")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return v, nil
+}
+
+// formatDate formats a time with layout. The time may be a time.Time or an
+// RFC 3339 string, as found in payloads decoded from JSON.
+func formatDate(layout string, v any) (string, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(layout), nil
+}
+
+// inZone converts a time to the named IANA time zone, e.g. "Europe/Moscow".
+// An empty or unknown zone leaves the time in UTC.
+func inZone(zone string, v any) (time.Time, error) {
+	t, err := toTime(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return t.UTC(), nil
+	}
+
+	return t.In(loc), nil
+}
+
+// toTime converts a time.Time or an RFC 3339 string to time.Time.
+func toTime(v any) (time.Time, error) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, nil
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("parse time %q: %w", t, err)
+		}
+		return parsed, nil
+	default:
+		return time.Time{}, fmt.Errorf("unsupported time value %T", v)
+	}
+}
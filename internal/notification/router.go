@@ -0,0 +1,84 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// Channel delivers rendered messages to a recipient over a single medium.
+type Channel interface {
+	// Send sends a rendered notification message to the recipient.
+	Send(ctx context.Context, to Recipient, msg *Message) error
+}
+
+// Router delivers messages over the channels chosen in a user's preferences.
+type Router struct {
+	channels map[string]Channel
+}
+
+// NewRouter creates a new Router without channels.
+func NewRouter() *Router {
+	return &Router{channels: make(map[string]Channel)}
+}
+
+// Register makes a channel available under the given name.
+func (r *Router) Register(name string, ch Channel) {
+	r.channels[name] = ch
+}
+
+// Send delivers msg, rendered from the named template, over the channels
+// selected in prefs, skipping those listed in delivered.
+//
+// Non-essential messages are dropped for users who opted out of them.
+// Essential messages fall back to email if none of the selected channels is available.
+// It returns the updated list of channels the message was delivered to,
+// so that a retry does not deliver it twice over the same channel.
+func (r *Router) Send(
+	ctx context.Context,
+	prefs *model.NotificationPreferences,
+	to Recipient,
+	template string,
+	msg *Message,
+	delivered []string,
+) ([]string, error) {
+	essential := IsEssential(template)
+	if prefs.OptOutNonEssential && !essential {
+		return delivered, nil
+	}
+
+	channels := make([]string, 0, len(prefs.Channels))
+	for _, name := range prefs.Channels {
+		if _, ok := r.channels[name]; ok {
+			channels = append(channels, name)
+		}
+	}
+	if len(channels) == 0 && essential {
+		channels = append(channels, ChannelEmail)
+	}
+
+	var errs []error
+	for _, name := range channels {
+		if slices.Contains(delivered, name) {
+			continue
+		}
+
+		ch, ok := r.channels[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: channel not configured", name))
+			continue
+		}
+
+		if err := ch.Send(ctx, to, msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+
+		delivered = append(delivered, name)
+	}
+
+	return delivered, errors.Join(errs...)
+}
@@ -0,0 +1,138 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// fakeChannel records the messages sent over it and fails with err if set.
+type fakeChannel struct {
+	sent []*Message
+	err  error
+}
+
+func (c *fakeChannel) Send(_ context.Context, _ Recipient, msg *Message) error {
+	if c.err != nil {
+		return c.err
+	}
+
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+func TestRouterSend(t *testing.T) {
+	errUnavailable := errors.New("unavailable")
+
+	tests := []struct {
+		name          string
+		prefs         model.NotificationPreferences
+		template      string
+		delivered     []string
+		failing       []string // channels failing to send
+		unregistered  []string // channels not registered with the router
+		wantSent      []string // channels the message is sent over
+		wantDelivered []string
+		wantErr       bool
+	}{
+		{
+			name:          "selected channels",
+			prefs:         model.NotificationPreferences{Channels: []string{ChannelEmail, ChannelWebhook}},
+			template:      TemplateBookingExpired,
+			wantSent:      []string{ChannelEmail, ChannelWebhook},
+			wantDelivered: []string{ChannelEmail, ChannelWebhook},
+		},
+		{
+			name:     "opted out of non-essential",
+			prefs:    model.NotificationPreferences{Channels: []string{ChannelEmail}, OptOutNonEssential: true},
+			template: TemplateEventReminder,
+		},
+		{
+			name:          "opted out still gets essential",
+			prefs:         model.NotificationPreferences{Channels: []string{ChannelTelegram}, OptOutNonEssential: true},
+			template:      TemplateBookingExpired,
+			wantSent:      []string{ChannelTelegram},
+			wantDelivered: []string{ChannelTelegram},
+		},
+		{
+			name:          "essential falls back to email",
+			prefs:         model.NotificationPreferences{Channels: []string{ChannelTelegram}},
+			template:      TemplatePasswordReset,
+			unregistered:  []string{ChannelTelegram},
+			wantSent:      []string{ChannelEmail},
+			wantDelivered: []string{ChannelEmail},
+		},
+		{
+			name:         "non-essential does not fall back",
+			prefs:        model.NotificationPreferences{Channels: []string{ChannelTelegram}},
+			template:     TemplateEventReminder,
+			unregistered: []string{ChannelTelegram},
+		},
+		{
+			name:          "skips already delivered",
+			prefs:         model.NotificationPreferences{Channels: []string{ChannelEmail, ChannelWebhook, ChannelTelegram}},
+			template:      TemplateBookingExpired,
+			delivered:     []string{ChannelEmail},
+			wantSent:      []string{ChannelWebhook, ChannelTelegram},
+			wantDelivered: []string{ChannelEmail, ChannelWebhook, ChannelTelegram},
+		},
+		{
+			name:          "failed channel is not delivered",
+			prefs:         model.NotificationPreferences{Channels: []string{ChannelEmail, ChannelWebhook}},
+			template:      TemplateBookingExpired,
+			failing:       []string{ChannelWebhook},
+			wantSent:      []string{ChannelEmail},
+			wantDelivered: []string{ChannelEmail},
+			wantErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouter()
+			channels := make(map[string]*fakeChannel)
+			for _, name := range []string{ChannelEmail, ChannelWebhook, ChannelTelegram} {
+				if slices.Contains(tt.unregistered, name) {
+					continue
+				}
+
+				ch := &fakeChannel{}
+				if slices.Contains(tt.failing, name) {
+					ch.err = errUnavailable
+				}
+				channels[name] = ch
+				r.Register(name, ch)
+			}
+
+			msg := &Message{Subject: "Subject", Text: "Text"}
+			to := Recipient{UserID: uuid.New(), Email: "jane@example.com"}
+
+			delivered, err := r.Send(context.Background(), &tt.prefs, to, tt.template, msg, slices.Clone(tt.delivered))
+			if tt.wantErr {
+				if !errors.Is(err, errUnavailable) {
+					t.Errorf("Send error = %v, want %v", err, errUnavailable)
+				}
+			} else if err != nil {
+				t.Errorf("Send: %v", err)
+			}
+
+			if !slices.Equal(delivered, tt.wantDelivered) {
+				t.Errorf("delivered = %v, want %v", delivered, tt.wantDelivered)
+			}
+			for name, ch := range channels {
+				want := 0
+				if slices.Contains(tt.wantSent, name) {
+					want = 1
+				}
+				if len(ch.sent) != want {
+					t.Errorf("%s sent %d messages, want %d", name, len(ch.sent), want)
+				}
+			}
+		})
+	}
+}
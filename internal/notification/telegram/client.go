@@ -0,0 +1,90 @@
+// Package telegram delivers notifications as Telegram bot messages.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aliskhannn/event-booker/internal/notification"
+)
+
+var ErrNoChatID = errors.New("recipient has no telegram chat id")
+
+// sendMessageRequest is the body of the Bot API sendMessage method.
+type sendMessageRequest struct {
+	ChatID string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// apiResponse is the common envelope of Bot API responses.
+type apiResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// Client sends messages through the Telegram Bot API.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	token      string
+}
+
+// NewClient creates a new Telegram client.
+// baseURL is the Bot API root, e.g. "https://api.telegram.org".
+func NewClient(baseURL, token string, timeout time.Duration) *Client {
+	return &Client{
+		httpClient: &http.Client{Timeout: timeout},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		token:      token,
+	}
+}
+
+// Send sends the subject and text part of a notification to the recipient's chat.
+func (c *Client) Send(ctx context.Context, to notification.Recipient, msg *notification.Message) error {
+	if to.TelegramChatID == "" {
+		return ErrNoChatID
+	}
+
+	body, err := json.Marshal(sendMessageRequest{
+		ChatID: to.TelegramChatID,
+		Text:   msg.Subject + "\n\n" + msg.Text,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", c.baseURL, c.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Do not leak the bot token embedded in the URL.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("send message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var res apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return fmt.Errorf("decode response (status %d): %w", resp.StatusCode, err)
+	}
+	if !res.OK {
+		return fmt.Errorf("telegram api error (status %d): %s", resp.StatusCode, res.Description)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/notification"
+)
+
+const testToken = "123456:test-token"
+
+var testMessage = &notification.Message{
+	Subject: "Booking confirmed",
+	Text:    "See you at Go Meetup.",
+}
+
+// newStandIn starts a local Bot API stand-in answering sendMessage with
+// status and the given response, and returns a client using it as base URL.
+// Requests it receives are sent to the returned channel.
+func newStandIn(t *testing.T, status int, res apiResponse) (*Client, <-chan sendMessageRequest) {
+	t.Helper()
+
+	received := make(chan sendMessageRequest, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /bot"+testToken+"/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		var req sendMessageRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+		}
+		received <- req
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(res)
+	})
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	// A trailing slash in the base URL must not break the endpoint.
+	return NewClient(srv.URL+"/", testToken, 5*time.Second), received
+}
+
+func TestSend(t *testing.T) {
+	c, received := newStandIn(t, http.StatusOK, apiResponse{OK: true})
+	to := notification.Recipient{UserID: uuid.New(), TelegramChatID: "42"}
+
+	if err := c.Send(context.Background(), to, testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	want := sendMessageRequest{ChatID: "42", Text: "Booking confirmed\n\nSee you at Go Meetup."}
+	if got := <-received; got != want {
+		t.Errorf("request = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendAPIError(t *testing.T) {
+	c, _ := newStandIn(t, http.StatusBadRequest, apiResponse{OK: false, Description: "Bad Request: chat not found"})
+	to := notification.Recipient{UserID: uuid.New(), TelegramChatID: "42"}
+
+	err := c.Send(context.Background(), to, testMessage)
+	if err == nil {
+		t.Fatal("Send succeeded on ok:false")
+	}
+	if !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("Send error = %v, want the API description", err)
+	}
+}
+
+func TestSendNoChatID(t *testing.T) {
+	c, _ := newStandIn(t, http.StatusOK, apiResponse{OK: true})
+	to := notification.Recipient{UserID: uuid.New()}
+
+	if err := c.Send(context.Background(), to, testMessage); !errors.Is(err, ErrNoChatID) {
+		t.Errorf("Send error = %v, want %v", err, ErrNoChatID)
+	}
+}
+
+// TestSendHidesToken checks that a transport error does not expose the bot
+// token embedded in the request URL.
+func TestSendHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	c := NewClient(srv.URL, testToken, 5*time.Second)
+	to := notification.Recipient{UserID: uuid.New(), TelegramChatID: "42"}
+
+	err := c.Send(context.Background(), to, testMessage)
+	if err == nil {
+		t.Fatal("Send succeeded against a closed server")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("Send error %q contains the bot token", err)
+	}
+}
@@ -0,0 +1,150 @@
+// Package webhook delivers notifications as JSON to user-configured HTTP endpoints.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/notification"
+)
+
+// SignatureHeader carries the hex-encoded HMAC-SHA256 of the request body
+// when a signing secret is configured.
+const SignatureHeader = "X-EventBooker-Signature"
+
+var (
+	ErrNoWebhookURL     = errors.New("recipient has no webhook url")
+	ErrInsecureURL      = errors.New("webhook url must use https")
+	ErrNonPublicAddress = errors.New("webhook address is not public")
+)
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), not routable on the internet.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// payload is the JSON body posted to the webhook.
+type payload struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Subject string    `json:"subject"`
+	Text    string    `json:"text"`
+	HTML    string    `json:"html,omitempty"`
+}
+
+// Client posts notifications to webhooks.
+type Client struct {
+	httpClient *http.Client
+	secret     string
+}
+
+// NewClient creates a new webhook client.
+// If secret is not empty, every request is signed with it.
+//
+// Webhook URLs are chosen by users, so the client only connects to public
+// addresses. The check runs on the address actually dialed, after DNS
+// resolution, so a host that resolves to a public address when saved and to
+// a private one later is still rejected. Redirects are not followed.
+func NewClient(timeout time.Duration, secret string) *Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: publicAddressOnly,
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				Proxy:               nil, // a proxy would be the address checked, not the webhook
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: timeout,
+				MaxIdleConnsPerHost: 2,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		secret: secret,
+	}
+}
+
+// Send posts a rendered notification to the recipient's webhook URL.
+// Any non-2xx response is treated as a failed delivery.
+func (c *Client) Send(ctx context.Context, to notification.Recipient, msg *notification.Message) error {
+	if to.WebhookURL == "" {
+		return ErrNoWebhookURL
+	}
+
+	u, err := url.Parse(to.WebhookURL)
+	if err != nil {
+		return fmt.Errorf("parse webhook url: %w", err)
+	}
+	if u.Scheme != "https" {
+		return ErrInsecureURL
+	}
+
+	body, err := json.Marshal(payload{
+		UserID:  to.UserID,
+		Subject: msg.Subject,
+		Text:    msg.Text,
+		HTML:    msg.HTML,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if c.secret != "" {
+		mac := hmac.New(sha256.New, []byte(c.secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	// Redirects are returned as is and fail here.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// publicAddressOnly is a net.Dialer Control function that refuses to connect
+// to loopback, private, link-local (including cloud metadata endpoints),
+// multicast and other non-public addresses.
+func publicAddressOnly(_, address string, _ syscall.RawConn) error {
+	ap, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, address)
+	}
+
+	addr := ap.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() || sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, addr)
+	}
+
+	return nil
+}
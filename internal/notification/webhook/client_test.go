@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/notification"
+)
+
+const testSecret = "test-secret"
+
+var testMessage = &notification.Message{
+	Subject: "Booking confirmed",
+	Text:    "See you at Go Meetup.",
+	HTML:    "<p>See you at Go Meetup.</p>",
+}
+
+// request is a webhook request received by a stand-in.
+type request struct {
+	signature string
+	body      []byte
+}
+
+// newStandIn starts a local TLS webhook endpoint responding with status and
+// returns a client trusting it. The stand-in listens on a loopback address,
+// so the client uses the stand-in's transport instead of the public-only one.
+func newStandIn(t *testing.T, status int) (*Client, *httptest.Server, <-chan request) {
+	t.Helper()
+
+	received := make(chan request, 1)
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- request{signature: r.Header.Get(SignatureHeader), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(5*time.Second, testSecret)
+	c.httpClient = srv.Client()
+
+	return c, srv, received
+}
+
+func TestSendSigned(t *testing.T) {
+	c, srv, received := newStandIn(t, http.StatusNoContent)
+	to := notification.Recipient{UserID: uuid.New(), WebhookURL: srv.URL + "/hook"}
+
+	if err := c.Send(context.Background(), to, testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-received
+
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write(req.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.signature != want {
+		t.Errorf("signature = %q, want %q", req.signature, want)
+	}
+
+	var got payload
+	if err := json.Unmarshal(req.body, &got); err != nil {
+		t.Fatalf("decode payload: %v", err)
+	}
+	want := payload{UserID: to.UserID, Subject: testMessage.Subject, Text: testMessage.Text, HTML: testMessage.HTML}
+	if got != want {
+		t.Errorf("payload = %+v, want %+v", got, want)
+	}
+}
+
+func TestSendUnsigned(t *testing.T) {
+	c, srv, received := newStandIn(t, http.StatusOK)
+	c.secret = ""
+
+	to := notification.Recipient{UserID: uuid.New(), WebhookURL: srv.URL}
+	if err := c.Send(context.Background(), to, testMessage); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if req := <-received; req.signature != "" {
+		t.Errorf("signature = %q, want none without a secret", req.signature)
+	}
+}
+
+func TestSendNon2xx(t *testing.T) {
+	for _, status := range []int{http.StatusFound, http.StatusBadRequest, http.StatusInternalServerError} {
+		t.Run(http.StatusText(status), func(t *testing.T) {
+			c, srv, _ := newStandIn(t, status)
+			to := notification.Recipient{UserID: uuid.New(), WebhookURL: srv.URL}
+
+			if err := c.Send(context.Background(), to, testMessage); err == nil {
+				t.Fatalf("Send succeeded on status %d", status)
+			}
+		})
+	}
+}
+
+func TestSendInvalidURL(t *testing.T) {
+	c := NewClient(5*time.Second, testSecret)
+
+	tests := []struct {
+		name    string
+		url     string
+		wantErr error
+	}{
+		{name: "empty", url: "", wantErr: ErrNoWebhookURL},
+		{name: "http", url: "http://example.com/hook", wantErr: ErrInsecureURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			to := notification.Recipient{UserID: uuid.New(), WebhookURL: tt.url}
+			if err := c.Send(context.Background(), to, testMessage); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Send error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSendNonPublicAddress sends to a local stand-in with the client's own
+// transport, which must refuse to connect to it.
+func TestSendNonPublicAddress(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("request reached a loopback address")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient(5*time.Second, testSecret)
+	to := notification.Recipient{UserID: uuid.New(), WebhookURL: srv.URL}
+
+	if err := c.Send(context.Background(), to, testMessage); !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("Send error = %v, want %v", err, ErrNonPublicAddress)
+	}
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.216.34:443", public: true},
+		{address: "[2606:2800:220:1::1]:443", public: true},
+		{address: "127.0.0.1:443"},
+		{address: "[::1]:443"},
+		{address: "10.0.0.1:443"},
+		{address: "172.16.0.1:443"},
+		{address: "192.168.1.1:443"},
+		{address: "169.254.169.254:80"},
+		{address: "100.64.0.1:443"},
+		{address: "0.0.0.0:443"},
+		{address: "224.0.0.1:443"},
+		{address: "[fd00::1]:443"},
+		{address: "[fe80::1]:443"},
+		{address: "[::ffff:127.0.0.1]:443"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			err := publicAddressOnly("tcp", tt.address, nil)
+			if tt.public && err != nil {
+				t.Errorf("publicAddressOnly(%q) = %v, want nil", tt.address, err)
+			}
+			if !tt.public && !errors.Is(err, ErrNonPublicAddress) {
+				t.Errorf("publicAddressOnly(%q) = %v, want %v", tt.address, err, ErrNonPublicAddress)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+)
+
+const (
+	testClientID = "event-booker"
+	testCode     = "test-code"
+	testVerifier = "test-verifier"
+	testNonce    = "test-nonce"
+	testKeyID    = "test-key"
+)
+
+// mockProvider is a local OpenID Connect provider serving discovery, its
+// JWKS and a token endpoint. The token endpoint returns idToken for testCode
+// redeemed with testVerifier by testClientID.
+type mockProvider struct {
+	*httptest.Server
+
+	key     *ecdsa.PrivateKey
+	issuer  string // issuer in the metadata; the server URL if empty
+	idToken string
+}
+
+// newMockProvider starts a mock provider, stopped when the test ends.
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	m := &mockProvider{key: newKey(t)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		issuer := m.issuer
+		if issuer == "" {
+			issuer = m.URL
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.URL + "/authorize",
+			"token_endpoint":         m.URL + "/token",
+			"jwks_uri":               m.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, _ *http.Request) {
+		pub := m.key.PublicKey
+		writeJSON(w, http.StatusOK, map[string]any{
+			"keys": []map[string]string{{
+				"kty": "EC",
+				"kid": testKeyID,
+				"use": "sig",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(pub.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(pub.Y.FillBytes(make([]byte, 32))),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+			return
+		}
+
+		if r.PostForm.Get("grant_type") != "authorization_code" ||
+			r.PostForm.Get("code") != testCode ||
+			r.PostForm.Get("code_verifier") != testVerifier ||
+			r.PostForm.Get("client_id") != testClientID {
+			writeJSON(w, http.StatusBadRequest, map[string]string{
+				"error":             "invalid_grant",
+				"error_description": "unknown code",
+			})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"access_token": "test-access-token",
+			"token_type":   "Bearer",
+			"id_token":     m.idToken,
+		})
+	})
+
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+
+	return m
+}
+
+// provider returns a client of the mock provider.
+func (m *mockProvider) provider() *Provider {
+	return NewProvider(config.OIDC{
+		Enabled:     true,
+		Issuer:      m.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:3000/oidc/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+		Timeout:     5 * time.Second,
+	})
+}
+
+// claims returns the claims of a valid ID token issued by the mock provider.
+func (m *mockProvider) claims() jwt.MapClaims {
+	now := time.Now()
+
+	return jwt.MapClaims{
+		"iss":            m.URL,
+		"sub":            "user-1",
+		"aud":            testClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          testNonce,
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+	}
+}
+
+// newKey generates a P-256 signing key.
+func newKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	return key
+}
+
+// sign signs claims with ES256 under key id kid.
+func sign(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("sign id token: %v", err)
+	}
+
+	return signed
+}
+
+// writeJSON writes v as a JSON response with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	m := newMockProvider(t)
+
+	raw, err := m.provider().AuthCodeURL(context.Background(), "test-state", testNonce, testVerifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse url: %v", err)
+	}
+	if got := u.Scheme + "://" + u.Host + u.Path; got != m.URL+"/authorize" {
+		t.Errorf("endpoint = %q, want %q", got, m.URL+"/authorize")
+	}
+
+	challenge := sha256.Sum256([]byte(testVerifier))
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          "http://localhost:3000/oidc/callback",
+		"scope":                 "openid email profile",
+		"state":                 "test-state",
+		"nonce":                 testNonce,
+		"code_challenge":        base64.RawURLEncoding.EncodeToString(challenge[:]),
+		"code_challenge_method": "S256",
+	}
+	q := u.Query()
+	for name, value := range want {
+		if got := q.Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+	if q.Has("code_verifier") {
+		t.Error("code_verifier must not be sent in the authorization URL")
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	otherKey := newKey(t)
+
+	tests := []struct {
+		name    string
+		code    string
+		idToken func(m *mockProvider) string
+		want    *Identity
+		wantErr error
+	}{
+		{
+			name: "valid",
+			idToken: func(m *mockProvider) string {
+				return sign(t, m.key, testKeyID, m.claims())
+			},
+			want: &Identity{Subject: "user-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"},
+		},
+		{
+			name: "email not verified",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				claims["email_verified"] = false
+				return sign(t, m.key, testKeyID, claims)
+			},
+			want: &Identity{Subject: "user-1", Email: "jane@example.com", EmailVerified: false, Name: "Jane Doe"},
+		},
+		{
+			name: "email verified as string",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				claims["email_verified"] = "true"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			want: &Identity{Subject: "user-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane Doe"},
+		},
+		{
+			name: "wrong nonce",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				claims["nonce"] = "other-nonce"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "wrong audience",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				claims["aud"] = "other-client"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "wrong issuer",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				claims["iss"] = "https://idp.example.com"
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "expired",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				claims["iat"] = time.Now().Add(-time.Hour).Unix()
+				claims["exp"] = time.Now().Add(-10 * time.Minute).Unix()
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "no subject",
+			idToken: func(m *mockProvider) string {
+				claims := m.claims()
+				delete(claims, "sub")
+				return sign(t, m.key, testKeyID, claims)
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "unknown key id",
+			idToken: func(m *mockProvider) string {
+				return sign(t, otherKey, "other-key", m.claims())
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "signed by another key",
+			idToken: func(m *mockProvider) string {
+				return sign(t, otherKey, testKeyID, m.claims())
+			},
+			wantErr: ErrInvalidIDToken,
+		},
+		{
+			name: "wrong code",
+			code: "other-code",
+			idToken: func(m *mockProvider) string {
+				return sign(t, m.key, testKeyID, m.claims())
+			},
+			wantErr: ErrExchangeFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockProvider(t)
+			m.idToken = tt.idToken(m)
+
+			code := testCode
+			if tt.code != "" {
+				code = tt.code
+			}
+
+			identity, err := m.provider().Authenticate(context.Background(), code, testVerifier, testNonce)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Authenticate error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+
+			want := *tt.want
+			want.Issuer = m.URL
+			if *identity != want {
+				t.Errorf("identity = %+v, want %+v", *identity, want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateIssuerMismatch(t *testing.T) {
+	m := newMockProvider(t)
+	m.issuer = "https://idp.example.com"
+	m.idToken = sign(t, m.key, testKeyID, m.claims())
+
+	if _, err := m.provider().Authenticate(context.Background(), testCode, testVerifier, testNonce); err == nil {
+		t.Fatal("Authenticate succeeded with metadata of another issuer")
+	}
+}
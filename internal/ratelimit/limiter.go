@@ -0,0 +1,78 @@
+// Package ratelimit limits how often a client may act, such as requesting a
+// guest booking, using the attempt counters of the login guard as storage.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+var ErrLimited = errors.New("too many requests, try again later")
+
+// LimitedError is returned for actions over the limit. It unwraps to ErrLimited.
+type LimitedError struct {
+	RetryAfter time.Duration // how long to wait before the next action
+}
+
+// Error implements the error interface.
+func (e *LimitedError) Error() string {
+	return ErrLimited.Error()
+}
+
+// Unwrap returns ErrLimited.
+func (e *LimitedError) Unwrap() error {
+	return ErrLimited
+}
+
+// counters defines the interface for counting actions per key.
+// It is implemented by the login repository and by in-memory counters.
+type counters interface {
+	// Hit counts an action for key and returns the number of actions before it,
+	// the previous action's time and this action's time.
+	Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, time.Time, error)
+
+	// Undo takes back a rejected action counted at at and restores the previous action's time.
+	Undo(ctx context.Context, key string, prevAt, at time.Time) error
+}
+
+// Limiter limits the number of actions per key. A key's count starts over
+// once window passes without an allowed action.
+type Limiter struct {
+	counters counters
+	window   time.Duration
+}
+
+// New creates a new limiter counting actions in c.
+func New(c counters, window time.Duration) *Limiter {
+	return &Limiter{
+		counters: c,
+		window:   window,
+	}
+}
+
+// Allow counts an action for key and returns a *LimitedError if limit actions
+// were already counted. Rejected actions are taken back, so that they do not
+// extend the wait. A limit of zero or less allows every action.
+func (l *Limiter) Allow(ctx context.Context, key string, limit int) error {
+	if limit <= 0 {
+		return nil
+	}
+
+	prev, prevAt, at, err := l.counters.Hit(ctx, key, l.window)
+	if err != nil {
+		return fmt.Errorf("count action: %w", err)
+	}
+	if prev < limit {
+		return nil
+	}
+
+	if err := l.counters.Undo(ctx, key, prevAt, at); err != nil {
+		zlog.Logger.Error().Err(err).Str("key", key).Msg("failed to undo rejected action")
+	}
+
+	return &LimitedError{RetryAfter: l.window - at.Sub(prevAt)}
+}
@@ -0,0 +1,152 @@
+package apikey
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// Repository provides methods to interact with the api_keys table.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new API key repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateAPIKey stores a new API key and sets its id and creation time.
+func (r *Repository) CreateAPIKey(ctx context.Context, k *model.APIKey) error {
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at;
+	`
+
+	err := r.db.Master.QueryRowContext(
+		ctx, query, k.UserID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), k.ExpiresAt,
+	).Scan(&k.ID, &k.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+
+	return nil
+}
+
+// GetAPIKeys retrieves all API keys of a user, including revoked and expired ones, newest first.
+func (r *Repository) GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error) {
+	query := `
+		SELECT id, user_id, name, prefix, scopes, expires_at, last_used_at, COALESCE(last_used_ip, ''),
+		       revoked_at, created_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY created_at DESC;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*model.APIKey
+	for rows.Next() {
+		var k model.APIKey
+		err := rows.Scan(
+			&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.ExpiresAt, &k.LastUsedAt, &k.LastUsedIP,
+			&k.RevokedAt, &k.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, &k)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return keys, nil
+}
+
+// GetActiveAPIKey retrieves the unrevoked, unexpired API key with the given
+// hash, together with the role of its owner.
+// Returns ErrAPIKeyNotFound if there is no such key.
+func (r *Repository) GetActiveAPIKey(ctx context.Context, keyHash string) (*model.APIKey, error) {
+	query := `
+		SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.expires_at, k.last_used_at,
+		       COALESCE(k.last_used_ip, ''), k.created_at, u.role
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1 AND k.revoked_at IS NULL AND (k.expires_at IS NULL OR k.expires_at > NOW());
+	`
+
+	// Read from the master, so that a revoked key stops working at once.
+	var k model.APIKey
+	err := r.db.Master.QueryRowContext(ctx, query, keyHash).Scan(
+		&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), &k.ExpiresAt, &k.LastUsedAt,
+		&k.LastUsedIP, &k.CreatedAt, &k.OwnerRole,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAPIKeyNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	return &k, nil
+}
+
+// TouchAPIKey records that an API key was used from ip. The time is only
+// updated if the recorded use is older than since, so that busy keys do not
+// write on every request.
+func (r *Repository) TouchAPIKey(ctx context.Context, keyID uuid.UUID, ip string, since time.Time) error {
+	query := `
+		UPDATE api_keys
+		SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < $3 OR last_used_ip IS DISTINCT FROM $2);
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, keyID, ip, since); err != nil {
+		return fmt.Errorf("failed to record api key use: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes an API key of a user.
+// Returns ErrAPIKeyNotFound if the user has no such unrevoked key.
+func (r *Repository) RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	query := `
+		UPDATE api_keys
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, keyID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if n == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
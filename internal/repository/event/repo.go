@@ -5,16 +5,20 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/wb-go/wbf/dbpg"
+	"github.com/lib/pq"
 
+	"github.com/aliskhannn/event-booker/internal/database"
 	"github.com/aliskhannn/event-booker/internal/model"
+	"github.com/aliskhannn/event-booker/internal/notification"
 )
 
 var (
 	ErrEventNotFound                     = errors.New("event not found")
+	ErrBookingNotFound                   = errors.New("booking not found")
 	ErrNoSeatsAvailable                  = errors.New("no seats available")
 	ErrBookingNotFoundOrAlreadyConfirmed = errors.New("booking not found or already confirmed")
 	ErrBookingNotFoundOrAlreadyCancelled = errors.New("booking not found or already cancelled")
@@ -22,29 +26,40 @@ var (
 
 // Repository provides methods to interact with events table.
 type Repository struct {
-	db *dbpg.DB
+	db *database.DB
 }
 
 // NewRepository creates a new event repository.
-func NewRepository(db *dbpg.DB) *Repository {
+func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
 // CreateEvent adds a new event to the database.
+// For events using the slots strategy, one seat slot is created per seat.
 func (r *Repository) CreateEvent(ctx context.Context, event *model.Event) (uuid.UUID, error) {
 	query := `
-		INSERT INTO events (title, date, total_seats, available_seats, booking_ttl)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id;
+		WITH created AS (
+			INSERT INTO events (title, date, total_seats, available_seats, booking_ttl, seat_strategy, organizer_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING id, total_seats, seat_strategy
+		), slots AS (
+			INSERT INTO seat_slots (event_id, slot_no)
+			SELECT c.id, n
+			FROM created c, generate_series(1, c.total_seats) AS n
+			WHERE c.seat_strategy = 'slots'
+		)
+		SELECT id FROM created;
 	`
 
-	err := r.db.QueryRowContext(
+	err := r.db.Master.QueryRowContext(
 		ctx, query,
 		event.Title,
 		event.Date,
 		event.TotalSeats,
 		event.AvailableSeats,
 		int64(event.BookingTTL.Seconds()),
+		event.SeatStrategy,
+		event.OrganizerID,
 	).Scan(&event.ID)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create event: %w", err)
@@ -82,12 +97,12 @@ func (r *Repository) CreateBooking(ctx context.Context, booking *model.Booking)
 	}
 
 	createBookingQuery := `
-		INSERT INTO bookings (event_id, user_id, expires_at)
-		VALUES ($1, $2, $3)
+		INSERT INTO bookings (event_id, user_id, expires_at, guest)
+		VALUES ($1, $2, $3, $4)
 		RETURNING id, status, created_at, updated_at
 	`
 
-	err = tx.QueryRowContext(ctx, createBookingQuery, booking.EventID, booking.UserID, booking.ExpiresAt).
+	err = tx.QueryRowContext(ctx, createBookingQuery, booking.EventID, booking.UserID, booking.ExpiresAt, booking.Guest).
 		Scan(&booking.ID, &booking.Status, &booking.CreatedAt, &booking.UpdatedAt)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to insert booking: %w", err)
@@ -100,11 +115,84 @@ func (r *Repository) CreateBooking(ctx context.Context, booking *model.Booking)
 	return booking.ID, nil
 }
 
+// slotAttempts bounds how many times CreateSlotBooking tries to claim a slot
+// while free slots remain.
+const slotAttempts = 5
+
+// claimSlotQuery books the first free seat slot of an event. The lock clause
+// is SKIP LOCKED or empty, to wait for a locked slot.
+const claimSlotQuery = `
+	WITH slot AS (
+		SELECT event_id, slot_no
+		FROM seat_slots
+		WHERE event_id = $1 AND booking_id IS NULL
+		LIMIT 1
+		FOR UPDATE %s
+	), created AS (
+		INSERT INTO bookings (event_id, user_id, expires_at, guest)
+		SELECT event_id, $2, $3, $4
+		FROM slot
+		RETURNING id, status, created_at, updated_at
+	), claimed AS (
+		UPDATE seat_slots s
+		SET booking_id = c.id
+		FROM slot, created c
+		WHERE s.event_id = slot.event_id AND s.slot_no = slot.slot_no
+	)
+	SELECT id, status, created_at, updated_at FROM created;
+`
+
+// CreateSlotBooking adds a new booking for an event using the slots strategy.
+// It claims a free seat slot with SKIP LOCKED, so concurrent bookings of the
+// same event take different slots instead of waiting on one row.
+//
+// If every free slot is locked by a booking still in progress, it waits for
+// one of them and tries again, as the other booking may fail and leave its
+// slot free. It returns ErrNoSeatsAvailable once no free slot is left.
+func (r *Repository) CreateSlotBooking(ctx context.Context, booking *model.Booking) (uuid.UUID, error) {
+	lock := "SKIP LOCKED"
+
+	for attempt := 1; ; attempt++ {
+		err := r.db.Master.QueryRowContext(ctx, fmt.Sprintf(claimSlotQuery, lock),
+			booking.EventID, booking.UserID, booking.ExpiresAt, booking.Guest,
+		).Scan(&booking.ID, &booking.Status, &booking.CreatedAt, &booking.UpdatedAt)
+		if err == nil {
+			return booking.ID, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, fmt.Errorf("failed to insert booking: %w", err)
+		}
+
+		// No slot was claimed: see whether free slots are only locked.
+		var free int
+		err = r.db.Master.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM seat_slots WHERE event_id = $1 AND booking_id IS NULL`, booking.EventID,
+		).Scan(&free)
+		if err != nil {
+			return uuid.Nil, fmt.Errorf("failed to count free slots: %w", err)
+		}
+		if free == 0 || attempt == slotAttempts {
+			return uuid.Nil, ErrNoSeatsAvailable
+		}
+
+		lock = ""
+	}
+}
+
+// availableSeatsColumn selects an event's available seats: the counter for
+// counter events, the number of free seat slots for slot events.
+const availableSeatsColumn = `
+	CASE WHEN e.seat_strategy = 'slots'
+		THEN (SELECT COUNT(*) FROM seat_slots s WHERE s.event_id = e.id AND s.booking_id IS NULL)::INT
+		ELSE e.available_seats
+	END`
+
 // GetAllEvents retrieves all events from the database.
 func (r *Repository) GetAllEvents(ctx context.Context) ([]*model.Event, error) {
 	query := `
-		SELECT id, title, date, total_seats, available_seats, booking_ttl, created_at, updated_at
-		FROM events;
+		SELECT e.id, e.title, e.date, e.total_seats, ` + availableSeatsColumn + `,
+		       e.booking_ttl, e.seat_strategy, e.created_at, e.updated_at, e.organizer_id
+		FROM events e;
 	`
 
 	rows, err := r.db.QueryContext(ctx, query)
@@ -124,8 +212,10 @@ func (r *Repository) GetAllEvents(ctx context.Context) ([]*model.Event, error) {
 			&e.TotalSeats,
 			&e.AvailableSeats,
 			&bookingTTLSeconds,
+			&e.SeatStrategy,
 			&e.CreatedAt,
 			&e.UpdatedAt,
+			&e.OrganizerID,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan event: %w", err)
@@ -144,9 +234,10 @@ func (r *Repository) GetAllEvents(ctx context.Context) ([]*model.Event, error) {
 // GetEventByID retrieves an event by its id.
 func (r *Repository) GetEventByID(ctx context.Context, eventID uuid.UUID) (*model.Event, error) {
 	query := `
-		SELECT id, title, date, total_seats, available_seats, booking_ttl, created_at, updated_at
-		FROM events
-		WHERE id = $1;
+		SELECT e.id, e.title, e.date, e.total_seats, ` + availableSeatsColumn + `,
+		       e.booking_ttl, e.seat_strategy, e.created_at, e.updated_at, e.organizer_id
+		FROM events e
+		WHERE e.id = $1;
 	`
 
 	var event model.Event
@@ -155,7 +246,7 @@ func (r *Repository) GetEventByID(ctx context.Context, eventID uuid.UUID) (*mode
 		ctx, query, eventID,
 	).Scan(
 		&event.ID, &event.Title, &event.Date, &event.TotalSeats, &event.AvailableSeats,
-		&bookingTTLSeconds, &event.CreatedAt, &event.UpdatedAt,
+		&bookingTTLSeconds, &event.SeatStrategy, &event.CreatedAt, &event.UpdatedAt, &event.OrganizerID,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -181,7 +272,7 @@ func (r *Repository) ConfirmBooking(ctx context.Context, bookingID uuid.UUID) er
 	`
 
 	var id uuid.UUID
-	err := r.db.QueryRowContext(ctx, query, bookingID).Scan(&id)
+	err := r.db.Master.QueryRowContext(ctx, query, bookingID).Scan(&id)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return ErrBookingNotFoundOrAlreadyConfirmed
@@ -193,17 +284,56 @@ func (r *Repository) ConfirmBooking(ctx context.Context, bookingID uuid.UUID) er
 	return nil
 }
 
-// GetExpiredBookings retrieves all expired pending bookings.
-func (r *Repository) GetExpiredBookings(ctx context.Context) ([]*model.Booking, error) {
+// GetBookingByID retrieves a booking by id.
+func (r *Repository) GetBookingByID(ctx context.Context, bookingID uuid.UUID) (*model.Booking, error) {
+	query := `
+		SELECT id, event_id, user_id, status, guest, expires_at, created_at, updated_at
+		FROM bookings
+		WHERE id = $1;
+	`
+
+	var b model.Booking
+	err := r.db.QueryRowContext(ctx, query, bookingID).Scan(
+		&b.ID, &b.EventID, &b.UserID, &b.Status, &b.Guest, &b.ExpiresAt, &b.CreatedAt, &b.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBookingNotFound
+		}
+
+		return nil, fmt.Errorf("failed to query booking: %w", err)
+	}
+
+	return &b, nil
+}
+
+// CountPendingGuestBookings counts the pending bookings made through guest checkout for an event.
+func (r *Repository) CountPendingGuestBookings(ctx context.Context, eventID uuid.UUID) (int, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM bookings
+		WHERE event_id = $1 AND guest AND status = 'pending';
+	`
+
+	var n int
+	if err := r.db.QueryRowContext(ctx, query, eventID).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to count pending guest bookings: %w", err)
+	}
+
+	return n, nil
+}
+
+// GetPendingBookings retrieves all pending bookings, expired or not.
+func (r *Repository) GetPendingBookings(ctx context.Context) ([]*model.Booking, error) {
 	query := `
         SELECT id, event_id, user_id, status, expires_at, created_at, updated_at
         FROM bookings
-        WHERE expires_at < NOW() AND status = 'pending';
+        WHERE status = 'pending';
     `
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("query expired bookings: %w", err)
+		return nil, fmt.Errorf("query pending bookings: %w", err)
 	}
 	defer rows.Close()
 
@@ -216,9 +346,45 @@ func (r *Repository) GetExpiredBookings(ctx context.Context) ([]*model.Booking,
 		bookings = append(bookings, &b)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
 	return bookings, nil
 }
 
+// GetAttendees retrieves the users holding pending or confirmed bookings for an event, in booking order.
+func (r *Repository) GetAttendees(ctx context.Context, eventID uuid.UUID) ([]*model.Attendee, error) {
+	query := `
+        SELECT b.id, u.id, u.email, u.name, b.status, b.created_at
+        FROM bookings b
+        JOIN users u ON u.id = b.user_id
+        WHERE b.event_id = $1 AND b.status IN ('pending', 'confirmed')
+        ORDER BY b.created_at;
+    `
+
+	rows, err := r.db.QueryContext(ctx, query, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("query attendees: %w", err)
+	}
+	defer rows.Close()
+
+	var attendees []*model.Attendee
+	for rows.Next() {
+		var a model.Attendee
+		if err := rows.Scan(&a.BookingID, &a.UserID, &a.Email, &a.Name, &a.Status, &a.BookedAt); err != nil {
+			return nil, fmt.Errorf("scan attendee: %w", err)
+		}
+		attendees = append(attendees, &a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return attendees, nil
+}
+
 // CancelBooking cancels a booking by a user.
 func (r *Repository) CancelBooking(ctx context.Context, bookingID uuid.UUID) error {
 	tx, err := r.db.Master.BeginTx(ctx, nil)
@@ -248,13 +414,17 @@ func (r *Repository) CancelBooking(ctx context.Context, bookingID uuid.UUID) err
  		UPDATE events
 		SET available_seats = available_seats + 1,
 		    updated_at = NOW()
- 		WHERE id = $1;
+ 		WHERE id = $1 AND seat_strategy = 'counter';
 	`
 	_, err = tx.ExecContext(ctx, updateEventQuery, eventID)
 	if err != nil {
 		return fmt.Errorf("failed to update event seats: %w", err)
 	}
 
+	if err = releaseSlot(ctx, tx, bookingID); err != nil {
+		return err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
@@ -262,7 +432,8 @@ func (r *Repository) CancelBooking(ctx context.Context, bookingID uuid.UUID) err
 	return nil
 }
 
-// CancelExpiredBooking sets the status of an expired booking to 'cancelled'.
+// CancelExpiredBooking sets the status of an expired booking to 'cancelled'
+// and enqueues an expiry notification for the booking's user.
 func (r *Repository) CancelExpiredBooking(ctx context.Context, bookingID uuid.UUID) error {
 	tx, err := r.db.Master.BeginTx(ctx, nil)
 	if err != nil {
@@ -292,7 +463,7 @@ func (r *Repository) CancelExpiredBooking(ctx context.Context, bookingID uuid.UU
  		UPDATE events
 		SET available_seats = available_seats + 1,
 		    updated_at = NOW()
- 		WHERE id = $1;
+ 		WHERE id = $1 AND seat_strategy = 'counter';
 	`
 
 	_, err = tx.ExecContext(ctx, updateEventQuery, eventID)
@@ -300,9 +471,333 @@ func (r *Repository) CancelExpiredBooking(ctx context.Context, bookingID uuid.UU
 		return fmt.Errorf("failed to update event: %w", err)
 	}
 
+	if err = releaseSlot(ctx, tx, bookingID); err != nil {
+		return err
+	}
+
+	// Enqueue the expiry notification in the same transaction,
+	// so it is never lost once the booking is cancelled.
+	enqueueQuery := `
+		INSERT INTO outbox (user_id, recipient, template, locale, payload)
+		SELECT u.id, u.email, $2, u.locale,
+		       jsonb_build_object('UserName', u.name, 'EventTitle', e.title, 'EventDate', e.date)
+		FROM bookings b
+		JOIN users u ON u.id = b.user_id
+		JOIN events e ON e.id = b.event_id
+		WHERE b.id = $1;
+	`
+
+	_, err = tx.ExecContext(ctx, enqueueQuery, bookingID, notification.TemplateBookingExpired)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+
 	if err = tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
 	return nil
 }
+
+// EnqueueEventReminders enqueues reminders for confirmed bookings of events that
+// start within (after, before] from now, recording them under before's offset.
+//
+// Each booking is reminded at most once per offset: the reminder is recorded in
+// booking_reminders in the same statement that writes it to the outbox, so
+// restarts and concurrent instances never send it twice.
+// It returns the number of reminders enqueued.
+func (r *Repository) EnqueueEventReminders(ctx context.Context, after, before time.Duration) (int, error) {
+	query := `
+		WITH due AS (
+			SELECT b.id
+			FROM bookings b
+			JOIN events e ON e.id = b.event_id
+			WHERE b.status = 'confirmed'
+			  AND e.date > NOW() + make_interval(secs => $1)
+			  AND e.date <= NOW() + make_interval(secs => $2)
+		), recorded AS (
+			INSERT INTO booking_reminders (booking_id, offset_seconds)
+			SELECT id, $2::BIGINT
+			FROM due
+			ON CONFLICT DO NOTHING
+			RETURNING booking_id
+		)
+		INSERT INTO outbox (user_id, recipient, template, locale, payload)
+		SELECT u.id, u.email, $3, u.locale,
+		       jsonb_build_object('UserName', u.name, 'EventTitle', e.title, 'EventDate', e.date, 'Timezone', u.timezone)
+		FROM recorded rec
+		JOIN bookings b ON b.id = rec.booking_id
+		JOIN users u ON u.id = b.user_id
+		JOIN events e ON e.id = b.event_id;
+	`
+
+	res, err := r.db.ExecContext(
+		ctx, query,
+		int64(after.Seconds()),
+		int64(before.Seconds()),
+		notification.TemplateEventReminder,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue event reminders: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// EnqueueHoldExpiryWarnings marks pending bookings that are about to expire as
+// warned and enqueues a warning with a confirmation link for each of them.
+//
+// A booking is due once the time left is at most fraction of its event's booking
+// TTL, or at most lead if fraction is zero. Marking and enqueueing happen in one
+// statement, so each booking is warned at most once.
+// It returns the number of warnings enqueued.
+func (r *Repository) EnqueueHoldExpiryWarnings(
+	ctx context.Context,
+	lead time.Duration,
+	fraction float64,
+	baseURL string,
+) (int, error) {
+	query := `
+		WITH warned AS (
+			UPDATE bookings b
+			SET warned_at = NOW()
+			FROM events e
+			WHERE e.id = b.event_id
+			  AND b.status = 'pending'
+			  AND b.warned_at IS NULL
+			  AND b.expires_at > NOW()
+			  AND b.expires_at <= NOW() + CASE
+			      WHEN $2::FLOAT8 > 0 THEN make_interval(secs => e.booking_ttl * $2::FLOAT8)
+			      ELSE make_interval(secs => $1::FLOAT8)
+			  END
+			RETURNING b.id, b.user_id, b.event_id, b.expires_at
+		)
+		INSERT INTO outbox (user_id, recipient, template, locale, payload)
+		SELECT u.id, u.email, $4, u.locale,
+		       jsonb_build_object(
+		           'UserName', u.name,
+		           'EventTitle', e.title,
+		           'ExpiresAt', w.expires_at,
+		           'Timezone', u.timezone,
+		           'ConfirmURL', $3::TEXT || '/events/' || e.id || '?booking=' || w.id
+		       )
+		FROM warned w
+		JOIN users u ON u.id = w.user_id
+		JOIN events e ON e.id = w.event_id;
+	`
+
+	res, err := r.db.ExecContext(
+		ctx, query,
+		lead.Seconds(),
+		fraction,
+		strings.TrimRight(baseURL, "/"),
+		notification.TemplateHoldExpiring,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to enqueue hold expiry warnings: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// CancelExpiredBookingsBatch cancels up to limit expired pending bookings in a
+// single statement: it restores available seats with one update per event (or
+// frees the bookings' seat slots) and enqueues an expiry notification for each booking.
+//
+// Rows locked by a concurrent run are skipped. It returns the number of
+// cancelled bookings.
+func (r *Repository) CancelExpiredBookingsBatch(ctx context.Context, limit int) (int, error) {
+	query := `
+		WITH expired AS (
+			SELECT id
+			FROM bookings
+			WHERE status = 'pending' AND expires_at < NOW()
+			ORDER BY expires_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), cancelled AS (
+			UPDATE bookings b
+			SET status = 'cancelled',
+			    updated_at = NOW()
+			FROM expired x
+			WHERE b.id = x.id
+			RETURNING b.id, b.event_id, b.user_id
+		), released AS (
+			UPDATE events e
+			SET available_seats = e.available_seats + c.seats,
+			    updated_at = NOW()
+			FROM (
+				SELECT event_id, COUNT(*) AS seats
+				FROM cancelled
+				GROUP BY event_id
+			) c
+			WHERE e.id = c.event_id AND e.seat_strategy = 'counter'
+		), freed AS (
+			UPDATE seat_slots s
+			SET booking_id = NULL
+			FROM cancelled c
+			WHERE s.booking_id = c.id
+		), notified AS (
+			INSERT INTO outbox (user_id, recipient, template, locale, payload)
+			SELECT u.id, u.email, $2, u.locale,
+			       jsonb_build_object('UserName', u.name, 'EventTitle', e.title, 'EventDate', e.date)
+			FROM cancelled c
+			JOIN users u ON u.id = c.user_id
+			JOIN events e ON e.id = c.event_id
+		)
+		SELECT COUNT(*) FROM cancelled;
+	`
+
+	var n int
+	if err := r.db.Master.QueryRowContext(ctx, query, limit, notification.TemplateBookingExpired).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to cancel expired bookings: %w", err)
+	}
+
+	return n, nil
+}
+
+// seatDriftQuery selects counter events whose available_seats does not match
+// total_seats minus their active bookings. If $1 is not NULL, only the given events are checked.
+// Slot events are skipped, since their availability is derived from seat_slots.
+const seatDriftQuery = `
+	SELECT e.id, e.title, e.total_seats, e.available_seats, COUNT(b.id) AS active_bookings
+	FROM events e
+	LEFT JOIN bookings b ON b.event_id = e.id AND b.status IN ('pending', 'confirmed')
+	WHERE e.seat_strategy = 'counter' AND ($1::UUID[] IS NULL OR e.id = ANY($1::UUID[]))
+	GROUP BY e.id
+	HAVING e.available_seats <> e.total_seats - COUNT(b.id)
+	ORDER BY e.date;
+`
+
+// releaseSlot frees the seat slot held by a booking, if any.
+func releaseSlot(ctx context.Context, tx *sql.Tx, bookingID uuid.UUID) error {
+	query := `UPDATE seat_slots SET booking_id = NULL WHERE booking_id = $1`
+
+	if _, err := tx.ExecContext(ctx, query, bookingID); err != nil {
+		return fmt.Errorf("failed to release seat slot: %w", err)
+	}
+
+	return nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// GetSeatDrifts retrieves all events whose available seats drifted from their bookings.
+func (r *Repository) GetSeatDrifts(ctx context.Context) ([]*model.SeatDrift, error) {
+	return getSeatDrifts(ctx, r.db.Master, nil)
+}
+
+// ReconcileSeats detects events whose available seats drifted from their bookings
+// and records each drift in the seat_drifts table. If correct is true, it also
+// resets available_seats to total_seats minus the active bookings (never below zero).
+//
+// The drifted events are locked and checked again before anything is recorded,
+// so bookings made while the check runs are not mistaken for drift.
+func (r *Repository) ReconcileSeats(ctx context.Context, correct bool) ([]*model.SeatDrift, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	candidates, err := getSeatDrifts(ctx, tx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(candidates))
+	for _, d := range candidates {
+		ids = append(ids, d.EventID)
+	}
+
+	// Booking changes update the event row in the same transaction,
+	// so once the rows are locked the bookings can be counted safely.
+	lockQuery := `SELECT id FROM events WHERE id = ANY($1::UUID[]) ORDER BY id FOR UPDATE`
+	if _, err := tx.ExecContext(ctx, lockQuery, pq.Array(ids)); err != nil {
+		return nil, fmt.Errorf("failed to lock events: %w", err)
+	}
+
+	drifts, err := getSeatDrifts(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	recordQuery := `
+		INSERT INTO seat_drifts (event_id, total_seats, available_seats, active_bookings, corrected)
+		VALUES ($1, $2, $3, $4, $5);
+	`
+
+	correctQuery := `
+		UPDATE events
+		SET available_seats = GREATEST(total_seats - $2, 0),
+		    updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	for _, d := range drifts {
+		_, err := tx.ExecContext(ctx, recordQuery, d.EventID, d.TotalSeats, d.AvailableSeats, d.ActiveBookings, correct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to record seat drift: %w", err)
+		}
+
+		if correct {
+			if _, err := tx.ExecContext(ctx, correctQuery, d.EventID, d.ActiveBookings); err != nil {
+				return nil, fmt.Errorf("failed to correct available seats: %w", err)
+			}
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return drifts, nil
+}
+
+// getSeatDrifts runs seatDriftQuery, optionally limited to the given events.
+func getSeatDrifts(ctx context.Context, q querier, eventIDs []uuid.UUID) ([]*model.SeatDrift, error) {
+	var ids any
+	if eventIDs != nil {
+		ids = pq.Array(eventIDs)
+	}
+
+	rows, err := q.QueryContext(ctx, seatDriftQuery, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seat drifts: %w", err)
+	}
+	defer rows.Close()
+
+	var drifts []*model.SeatDrift
+	for rows.Next() {
+		var d model.SeatDrift
+		if err := rows.Scan(&d.EventID, &d.EventTitle, &d.TotalSeats, &d.AvailableSeats, &d.ActiveBookings); err != nil {
+			return nil, fmt.Errorf("scan seat drift: %w", err)
+		}
+
+		d.ExpectedSeats = d.TotalSeats - d.ActiveBookings
+		d.Drift = d.AvailableSeats - d.ExpectedSeats
+		drifts = append(drifts, &d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return drifts, nil
+}
@@ -0,0 +1,284 @@
+package event
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/dbpg"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// testDSNEnv names the environment variable with the DSN of a migrated test
+// database. Tests and benchmarks against the database are skipped without it.
+const testDSNEnv = "TEST_DATABASE_DSN"
+
+// newTestRepository connects a repository to the test database.
+func newTestRepository(tb testing.TB) *Repository {
+	tb.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		tb.Skipf("%s is not set", testDSNEnv)
+	}
+
+	pg, err := dbpg.New(dsn, nil, &dbpg.Options{MaxOpenConns: 64, MaxIdleConns: 64})
+	if err != nil {
+		tb.Fatalf("connect to test database: %v", err)
+	}
+	tb.Cleanup(func() { _ = pg.Master.Close() })
+
+	return NewRepository(database.New(pg, 0))
+}
+
+// createTestUser creates a user to hold test bookings.
+func createTestUser(tb testing.TB, r *Repository) uuid.UUID {
+	tb.Helper()
+
+	var id uuid.UUID
+	err := r.db.Master.QueryRowContext(context.Background(), `
+		INSERT INTO users (email, password_hash, name)
+		VALUES ($1, '', 'Test User')
+		RETURNING id;
+	`, "test-"+uuid.NewString()+"@example.com").Scan(&id)
+	if err != nil {
+		tb.Fatalf("create user: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		_, _ = r.db.Master.Exec(`DELETE FROM outbox WHERE user_id = $1`, id)
+		_, _ = r.db.Master.Exec(`DELETE FROM users WHERE id = $1`, id)
+	})
+
+	return id
+}
+
+// createTestEvent creates an event with the given seats and seat strategy.
+// It is deleted with its bookings when the test ends.
+func createTestEvent(tb testing.TB, r *Repository, strategy string, seats int) uuid.UUID {
+	tb.Helper()
+
+	id, err := r.CreateEvent(context.Background(), &model.Event{
+		Title:          "Test Event",
+		Date:           time.Now().Add(24 * time.Hour),
+		TotalSeats:     seats,
+		AvailableSeats: seats,
+		BookingTTL:     time.Hour,
+		SeatStrategy:   strategy,
+	})
+	if err != nil {
+		tb.Fatalf("create event: %v", err)
+	}
+
+	tb.Cleanup(func() {
+		_, _ = r.db.Master.Exec(`DELETE FROM seat_slots WHERE event_id = $1`, id)
+		_, _ = r.db.Master.Exec(`DELETE FROM bookings WHERE event_id = $1`, id)
+		_, _ = r.db.Master.Exec(`DELETE FROM events WHERE id = $1`, id)
+	})
+
+	return id
+}
+
+// createBookingFunc returns the repository method booking seats with strategy.
+func createBookingFunc(r *Repository, strategy string) func(context.Context, *model.Booking) (uuid.UUID, error) {
+	if strategy == model.SeatStrategySlots {
+		return r.CreateSlotBooking
+	}
+
+	return r.CreateBooking
+}
+
+// countHeldSeats counts the pending and confirmed bookings of an event.
+func countHeldSeats(tb testing.TB, r *Repository, eventID uuid.UUID) int {
+	tb.Helper()
+
+	var n int
+	err := r.db.Master.QueryRowContext(context.Background(), `
+		SELECT COUNT(*)
+		FROM bookings
+		WHERE event_id = $1 AND status IN ('pending', 'confirmed');
+	`, eventID).Scan(&n)
+	if err != nil {
+		tb.Fatalf("count bookings: %v", err)
+	}
+
+	return n
+}
+
+// TestCreateBookingConcurrent books an event from more goroutines than it has
+// seats. Every seat must be booked exactly once: none oversold, and none
+// reported sold out while it was only locked by another booking.
+func TestCreateBookingConcurrent(t *testing.T) {
+	const (
+		seats   = 20
+		bookers = 100
+	)
+
+	r := newTestRepository(t)
+	userID := createTestUser(t, r)
+
+	for _, strategy := range []string{model.SeatStrategyCounter, model.SeatStrategySlots} {
+		t.Run(strategy, func(t *testing.T) {
+			eventID := createTestEvent(t, r, strategy, seats)
+			create := createBookingFunc(r, strategy)
+
+			var (
+				booked atomic.Int64
+				wg     sync.WaitGroup
+			)
+			for range bookers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					_, err := create(context.Background(), &model.Booking{
+						EventID:   eventID,
+						UserID:    userID,
+						ExpiresAt: time.Now().Add(time.Hour),
+					})
+					switch {
+					case err == nil:
+						booked.Add(1)
+					case !errors.Is(err, ErrNoSeatsAvailable):
+						t.Errorf("create booking: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if got := booked.Load(); got != seats {
+				t.Errorf("%d bookings succeeded, want %d", got, seats)
+			}
+			if held := countHeldSeats(t, r, eventID); held != seats {
+				t.Errorf("%d seats held, want %d", held, seats)
+			}
+		})
+	}
+}
+
+// BenchmarkCreateBooking books the seats of one event from parallel
+// goroutines with each seat strategy, to compare their throughput under
+// contention. Run with -cpu to vary the number of goroutines.
+func BenchmarkCreateBooking(b *testing.B) {
+	r := newTestRepository(b)
+	userID := createTestUser(b, r)
+
+	for _, strategy := range []string{model.SeatStrategyCounter, model.SeatStrategySlots} {
+		b.Run(strategy, func(b *testing.B) {
+			eventID := createTestEvent(b, r, strategy, b.N)
+			create := createBookingFunc(r, strategy)
+
+			var soldOut atomic.Int64
+
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, err := create(context.Background(), &model.Booking{
+						EventID:   eventID,
+						UserID:    userID,
+						ExpiresAt: time.Now().Add(time.Hour),
+					})
+					switch {
+					case errors.Is(err, ErrNoSeatsAvailable):
+						soldOut.Add(1)
+					case err != nil:
+						b.Errorf("create booking: %v", err)
+						return
+					}
+				}
+			})
+			b.StopTimer()
+
+			b.ReportMetric(float64(soldOut.Load())/float64(b.N), "soldout/op")
+			if held := countHeldSeats(b, r, eventID); held > b.N {
+				b.Fatalf("%d seats held, event has %d", held, b.N)
+			}
+		})
+	}
+}
+
+// createExpiredBookings creates n pending bookings of an event that have expired.
+func createExpiredBookings(tb testing.TB, r *Repository, eventID, userID uuid.UUID, n int) []uuid.UUID {
+	tb.Helper()
+
+	rows, err := r.db.Master.QueryContext(context.Background(), `
+		INSERT INTO bookings (event_id, user_id, expires_at)
+		SELECT $1, $2, NOW() - INTERVAL '1 minute'
+		FROM generate_series(1, $3)
+		RETURNING id;
+	`, eventID, userID, n)
+	if err != nil {
+		tb.Fatalf("create expired bookings: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make([]uuid.UUID, 0, n)
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			tb.Fatalf("scan booking id: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		tb.Fatalf("create expired bookings: %v", err)
+	}
+
+	return ids
+}
+
+// BenchmarkCancelExpiredBookings compares cancelling expired bookings in
+// batched statements with cancelling them one transaction per booking.
+// Each iteration cancels the same number of bookings, which includes
+// enqueueing their expiry notifications.
+func BenchmarkCancelExpiredBookings(b *testing.B) {
+	const (
+		bookings  = 1000
+		batchSize = 500
+	)
+
+	r := newTestRepository(b)
+	userID := createTestUser(b, r)
+	eventID := createTestEvent(b, r, model.SeatStrategyCounter, bookings)
+	ctx := context.Background()
+
+	b.Run("batch", func(b *testing.B) {
+		for range b.N {
+			b.StopTimer()
+			createExpiredBookings(b, r, eventID, userID, bookings)
+			b.StartTimer()
+
+			for {
+				n, err := r.CancelExpiredBookingsBatch(ctx, batchSize)
+				if err != nil {
+					b.Fatalf("cancel expired bookings batch: %v", err)
+				}
+				if n < batchSize {
+					break
+				}
+			}
+		}
+	})
+
+	b.Run("row", func(b *testing.B) {
+		for range b.N {
+			b.StopTimer()
+			ids := createExpiredBookings(b, r, eventID, userID, bookings)
+			b.StartTimer()
+
+			for _, id := range ids {
+				if err := r.CancelExpiredBooking(ctx, id); err != nil {
+					b.Fatalf("cancel expired booking: %v", err)
+				}
+			}
+		}
+	})
+}
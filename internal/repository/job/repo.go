@@ -0,0 +1,153 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// Repository provides methods to interact with job_runs and job_states tables.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new job repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// StartRun records the start of a job run and returns its id.
+func (r *Repository) StartRun(ctx context.Context, jobName, instance, trigger string) (uuid.UUID, error) {
+	query := `
+		INSERT INTO job_runs (job_name, instance, trigger)
+		VALUES ($1, $2, $3)
+		RETURNING id;
+	`
+
+	var id uuid.UUID
+	err := r.db.Master.QueryRowContext(ctx, query, jobName, instance, trigger).Scan(&id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to start job run: %w", err)
+	}
+
+	return id, nil
+}
+
+// FinishRun records the end of a job run after the given number of attempts.
+// An empty errMsg marks the run as succeeded.
+func (r *Repository) FinishRun(ctx context.Context, runID uuid.UUID, attempts, itemsProcessed int, errMsg string) error {
+	query := `
+		UPDATE job_runs
+		SET status = CASE WHEN $4 = '' THEN 'succeeded' ELSE 'failed' END,
+		    attempts = $2,
+		    items_processed = $3,
+		    error = NULLIF($4, ''),
+		    finished_at = NOW(),
+		    duration_ms = (EXTRACT(EPOCH FROM NOW() - started_at) * 1000)::BIGINT
+		WHERE id = $1;
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, runID, attempts, itemsProcessed, errMsg); err != nil {
+		return fmt.Errorf("failed to finish job run: %w", err)
+	}
+
+	return nil
+}
+
+// GetRuns retrieves the most recent runs of a job.
+func (r *Repository) GetRuns(ctx context.Context, jobName string, limit int) ([]*model.JobRun, error) {
+	query := `
+		SELECT id, job_name, instance, trigger, status, attempts, items_processed, COALESCE(error, ''),
+		       started_at, finished_at, duration_ms
+		FROM job_runs
+		WHERE job_name = $1
+		ORDER BY started_at DESC
+		LIMIT $2;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, jobName, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query job runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*model.JobRun
+	for rows.Next() {
+		var run model.JobRun
+		err := rows.Scan(
+			&run.ID, &run.JobName, &run.Instance, &run.Trigger, &run.Status, &run.Attempts, &run.ItemsProcessed, &run.Error,
+			&run.StartedAt, &run.FinishedAt, &run.DurationMS,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan job run: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return runs, nil
+}
+
+// IsPaused checks whether a job is paused.
+func (r *Repository) IsPaused(ctx context.Context, jobName string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM job_states WHERE job_name = $1 AND paused)`
+
+	var paused bool
+	err := r.db.Master.QueryRowContext(ctx, query, jobName).Scan(&paused)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if job is paused: %w", err)
+	}
+
+	return paused, nil
+}
+
+// ClaimTick claims the scheduled tick of a job for a run. It returns false if
+// the tick, or a later one, was already claimed, e.g. by another instance
+// whose clock fired a moment later.
+func (r *Repository) ClaimTick(ctx context.Context, jobName string, tick time.Time) (bool, error) {
+	query := `
+		INSERT INTO job_states (job_name, last_tick)
+		VALUES ($1, $2)
+		ON CONFLICT (job_name) DO UPDATE
+		SET last_tick = EXCLUDED.last_tick,
+		    updated_at = NOW()
+		WHERE job_states.last_tick IS NULL OR job_states.last_tick < EXCLUDED.last_tick;
+	`
+
+	res, err := r.db.Master.ExecContext(ctx, query, jobName, tick)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job tick: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// SetPaused pauses or resumes a job.
+func (r *Repository) SetPaused(ctx context.Context, jobName string, paused bool) error {
+	query := `
+		INSERT INTO job_states (job_name, paused)
+		VALUES ($1, $2)
+		ON CONFLICT (job_name) DO UPDATE
+		SET paused = EXCLUDED.paused,
+		    updated_at = NOW();
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, jobName, paused); err != nil {
+		return fmt.Errorf("failed to set job paused: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package lock
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+)
+
+// keyPrefix namespaces advisory lock keys of this application.
+const keyPrefix = "event-booker:"
+
+// unlockTimeout bounds how long releasing a lock may take.
+const unlockTimeout = 5 * time.Second
+
+// Repository provides PostgreSQL session-level advisory locks.
+//
+// A lock is held by a dedicated connection from the master pool for as long
+// as it is owned, so it is released automatically if the process dies.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new lock repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// TryLock tries to take the advisory lock for key without waiting.
+// If the lock is acquired, it returns a function that releases it.
+func (r *Repository) TryLock(ctx context.Context, key string) (func(), bool, error) {
+	conn, err := r.db.Master.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to get connection: %w", err)
+	}
+
+	var acquired bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, keyPrefix+key).Scan(&acquired)
+	if err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("failed to try advisory lock: %w", err)
+	}
+
+	if !acquired {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	release := func() {
+		unlockCtx, cancel := context.WithTimeout(context.Background(), unlockTimeout)
+		defer cancel()
+
+		_, err := conn.ExecContext(unlockCtx, `SELECT pg_advisory_unlock(hashtext($1))`, keyPrefix+key)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("key", key).Msg("failed to release advisory lock")
+
+			// Discard the connection instead of returning it to the pool,
+			// so that closing the session releases the lock.
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+
+		_ = conn.Close()
+	}
+
+	return release, true, nil
+}
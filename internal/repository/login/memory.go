@@ -0,0 +1,114 @@
+package login
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// counter holds the attempts counted for a key.
+type counter struct {
+	attempts      int
+	lastAttemptAt time.Time
+}
+
+// MemoryCounters counts login attempts in process memory. Counts are lost on
+// restart and are not shared between instances, but need no database writes.
+type MemoryCounters struct {
+	mu        sync.Mutex
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+// NewMemoryCounters creates an empty in-memory attempt counter.
+func NewMemoryCounters() *MemoryCounters {
+	return &MemoryCounters{counters: make(map[string]*counter)}
+}
+
+// Hit counts a login attempt for key and returns the number of attempts before
+// this one, the time of the previous attempt, zero if there was none, and the
+// time of this one. The count starts over once window has passed since the
+// previous attempt.
+func (m *MemoryCounters) Hit(_ context.Context, key string, window time.Duration) (int, time.Time, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now, window)
+
+	c, ok := m.counters[key]
+	if !ok {
+		c = &counter{}
+		m.counters[key] = c
+	}
+
+	prevAt := c.lastAttemptAt
+	if now.Sub(prevAt) > window {
+		c.attempts = 0
+	}
+
+	prev := c.attempts
+	c.attempts++
+	c.lastAttemptAt = now
+
+	return prev, prevAt, now, nil
+}
+
+// Undo takes back a rejected attempt for key counted at at, and restores the
+// time of the previous attempt, prevAt, unless a later attempt was counted
+// since. Rejected attempts thus neither count nor extend a delay or lockout.
+func (m *MemoryCounters) Undo(_ context.Context, key string, prevAt, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.counters[key]
+	if !ok {
+		return nil
+	}
+
+	if c.attempts > 0 {
+		c.attempts--
+	}
+	if c.lastAttemptAt.Equal(at) {
+		c.lastAttemptAt = prevAt
+	}
+
+	return nil
+}
+
+// Forgive takes back one counted attempt for key, e.g. after a successful login.
+func (m *MemoryCounters) Forgive(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if c, ok := m.counters[key]; ok && c.attempts > 0 {
+		c.attempts--
+	}
+
+	return nil
+}
+
+// Reset clears the attempts counted for key.
+func (m *MemoryCounters) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.counters, key)
+
+	return nil
+}
+
+// sweep drops counters idle for longer than window, at most once per window,
+// so that the map does not grow with every address ever seen.
+func (m *MemoryCounters) sweep(now time.Time, window time.Duration) {
+	if now.Sub(m.lastSweep) < window {
+		return
+	}
+
+	for key, c := range m.counters {
+		if now.Sub(c.lastAttemptAt) > window {
+			delete(m.counters, key)
+		}
+	}
+	m.lastSweep = now
+}
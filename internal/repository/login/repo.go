@@ -0,0 +1,145 @@
+package login
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// Repository provides methods to interact with login_counters and login_failures tables.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new login repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Hit counts a login attempt for key and returns the number of attempts before
+// this one, the time of the previous attempt, zero if there was none, and the
+// time of this one. The count starts over once window has passed since the
+// previous attempt.
+func (r *Repository) Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, time.Time, error) {
+	query := `
+		WITH prev AS (
+			SELECT last_attempt_at
+			FROM login_counters
+			WHERE key = $1
+		)
+		INSERT INTO login_counters (key, attempts, last_attempt_at)
+		VALUES ($1, 1, NOW())
+		ON CONFLICT (key) DO UPDATE
+		SET attempts = CASE
+		        WHEN login_counters.last_attempt_at < NOW() - make_interval(secs => $2) THEN 1
+		        ELSE login_counters.attempts + 1
+		    END,
+		    last_attempt_at = NOW()
+		RETURNING attempts, (SELECT last_attempt_at FROM prev), last_attempt_at;
+	`
+
+	var (
+		attempts int
+		prevAt   sql.NullTime
+		at       time.Time
+	)
+	if err := r.db.Master.QueryRowContext(ctx, query, key, window.Seconds()).Scan(&attempts, &prevAt, &at); err != nil {
+		return 0, time.Time{}, time.Time{}, fmt.Errorf("failed to count login attempt: %w", err)
+	}
+
+	return attempts - 1, prevAt.Time, at, nil
+}
+
+// Undo takes back a rejected attempt for key counted at at, and restores the
+// time of the previous attempt, prevAt, unless a later attempt was counted
+// since. Rejected attempts thus neither count nor extend a delay or lockout.
+func (r *Repository) Undo(ctx context.Context, key string, prevAt, at time.Time) error {
+	query := `
+		UPDATE login_counters
+		SET attempts = GREATEST(attempts - 1, 0),
+		    last_attempt_at = CASE WHEN last_attempt_at = $3 THEN $2 ELSE last_attempt_at END
+		WHERE key = $1;
+	`
+
+	if _, err := r.db.Master.ExecContext(ctx, query, key, prevAt, at); err != nil {
+		return fmt.Errorf("failed to undo login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// Forgive takes back one counted attempt for key, e.g. after a successful login.
+func (r *Repository) Forgive(ctx context.Context, key string) error {
+	query := `
+		UPDATE login_counters
+		SET attempts = attempts - 1
+		WHERE key = $1 AND attempts > 0;
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("failed to forgive login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// Reset clears the attempts counted for key.
+func (r *Repository) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_counters WHERE key = $1;`
+
+	if _, err := r.db.ExecContext(ctx, query, key); err != nil {
+		return fmt.Errorf("failed to reset login attempts: %w", err)
+	}
+
+	return nil
+}
+
+// RecordFailure stores an audit record of a failed login attempt.
+func (r *Repository) RecordFailure(ctx context.Context, f *model.LoginFailure) error {
+	query := `
+		INSERT INTO login_failures (email, user_id, ip, reason)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, f.Email, f.UserID, f.IP, f.Reason); err != nil {
+		return fmt.Errorf("failed to record login failure: %w", err)
+	}
+
+	return nil
+}
+
+// GetFailures retrieves the most recent failed login attempts, optionally only those for email.
+func (r *Repository) GetFailures(ctx context.Context, email string, limit int) ([]*model.LoginFailure, error) {
+	query := `
+		SELECT id, email, user_id, ip, reason, created_at
+		FROM login_failures
+		WHERE $1 = '' OR email = $1
+		ORDER BY created_at DESC
+		LIMIT $2;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, email, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query login failures: %w", err)
+	}
+	defer rows.Close()
+
+	var failures []*model.LoginFailure
+	for rows.Next() {
+		var f model.LoginFailure
+		if err := rows.Scan(&f.ID, &f.Email, &f.UserID, &f.IP, &f.Reason, &f.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan login failure: %w", err)
+		}
+		failures = append(failures, &f)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration error: %w", err)
+	}
+
+	return failures, nil
+}
@@ -0,0 +1,196 @@
+package mfa
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+var (
+	ErrMFANotFound          = errors.New("two-factor authentication not set up")
+	ErrMFAAlreadyEnabled    = errors.New("two-factor authentication already enabled")
+	ErrCodeAlreadyUsed      = errors.New("code already used")
+	ErrRecoveryCodeNotFound = errors.New("recovery code not found or already used")
+)
+
+// Repository provides methods to interact with user_mfa and recovery_codes tables.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new MFA repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// SavePendingSecret stores the secret of a new, unverified enrollment,
+// replacing an earlier unverified one.
+// Returns ErrMFAAlreadyEnabled if the user has a verified second factor.
+func (r *Repository) SavePendingSecret(ctx context.Context, userID uuid.UUID, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret,
+		    last_step = NULL,
+		    created_at = NOW()
+		WHERE user_mfa.confirmed_at IS NULL
+		RETURNING user_id;
+	`
+
+	var id uuid.UUID
+	if err := r.db.Master.QueryRowContext(ctx, query, userID, secret).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMFAAlreadyEnabled
+		}
+
+		return fmt.Errorf("failed to save mfa secret: %w", err)
+	}
+
+	return nil
+}
+
+// GetMFA retrieves the second factor of a user.
+// Returns ErrMFANotFound if the user has none, verified or not.
+func (r *Repository) GetMFA(ctx context.Context, userID uuid.UUID) (*model.MFA, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_step
+		FROM user_mfa
+		WHERE user_id = $1;
+	`
+
+	var m model.MFA
+	err := r.db.Master.QueryRowContext(ctx, query, userID).Scan(&m.UserID, &m.Secret, &m.ConfirmedAt, &m.LastStep)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrMFANotFound
+		}
+
+		return nil, fmt.Errorf("failed to query mfa: %w", err)
+	}
+
+	return &m, nil
+}
+
+// Confirm verifies the pending enrollment of a user, records the time step of
+// the code that verified it and replaces the user's recovery codes, in one transaction.
+// Returns ErrMFANotFound if there is no pending enrollment.
+func (r *Repository) Confirm(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	confirmQuery := `
+		UPDATE user_mfa
+		SET confirmed_at = NOW(),
+		    last_step = $2
+		WHERE user_id = $1 AND confirmed_at IS NULL;
+	`
+
+	res, err := tx.ExecContext(ctx, confirmQuery, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to confirm mfa: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrMFANotFound
+	}
+
+	if _, err = tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return fmt.Errorf("failed to delete recovery codes: %w", err)
+	}
+
+	codesQuery := `
+		INSERT INTO recovery_codes (user_id, code_hash)
+		SELECT $1, UNNEST($2::TEXT[]);
+	`
+
+	if _, err = tx.ExecContext(ctx, codesQuery, userID, pq.Array(codeHashes)); err != nil {
+		return fmt.Errorf("failed to insert recovery codes: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// UseStep records that the TOTP code of a time step was used.
+// Returns ErrCodeAlreadyUsed if a code of that or a later step was used before.
+func (r *Repository) UseStep(ctx context.Context, userID uuid.UUID, step int64) error {
+	query := `
+		UPDATE user_mfa
+		SET last_step = $2
+		WHERE user_id = $1 AND (last_step IS NULL OR last_step < $2);
+	`
+
+	res, err := r.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return fmt.Errorf("failed to use totp step: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrCodeAlreadyUsed
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user as used.
+// Returns ErrRecoveryCodeNotFound if the user has no such unused code.
+func (r *Repository) UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error {
+	query := `
+		UPDATE recovery_codes
+		SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrRecoveryCodeNotFound
+	}
+
+	return nil
+}
+
+// DeleteMFA removes the second factor and the recovery codes of a user.
+func (r *Repository) DeleteMFA(ctx context.Context, userID uuid.UUID) error {
+	query := `
+		WITH codes AS (
+			DELETE FROM recovery_codes WHERE user_id = $1
+		)
+		DELETE FROM user_mfa WHERE user_id = $1;
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to delete mfa: %w", err)
+	}
+
+	return nil
+}
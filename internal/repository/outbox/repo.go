@@ -0,0 +1,172 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+var ErrMessageNotFoundOrNotDead = errors.New("message not found or not dead")
+
+// Repository provides methods to interact with outbox table.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new outbox repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ClaimDueMessages locks up to limit pending messages that are due for delivery
+// and pushes their next attempt time forward by lease, so that concurrent
+// dispatchers skip them while they are being delivered.
+func (r *Repository) ClaimDueMessages(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error) {
+	query := `
+		UPDATE outbox
+		SET next_attempt_at = NOW() + make_interval(secs => $2),
+		    updated_at = NOW()
+		WHERE id IN (
+			SELECT id
+			FROM outbox
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, user_id, recipient, template, locale, payload, status, attempts, delivered_channels,
+		          COALESCE(last_error, ''), next_attempt_at, sent_at, created_at, updated_at;
+	`
+
+	rows, err := r.db.Master.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// MarkSent marks a message as delivered over the given channels.
+func (r *Repository) MarkSent(ctx context.Context, messageID uuid.UUID, delivered []string) error {
+	query := `
+		UPDATE outbox
+		SET status = 'sent',
+		    attempts = attempts + 1,
+		    delivered_channels = $2,
+		    last_error = NULL,
+		    sent_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, messageID, pq.Array(delivered)); err != nil {
+		return fmt.Errorf("failed to mark message sent: %w", err)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed delivery attempt and the channels the message
+// was already delivered to. The message is retried at nextAttemptAt,
+// or moved to the dead-letter status if dead is true.
+func (r *Repository) MarkFailed(
+	ctx context.Context,
+	messageID uuid.UUID,
+	delivered []string,
+	lastError string,
+	nextAttemptAt time.Time,
+	dead bool,
+) error {
+	query := `
+		UPDATE outbox
+		SET status = CASE WHEN $4 THEN 'dead' ELSE 'pending' END,
+		    attempts = attempts + 1,
+		    delivered_channels = $5,
+		    last_error = $2,
+		    next_attempt_at = $3,
+		    updated_at = NOW()
+		WHERE id = $1;
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, messageID, lastError, nextAttemptAt, dead, pq.Array(delivered)); err != nil {
+		return fmt.Errorf("failed to mark message failed: %w", err)
+	}
+
+	return nil
+}
+
+// GetMessages retrieves the most recently updated messages with the given status.
+func (r *Repository) GetMessages(ctx context.Context, status string, limit int) ([]*model.OutboxMessage, error) {
+	query := `
+		SELECT id, user_id, recipient, template, locale, payload, status, attempts, delivered_channels,
+		       COALESCE(last_error, ''), next_attempt_at, sent_at, created_at, updated_at
+		FROM outbox
+		WHERE status = $1
+		ORDER BY updated_at DESC
+		LIMIT $2;
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outbox messages: %w", err)
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// ReplayMessage moves a dead message back to pending with a fresh attempt budget.
+func (r *Repository) ReplayMessage(ctx context.Context, messageID uuid.UUID) error {
+	query := `
+		UPDATE outbox
+		SET status = 'pending',
+		    attempts = 0,
+		    next_attempt_at = NOW(),
+		    updated_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+		RETURNING id;
+	`
+
+	var id uuid.UUID
+	err := r.db.Master.QueryRowContext(ctx, query, messageID).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrMessageNotFoundOrNotDead
+		}
+
+		return fmt.Errorf("failed to replay message: %w", err)
+	}
+
+	return nil
+}
+
+// scanMessages reads outbox messages from rows.
+func scanMessages(rows *sql.Rows) ([]*model.OutboxMessage, error) {
+	var messages []*model.OutboxMessage
+	for rows.Next() {
+		var m model.OutboxMessage
+		err := rows.Scan(
+			&m.ID, &m.UserID, &m.Recipient, &m.Template, &m.Locale, &m.Payload, &m.Status, &m.Attempts,
+			pq.Array(&m.DeliveredChannels), &m.LastError, &m.NextAttemptAt, &m.SentAt, &m.CreatedAt, &m.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("scan outbox message: %w", err)
+		}
+		messages = append(messages, &m)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return messages, nil
+}
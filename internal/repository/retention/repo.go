@@ -0,0 +1,250 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// Repository provides methods to move old events and bookings out of the hot tables.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new retention repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// ArchiveEvents moves up to limit events that took place more than olderThan ago,
+// together with all their bookings and seat drifts, into the archive tables in
+// a single statement.
+// Events locked by a concurrent run are skipped. It returns the number of
+// archived events and bookings.
+func (r *Repository) ArchiveEvents(ctx context.Context, olderThan time.Duration, limit int) (int, int, error) {
+	query := `
+		WITH old AS (
+			SELECT id
+			FROM events
+			WHERE date < NOW() - make_interval(secs => $1)
+			ORDER BY date
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		), moved_bookings AS (
+			DELETE FROM bookings b
+			USING old
+			WHERE b.event_id = old.id
+			RETURNING b.id, b.event_id, b.user_id, b.status, b.expires_at, b.warned_at, b.created_at, b.updated_at,
+			          b.guest
+		), archived_bookings AS (
+			INSERT INTO archived_bookings (id, event_id, user_id, status, expires_at, warned_at, created_at, updated_at,
+			                               guest)
+			SELECT id, event_id, user_id, status, expires_at, warned_at, created_at, updated_at, guest
+			FROM moved_bookings
+			RETURNING 1
+		), moved_drifts AS (
+			DELETE FROM seat_drifts d
+			USING old
+			WHERE d.event_id = old.id
+			RETURNING d.id, d.event_id, d.total_seats, d.available_seats, d.active_bookings, d.corrected, d.detected_at
+		), archived_drifts AS (
+			INSERT INTO archived_seat_drifts (id, event_id, total_seats, available_seats, active_bookings, corrected,
+			                                  detected_at)
+			SELECT id, event_id, total_seats, available_seats, active_bookings, corrected, detected_at
+			FROM moved_drifts
+		), moved_events AS (
+			DELETE FROM events e
+			USING old
+			WHERE e.id = old.id
+			RETURNING e.id, e.title, e.date, e.total_seats, e.available_seats, e.booking_ttl, e.seat_strategy,
+			          e.created_at, e.updated_at, e.organizer_id
+		), archived_events AS (
+			INSERT INTO archived_events (id, title, date, total_seats, available_seats, booking_ttl, seat_strategy,
+			                             created_at, updated_at, organizer_id)
+			SELECT id, title, date, total_seats, available_seats, booking_ttl, seat_strategy, created_at, updated_at,
+			       organizer_id
+			FROM moved_events
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM archived_events), (SELECT COUNT(*) FROM archived_bookings);
+	`
+
+	var events, bookings int
+	err := r.db.Master.QueryRowContext(ctx, query, olderThan.Seconds(), limit).Scan(&events, &bookings)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to archive events: %w", err)
+	}
+
+	return events, bookings, nil
+}
+
+// DeleteCancelledBookings deletes up to limit bookings that were cancelled more
+// than olderThan ago and returns how many were deleted.
+func (r *Repository) DeleteCancelledBookings(ctx context.Context, olderThan time.Duration, limit int) (int, error) {
+	query := `
+		DELETE FROM bookings
+		WHERE id IN (
+			SELECT id
+			FROM bookings
+			WHERE status = 'cancelled' AND updated_at < NOW() - make_interval(secs => $1)
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		);
+	`
+
+	res, err := r.db.ExecContext(ctx, query, olderThan.Seconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete cancelled bookings: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// DeleteExpiredTokens deletes up to limit expired refresh tokens, revocation
+// entries of expired access tokens, expired emailed user tokens and abandoned
+// OpenID Connect logins each, and returns how many were deleted.
+func (r *Repository) DeleteExpiredTokens(ctx context.Context, limit int) (int, error) {
+	query := `
+		WITH refresh AS (
+			DELETE FROM refresh_tokens
+			WHERE id IN (
+				SELECT id
+				FROM refresh_tokens
+				WHERE expires_at < NOW()
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING 1
+		), revoked AS (
+			DELETE FROM revoked_tokens
+			WHERE jti IN (
+				SELECT jti
+				FROM revoked_tokens
+				WHERE expires_at < NOW()
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING 1
+		), emailed AS (
+			DELETE FROM user_tokens
+			WHERE id IN (
+				SELECT id
+				FROM user_tokens
+				WHERE expires_at < NOW()
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING 1
+		), oidc AS (
+			DELETE FROM oidc_states
+			WHERE state_hash IN (
+				SELECT state_hash
+				FROM oidc_states
+				WHERE expires_at < NOW()
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM refresh) + (SELECT COUNT(*) FROM revoked) + (SELECT COUNT(*) FROM emailed) +
+		       (SELECT COUNT(*) FROM oidc);
+	`
+
+	var n int
+	if err := r.db.Master.QueryRowContext(ctx, query, limit).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to delete expired tokens: %w", err)
+	}
+
+	return n, nil
+}
+
+// DeleteLoginRecords deletes up to limit failed login records and up to limit
+// login attempt counters older than olderThan each, and returns how many were deleted.
+func (r *Repository) DeleteLoginRecords(ctx context.Context, olderThan time.Duration, limit int) (int, error) {
+	query := `
+		WITH failures AS (
+			DELETE FROM login_failures
+			WHERE id IN (
+				SELECT id
+				FROM login_failures
+				WHERE created_at < NOW() - make_interval(secs => $1)
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING 1
+		), counters AS (
+			DELETE FROM login_counters
+			WHERE key IN (
+				SELECT key
+				FROM login_counters
+				WHERE last_attempt_at < NOW() - make_interval(secs => $1)
+				LIMIT $2
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING 1
+		)
+		SELECT (SELECT COUNT(*) FROM failures) + (SELECT COUNT(*) FROM counters);
+	`
+
+	var n int
+	if err := r.db.Master.QueryRowContext(ctx, query, olderThan.Seconds(), limit).Scan(&n); err != nil {
+		return 0, fmt.Errorf("failed to delete login records: %w", err)
+	}
+
+	return n, nil
+}
+
+// DeleteJobRuns deletes up to limit finished job runs that started more than
+// olderThan ago and returns how many were deleted. Runs still in progress are kept.
+func (r *Repository) DeleteJobRuns(ctx context.Context, olderThan time.Duration, limit int) (int, error) {
+	query := `
+		DELETE FROM job_runs
+		WHERE id IN (
+			SELECT id
+			FROM job_runs
+			WHERE finished_at IS NOT NULL AND started_at < NOW() - make_interval(secs => $1)
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		);
+	`
+
+	res, err := r.db.ExecContext(ctx, query, olderThan.Seconds(), limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete job runs: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to check rows affected: %w", err)
+	}
+
+	return int(rows), nil
+}
+
+// GetReport retrieves the archived volumes.
+func (r *Repository) GetReport(ctx context.Context) (*model.RetentionReport, error) {
+	query := `
+		SELECT (SELECT COUNT(*) FROM archived_events),
+		       (SELECT COUNT(*) FROM archived_bookings),
+		       pg_total_relation_size('archived_events') + pg_total_relation_size('archived_bookings'),
+		       GREATEST((SELECT MAX(archived_at) FROM archived_events), (SELECT MAX(archived_at) FROM archived_bookings));
+	`
+
+	var report model.RetentionReport
+	err := r.db.QueryRowContext(ctx, query).Scan(
+		&report.ArchivedEvents, &report.ArchivedBookings, &report.ArchiveBytes, &report.LastArchivedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention report: %w", err)
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,362 @@
+package session
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/database"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+var (
+	ErrRefreshTokenNotFound = errors.New("refresh token not found")
+	ErrRefreshTokenRevoked  = errors.New("refresh token revoked")
+	ErrUserTokenNotFound    = errors.New("user token not found, used or expired")
+	ErrOIDCStateNotFound    = errors.New("oidc state not found, used or expired")
+)
+
+// Repository provides methods to interact with refresh_tokens, revoked_tokens and user_tokens tables.
+type Repository struct {
+	db *database.DB
+}
+
+// NewRepository creates a new session repository.
+func NewRepository(db *database.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CreateRefreshToken stores a new refresh token and sets its id.
+func (r *Repository) CreateRefreshToken(ctx context.Context, t *model.RefreshToken) error {
+	return createRefreshToken(ctx, r.db.Master, t)
+}
+
+// GetRefreshToken retrieves a refresh token by its hash.
+func (r *Repository) GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error) {
+	query := `
+		SELECT id, user_id, family_id, token_hash, access_jti, access_expires_at, expires_at, revoked_at, replaced_by, mfa
+		FROM refresh_tokens
+		WHERE token_hash = $1;
+	`
+
+	var t model.RefreshToken
+	err := r.db.Master.QueryRowContext(ctx, query, tokenHash).Scan(
+		&t.ID, &t.UserID, &t.FamilyID, &t.TokenHash, &t.AccessJTI, &t.AccessExpiresAt, &t.ExpiresAt,
+		&t.RevokedAt, &t.ReplacedBy, &t.MFA,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRefreshTokenNotFound
+		}
+
+		return nil, fmt.Errorf("failed to query refresh token: %w", err)
+	}
+
+	return &t, nil
+}
+
+// RotateRefreshToken revokes the refresh token oldID, together with the access
+// token issued with it, and stores next as its replacement.
+// It returns ErrRefreshTokenRevoked if oldID was already revoked, e.g. by a concurrent refresh.
+func (r *Repository) RotateRefreshToken(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = createRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+
+	revokeQuery := `
+		WITH revoked AS (
+			UPDATE refresh_tokens
+			SET revoked_at = NOW(),
+			    replaced_by = $2
+			WHERE id = $1 AND revoked_at IS NULL
+			RETURNING access_jti, access_expires_at
+		), access AS (
+			INSERT INTO revoked_tokens (jti, expires_at)
+			SELECT access_jti, access_expires_at
+			FROM revoked
+			WHERE access_expires_at > NOW()
+			ON CONFLICT (jti) DO NOTHING
+		)
+		SELECT COUNT(*) FROM revoked;
+	`
+
+	var n int
+	if err = tx.QueryRowContext(ctx, revokeQuery, oldID, next.ID).Scan(&n); err != nil {
+		return fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if n == 0 {
+		return ErrRefreshTokenRevoked
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// CreateUserToken stores a new single-use user token and sets its id.
+// Unused tokens of the same user and purpose are invalidated, so only the latest one works.
+func (r *Repository) CreateUserToken(ctx context.Context, t *model.UserToken) error {
+	query := `
+		WITH invalidated AS (
+			UPDATE user_tokens
+			SET used_at = NOW()
+			WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+		)
+		INSERT INTO user_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id;
+	`
+
+	err := r.db.Master.QueryRowContext(ctx, query, t.UserID, t.Purpose, t.TokenHash, t.ExpiresAt).Scan(&t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create user token: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserTokenOwner returns the id of the user an unused, unexpired user token
+// with the given hash and purpose belongs to, without using it up.
+// Returns ErrUserTokenNotFound if there is no such token.
+func (r *Repository) GetUserTokenOwner(ctx context.Context, tokenHash, purpose string) (uuid.UUID, error) {
+	query := `
+		SELECT user_id
+		FROM user_tokens
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW();
+	`
+
+	var userID uuid.UUID
+	if err := r.db.Master.QueryRowContext(ctx, query, tokenHash, purpose).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrUserTokenNotFound
+		}
+
+		return uuid.Nil, fmt.Errorf("failed to query user token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ConsumeUserToken marks an unused, unexpired user token with the given hash
+// and purpose as used and returns its user's id.
+// Returns ErrUserTokenNotFound if there is no such token.
+func (r *Repository) ConsumeUserToken(ctx context.Context, tokenHash, purpose string) (uuid.UUID, error) {
+	return consumeUserToken(ctx, r.db.Master, tokenHash, purpose)
+}
+
+// CreateOIDCState stores a started OpenID Connect login.
+func (r *Repository) CreateOIDCState(ctx context.Context, st *model.OIDCState) error {
+	query := `
+		INSERT INTO oidc_states (state_hash, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4);
+	`
+
+	if _, err := r.db.ExecContext(ctx, query, st.StateHash, st.Nonce, st.CodeVerifier, st.ExpiresAt); err != nil {
+		return fmt.Errorf("failed to create oidc state: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeOIDCState deletes the unexpired OpenID Connect login with the given
+// state hash and returns it, so that each state is used once.
+// Returns ErrOIDCStateNotFound if there is no such login.
+func (r *Repository) ConsumeOIDCState(ctx context.Context, stateHash string) (*model.OIDCState, error) {
+	query := `
+		DELETE FROM oidc_states
+		WHERE state_hash = $1 AND expires_at > NOW()
+		RETURNING state_hash, nonce, code_verifier, expires_at;
+	`
+
+	var st model.OIDCState
+	err := r.db.Master.QueryRowContext(ctx, query, stateHash).Scan(&st.StateHash, &st.Nonce, &st.CodeVerifier, &st.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOIDCStateNotFound
+		}
+
+		return nil, fmt.Errorf("failed to consume oidc state: %w", err)
+	}
+
+	return &st, nil
+}
+
+// ResetPassword consumes a password reset token, sets the password hash of its
+// user, drops a pending email change and revokes all of the user's sessions,
+// in one transaction.
+// It returns the user's id, or ErrUserTokenNotFound if the token is unknown, used or expired.
+func (r *Repository) ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error) {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	userID, err := consumeUserToken(ctx, tx, tokenHash, model.TokenPurposePasswordReset)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	query := `UPDATE users SET password_hash = $2, pending_email = NULL WHERE id = $1;`
+	if _, err = tx.ExecContext(ctx, query, userID, passwordHash); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to update password: %w", err)
+	}
+
+	if err = revoke(ctx, tx, "user_id = $1", userID); err != nil {
+		return uuid.Nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return uuid.Nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return userID, nil
+}
+
+// ChangePassword sets a new password hash for a user, drops a pending email
+// change, invalidates unused password reset links and revokes all sessions of
+// the user.
+func (r *Repository) ChangePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE users SET password_hash = $2, pending_email = NULL WHERE id = $1;`
+	if _, err = tx.ExecContext(ctx, query, userID, passwordHash); err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+
+	query = `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL;
+	`
+	if _, err = tx.ExecContext(ctx, query, userID, model.TokenPurposePasswordReset); err != nil {
+		return fmt.Errorf("failed to invalidate reset tokens: %w", err)
+	}
+
+	if err = revoke(ctx, tx, "user_id = $1", userID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeFamily revokes all refresh tokens of a family and their access tokens.
+func (r *Repository) RevokeFamily(ctx context.Context, familyID uuid.UUID) error {
+	return revoke(ctx, r.db.Master, "family_id = $1", familyID)
+}
+
+// RevokeFamilyOfAccessToken revokes the family of the refresh token issued
+// with the given access token, and the access tokens of that family.
+func (r *Repository) RevokeFamilyOfAccessToken(ctx context.Context, jti uuid.UUID) error {
+	return revoke(ctx, r.db.Master, "family_id IN (SELECT family_id FROM refresh_tokens WHERE access_jti = $1)", jti)
+}
+
+// RevokeUserTokens revokes all refresh and access tokens of a user.
+func (r *Repository) RevokeUserTokens(ctx context.Context, userID uuid.UUID) error {
+	return revoke(ctx, r.db.Master, "user_id = $1", userID)
+}
+
+// IsAccessTokenRevoked checks whether an access token was revoked.
+func (r *Repository) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)`
+
+	var revoked bool
+	if err := r.db.Master.QueryRowContext(ctx, query, jti).Scan(&revoked); err != nil {
+		return false, fmt.Errorf("failed to check if token is revoked: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// revoke revokes the refresh tokens matching cond, which uses $1, and puts the
+// access tokens issued with them that have not expired yet on the revocation list.
+func revoke(ctx context.Context, q querier, cond string, arg any) error {
+	query := `
+		WITH matched AS (
+			SELECT id, access_jti, access_expires_at
+			FROM refresh_tokens
+			WHERE ` + cond + `
+		), revoked AS (
+			UPDATE refresh_tokens t
+			SET revoked_at = NOW()
+			FROM matched m
+			WHERE t.id = m.id AND t.revoked_at IS NULL
+		)
+		INSERT INTO revoked_tokens (jti, expires_at)
+		SELECT access_jti, access_expires_at
+		FROM matched
+		WHERE access_expires_at > NOW()
+		ON CONFLICT (jti) DO NOTHING;
+	`
+
+	if _, err := q.ExecContext(ctx, query, arg); err != nil {
+		return fmt.Errorf("failed to revoke tokens: %w", err)
+	}
+
+	return nil
+}
+
+// querier is implemented by both *sql.DB and *sql.Tx.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// consumeUserToken marks an unused, unexpired user token with the given hash
+// and purpose as used and returns its user's id.
+func consumeUserToken(ctx context.Context, q querier, tokenHash, purpose string) (uuid.UUID, error) {
+	query := `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id;
+	`
+
+	var userID uuid.UUID
+	if err := q.QueryRowContext(ctx, query, tokenHash, purpose).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return uuid.Nil, ErrUserTokenNotFound
+		}
+
+		return uuid.Nil, fmt.Errorf("failed to consume user token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// createRefreshToken inserts a refresh token and sets its id.
+func createRefreshToken(ctx context.Context, q querier, t *model.RefreshToken) error {
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, access_jti, access_expires_at, expires_at, mfa)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id;
+	`
+
+	err := q.QueryRowContext(
+		ctx, query, t.UserID, t.FamilyID, t.TokenHash, t.AccessJTI, t.AccessExpiresAt, t.ExpiresAt, t.MFA,
+	).Scan(&t.ID)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+
+	return nil
+}
@@ -5,36 +5,44 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/uuid"
-	"github.com/wb-go/wbf/dbpg"
+	"github.com/lib/pq"
 
+	"github.com/aliskhannn/event-booker/internal/database"
 	"github.com/aliskhannn/event-booker/internal/model"
 )
 
-var ErrUserNotFound = errors.New("user not found")
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrEmailTaken   = errors.New("email is already taken")
+)
+
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation.
+const uniqueViolation = "23505"
 
 // Repository provides methods to interact with users table.
 type Repository struct {
-	db *dbpg.DB
+	db *database.DB
 }
 
 // NewRepository creates a new user repository.
-func NewRepository(db *dbpg.DB) *Repository {
+func NewRepository(db *database.DB) *Repository {
 	return &Repository{db: db}
 }
 
 // CreateUser adds a new user to the database.
 func (r *Repository) CreateUser(ctx context.Context, user *model.User) (uuid.UUID, error) {
 	query := `
-		INSERT INTO users (email, password_hash, name)
-		VALUES ($1, $2, $3)
-		RETURNING id;
+		INSERT INTO users (email, password_hash, name, locale, timezone)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, role;
 	`
 
-	err := r.db.QueryRowContext(
-		ctx, query, user.Email, user.Password, user.Name,
-	).Scan(&user.ID)
+	err := r.db.Master.QueryRowContext(
+		ctx, query, user.Email, user.Password, user.Name, user.Locale, user.Timezone,
+	).Scan(&user.ID, &user.Role)
 	if err != nil {
 		return uuid.Nil, fmt.Errorf("failed to create user: %w", err)
 	}
@@ -42,16 +50,41 @@ func (r *Repository) CreateUser(ctx context.Context, user *model.User) (uuid.UUI
 	return user.ID, nil
 }
 
+// CreateGuestUser adds a lightweight user for a guest booking: no password
+// and an unverified email, until the guest follows the emailed link. It sets
+// the user's id. Returns ErrEmailTaken if a user has the email.
+func (r *Repository) CreateGuestUser(ctx context.Context, user *model.User) error {
+	query := `
+		INSERT INTO users (email, password_hash, name, locale, timezone)
+		VALUES ($1, '', $2, $3, $4)
+		RETURNING id, role, created_at;
+	`
+
+	err := r.db.Master.QueryRowContext(ctx, query, user.Email, user.Name, user.Locale, user.Timezone).Scan(
+		&user.ID, &user.Role, &user.CreatedAt,
+	)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrEmailTaken
+		}
+
+		return fmt.Errorf("failed to create guest user: %w", err)
+	}
+
+	return nil
+}
+
 // GetUserByID retrieves a user by id.
 func (r *Repository) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
 	query := `
-        SELECT id, email, name, created_at
+        SELECT id, email, name, locale, timezone, role, created_at, verified_at, pending_email
         FROM users
         WHERE id = $1
     `
 	var u model.User
 	err := r.db.QueryRowContext(ctx, query, userID).Scan(
-		&u.ID, &u.Email, &u.Name, &u.CreatedAt,
+		&u.ID, &u.Email, &u.Name, &u.Locale, &u.Timezone, &u.Role, &u.CreatedAt, &u.VerifiedAt, &u.PendingEmail,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -65,7 +98,7 @@ func (r *Repository) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.
 // GetUserByEmail retrieves a user by email.
 func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*model.User, error) {
 	query := `
-		SELECT id, email, password_hash, name, created_at
+		SELECT id, email, password_hash, name, locale, timezone, role, created_at, verified_at, pending_email
 		FROM users
 		WHERE email = $1
 	`
@@ -76,7 +109,48 @@ func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*model.U
 		&user.Email,
 		&user.Password,
 		&user.Name,
+		&user.Locale,
+		&user.Timezone,
+		&user.Role,
+		&user.CreatedAt,
+		&user.VerifiedAt,
+		&user.PendingEmail,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+
+	return &user, nil
+}
+
+// GetUserByEmailFold retrieves a user by email, ignoring case. Emails are
+// stored as entered, so several users may match; a verified user is
+// preferred, then the oldest one.
+func (r *Repository) GetUserByEmailFold(ctx context.Context, email string) (*model.User, error) {
+	query := `
+		SELECT id, email, password_hash, name, locale, timezone, role, created_at, verified_at, pending_email
+		FROM users
+		WHERE lower(email) = lower($1)
+		ORDER BY verified_at IS NULL, created_at
+		LIMIT 1
+	`
+
+	var user model.User
+	err := r.db.QueryRowContext(ctx, query, email).Scan(
+		&user.ID,
+		&user.Email,
+		&user.Password,
+		&user.Name,
+		&user.Locale,
+		&user.Timezone,
+		&user.Role,
 		&user.CreatedAt,
+		&user.VerifiedAt,
+		&user.PendingEmail,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -101,3 +175,388 @@ func (r *Repository) CheckUserExistsByEmail(ctx context.Context, email string) (
 
 	return exists, nil
 }
+
+// MarkEmailVerified marks the email address of a user as verified, unless it
+// was verified before. The email must still be the user's current one.
+// Returns ErrUserNotFound if no user has the given id and email.
+func (r *Repository) MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error {
+	query := `
+		UPDATE users
+		SET verified_at = COALESCE(verified_at, NOW())
+		WHERE id = $1 AND email = $2;
+	`
+
+	res, err := r.db.ExecContext(ctx, query, userID, email)
+	if err != nil {
+		return fmt.Errorf("failed to mark email verified: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetPasswordHash retrieves the password hash of a user, empty if the user
+// has no password.
+func (r *Repository) GetPasswordHash(ctx context.Context, userID uuid.UUID) (string, error) {
+	query := `SELECT password_hash FROM users WHERE id = $1;`
+
+	var hash string
+	if err := r.db.QueryRowContext(ctx, query, userID).Scan(&hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", ErrUserNotFound
+		}
+
+		return "", fmt.Errorf("failed to get password hash: %w", err)
+	}
+
+	return hash, nil
+}
+
+// UpdateProfile updates the name, locale and time zone of a user and, if
+// prefs is not nil, replaces the user's notification preferences, in one
+// transaction. Returns ErrUserNotFound if no user has the given id.
+func (r *Repository) UpdateProfile(ctx context.Context, user *model.User, prefs *model.NotificationPreferences) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET name = $2, locale = $3, timezone = $4
+		WHERE id = $1;
+	`
+
+	res, err := tx.ExecContext(ctx, query, user.ID, user.Name, user.Locale, user.Timezone)
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	if prefs != nil {
+		if err := saveNotificationPreferences(ctx, tx, prefs); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SetPendingEmail records a new email address for a user, replacing any
+// earlier one. The user's email is not changed until ConfirmPendingEmail.
+// Returns ErrUserNotFound if no user has the given id.
+func (r *Repository) SetPendingEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	query := `UPDATE users SET pending_email = $2 WHERE id = $1;`
+
+	res, err := r.db.ExecContext(ctx, query, userID, email)
+	if err != nil {
+		return fmt.Errorf("failed to set pending email: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// ConfirmPendingEmail makes the pending email address of a user, which must
+// still be email, the user's verified email. Unused login and password reset
+// links sent to the old address stop working.
+// Returns ErrEmailTaken if another user has the email in the meantime and
+// ErrUserNotFound if no user has the given id and pending email.
+func (r *Repository) ConfirmPendingEmail(ctx context.Context, userID uuid.UUID, email string) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET email = pending_email, pending_email = NULL, verified_at = NOW()
+		WHERE id = $1 AND pending_email = $2;
+	`
+
+	res, err := tx.ExecContext(ctx, query, userID, email)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return ErrEmailTaken
+		}
+
+		return fmt.Errorf("failed to confirm pending email: %w", err)
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrUserNotFound
+	}
+
+	query = `
+		UPDATE user_tokens
+		SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = ANY($2) AND used_at IS NULL;
+	`
+
+	purposes := []string{model.TokenPurposePasswordReset, model.TokenPurposeMagicLink}
+	if _, err = tx.ExecContext(ctx, query, userID, pq.Array(purposes)); err != nil {
+		return fmt.Errorf("failed to invalidate user tokens: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserByIdentity retrieves the user linked to the account subject at the OpenID Connect provider issuer.
+func (r *Repository) GetUserByIdentity(ctx context.Context, issuer, subject string) (*model.User, error) {
+	query := `
+		SELECT u.id, u.email, u.name, u.locale, u.timezone, u.role, u.created_at, u.verified_at, u.pending_email
+		FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.issuer = $1 AND i.subject = $2;
+	`
+
+	var u model.User
+	err := r.db.Master.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&u.ID, &u.Email, &u.Name, &u.Locale, &u.Timezone, &u.Role, &u.CreatedAt, &u.VerifiedAt, &u.PendingEmail,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get user by identity: %w", err)
+	}
+
+	return &u, nil
+}
+
+// LinkIdentity links a provider account to an existing user and marks the
+// user's email verified. An unverified user is claimed first, as by
+// ClaimUnverifiedUser, so only the provider's account holder gets in.
+func (r *Repository) LinkIdentity(ctx context.Context, identity *model.UserIdentity) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = claimUnverifiedUser(ctx, tx, identity.UserID); err != nil {
+		return err
+	}
+
+	if err = createIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimUnverifiedUser marks the email of a user verified for its owner, who
+// proved owning it. If it was not verified before, whoever set up the account
+// never did, so the password and notification preferences are removed and a
+// pending email change is dropped. A verified user is left unchanged.
+// Returns ErrUserNotFound if no user has the given id.
+func (r *Repository) ClaimUnverifiedUser(ctx context.Context, userID uuid.UUID) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err = claimUnverifiedUser(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// claimUnverifiedUser locks a user in tx and, if the email is unverified,
+// verifies it and resets what the account's creator set up.
+func claimUnverifiedUser(ctx context.Context, tx *sql.Tx, userID uuid.UUID) error {
+	var verifiedAt *time.Time
+	query := `SELECT verified_at FROM users WHERE id = $1 FOR UPDATE;`
+	if err := tx.QueryRowContext(ctx, query, userID).Scan(&verifiedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrUserNotFound
+		}
+
+		return fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	if verifiedAt != nil {
+		return nil
+	}
+
+	query = `UPDATE users SET password_hash = '', pending_email = NULL, verified_at = NOW() WHERE id = $1;`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to reset unverified user: %w", err)
+	}
+
+	query = `DELETE FROM notification_preferences WHERE user_id = $1;`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return fmt.Errorf("failed to reset notification preferences: %w", err)
+	}
+
+	return nil
+}
+
+// CreateUserWithIdentity creates a user with a verified email and no password,
+// linked to a provider account, in one transaction. It sets the ids of both.
+func (r *Repository) CreateUserWithIdentity(ctx context.Context, user *model.User, identity *model.UserIdentity) error {
+	tx, err := r.db.Master.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO users (email, password_hash, name, locale, timezone, verified_at)
+		VALUES ($1, '', $2, $3, $4, NOW())
+		RETURNING id, role, created_at, verified_at;
+	`
+
+	err = tx.QueryRowContext(ctx, query, user.Email, user.Name, user.Locale, user.Timezone).Scan(
+		&user.ID, &user.Role, &user.CreatedAt, &user.VerifiedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+
+	identity.UserID = user.ID
+	if err = createIdentity(ctx, tx, identity); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// createIdentity inserts a provider account link and sets its id.
+func createIdentity(ctx context.Context, tx *sql.Tx, identity *model.UserIdentity) error {
+	query := `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at;
+	`
+
+	err := tx.QueryRowContext(ctx, query, identity.UserID, identity.Issuer, identity.Subject, identity.Email).
+		Scan(&identity.ID, &identity.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create user identity: %w", err)
+	}
+
+	return nil
+}
+
+// GetNotificationPreferences retrieves the notification preferences of a user.
+// Users who never saved preferences get the defaults: email only, no opt-out.
+func (r *Repository) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error) {
+	query := `
+		SELECT u.id,
+		       COALESCE(p.channels, '{email}'),
+		       COALESCE(p.webhook_url, ''),
+		       COALESCE(p.telegram_chat_id, ''),
+		       COALESCE(p.opt_out_non_essential, FALSE)
+		FROM users u
+		LEFT JOIN notification_preferences p ON p.user_id = u.id
+		WHERE u.id = $1;
+	`
+
+	var prefs model.NotificationPreferences
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&prefs.UserID,
+		pq.Array(&prefs.Channels),
+		&prefs.WebhookURL,
+		&prefs.TelegramChatID,
+		&prefs.OptOutNonEssential,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get notification preferences: %w", err)
+	}
+
+	return &prefs, nil
+}
+
+// SaveNotificationPreferences creates or replaces the notification preferences of a user.
+func (r *Repository) SaveNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) error {
+	return saveNotificationPreferences(ctx, r.db.Master, prefs)
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// saveNotificationPreferences creates or replaces the notification preferences of a user.
+func saveNotificationPreferences(ctx context.Context, e execer, prefs *model.NotificationPreferences) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, channels, webhook_url, telegram_chat_id, opt_out_non_essential)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE
+		SET channels = EXCLUDED.channels,
+		    webhook_url = EXCLUDED.webhook_url,
+		    telegram_chat_id = EXCLUDED.telegram_chat_id,
+		    opt_out_non_essential = EXCLUDED.opt_out_non_essential,
+		    updated_at = NOW();
+	`
+
+	_, err := e.ExecContext(
+		ctx, query,
+		prefs.UserID,
+		pq.Array(prefs.Channels),
+		prefs.WebhookURL,
+		prefs.TelegramChatID,
+		prefs.OptOutNonEssential,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save notification preferences: %w", err)
+	}
+
+	return nil
+}
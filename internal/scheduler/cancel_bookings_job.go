@@ -4,56 +4,34 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/google/uuid"
 	"github.com/wb-go/wbf/zlog"
-
-	"github.com/aliskhannn/event-booker/internal/model"
 )
 
-// userService defines the user service interface used by the CancelExpiredBookingsJob.
-type userService interface {
-	// GetUserByID returns user info.
-	GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error)
-}
+// expiryBatchSize is the number of bookings cancelled per statement.
+const expiryBatchSize = 500
 
 // eventService defines the event-related business logic interface
 // that the CancelExpiredBookingsJob depends on.
 type eventService interface {
-	// GetEventByID returns event info with available seats.
-	GetEventByID(ctx context.Context, eventID uuid.UUID) (*model.Event, error)
-
-	// GetExpiredBookings returns all expired bookings (background job).
-	GetExpiredBookings(ctx context.Context) ([]*model.Booking, error)
-
-	// CancelExpiredBooking cancels a booking (background job).
-	CancelExpiredBooking(ctx context.Context, bookingID uuid.UUID) error
+	// CancelExpiredBookings cancels all expired bookings in batches and returns how many were cancelled.
+	CancelExpiredBookings(ctx context.Context, batchSize int) (int, error)
 }
 
-// notifier defines an interface for sending notifications through a channel.
-type notifier interface {
-	// Send sends a notification message to the specified recipient.
-	Send(to string, msg string) error
-}
-
-// CancelExpiredBookingsJob is a background job that cancels expired bookings
-// and notifies users via email.
+// CancelExpiredBookingsJob is a background job that cancels expired bookings.
+// Users are notified through the outbox, which is written in the same
+// statement as the cancellation.
+//
+// Bookings are normally cancelled on time by the ExpiryQueue; this job is
+// the safety net for bookings it missed, e.g. ones created on another instance.
 type CancelExpiredBookingsJob struct {
-	userService  userService
 	eventService eventService
-	notifier     notifier
 }
 
 // NewCancelExpiredBookingsJob creates a new instance of CancelExpiredBookingsJob
-// with the required user service, event service, and notifier.
-func NewCancelExpiredBookingsJob(
-	userSvc userService,
-	eventSvc eventService,
-	notifier notifier,
-) *CancelExpiredBookingsJob {
+// with the required event service.
+func NewCancelExpiredBookingsJob(eventSvc eventService) *CancelExpiredBookingsJob {
 	return &CancelExpiredBookingsJob{
-		userService:  userSvc,
 		eventService: eventSvc,
-		notifier:     notifier,
 	}
 }
 
@@ -67,41 +45,17 @@ func (j *CancelExpiredBookingsJob) Schedule() string {
 	return "*/30 * * * * *" // runs every 30 seconds
 }
 
-// Run executes the job logic: cancel expired bookings and notify users.
-func (j *CancelExpiredBookingsJob) Run(ctx context.Context) error {
-	// Retrieve all expired bookings.
-	booking, err := j.eventService.GetExpiredBookings(ctx)
+// Run executes the job logic: cancel expired bookings batch by batch until none are left.
+func (j *CancelExpiredBookingsJob) Run(ctx context.Context) (int, error) {
+	n, err := j.eventService.CancelExpiredBookings(ctx, expiryBatchSize)
 	if err != nil {
-		return fmt.Errorf("failed to get expired bookings: %w", err)
+		// Batches committed before the error count as processed.
+		return n, fmt.Errorf("failed to cancel expired bookings: %w", err)
 	}
 
-	for _, b := range booking {
-		// Cancel booking.
-		if err := j.eventService.CancelExpiredBooking(ctx, b.ID); err != nil {
-			zlog.Logger.Printf("failed to cancel booking %s: %v", b.ID, err)
-			continue
-		}
-
-		// Get user by id.
-		user, err := j.userService.GetUserByID(ctx, b.UserID)
-		if err != nil {
-			zlog.Logger.Printf("failed to get user %s: %v", b.UserID, err)
-			continue
-		}
-
-		// Get event by id.
-		event, err := j.eventService.GetEventByID(ctx, b.EventID)
-		if err != nil {
-			zlog.Logger.Printf("failed to get event %s: %v", b.EventID, err)
-			continue
-		}
-
-		// Notify user.
-		message := fmt.Sprintf("Your booking for the event \"%s\" has been canceled due to expiration.", event.Title)
-		if err := j.notifier.Send(user.Email, message); err != nil {
-			zlog.Logger.Printf("failed to send notification for booking %s: %v", b.ID, err)
-		}
+	if n > 0 {
+		zlog.Logger.Printf("cancelled %d expired bookings", n)
 	}
 
-	return nil
+	return n, nil
 }
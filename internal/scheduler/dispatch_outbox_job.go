@@ -0,0 +1,54 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// outboxService defines the outbox delivery interface
+// that the DispatchOutboxJob depends on.
+type outboxService interface {
+	// Dispatch delivers one batch of due messages and returns how many were claimed.
+	Dispatch(ctx context.Context) (int, error)
+}
+
+// DispatchOutboxJob is a background job that delivers notifications
+// written to the outbox.
+type DispatchOutboxJob struct {
+	outboxService outboxService
+}
+
+// NewDispatchOutboxJob creates a new instance of DispatchOutboxJob.
+func NewDispatchOutboxJob(outboxSvc outboxService) *DispatchOutboxJob {
+	return &DispatchOutboxJob{outboxService: outboxSvc}
+}
+
+// Name returns the name of the job.
+func (j *DispatchOutboxJob) Name() string {
+	return "DispatchOutboxJob"
+}
+
+// Schedule returns the cron schedule for the job.
+func (j *DispatchOutboxJob) Schedule() string {
+	return "*/5 * * * * *" // runs every 5 seconds
+}
+
+// Run executes the job logic: deliver due outbox messages batch by batch
+// until none are left. It returns the total number of messages claimed.
+func (j *DispatchOutboxJob) Run(ctx context.Context) (int, error) {
+	total := 0
+	for {
+		n, err := j.outboxService.Dispatch(ctx)
+		if err != nil {
+			return total, fmt.Errorf("failed to dispatch outbox: %w", err)
+		}
+		if n == 0 {
+			return total, nil
+		}
+		total += n
+
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
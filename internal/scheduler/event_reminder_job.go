@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// reminderService defines the event-related business logic interface
+// that the EventReminderJob depends on.
+type reminderService interface {
+	// SendEventReminders enqueues reminders for confirmed bookings of upcoming events (background job).
+	SendEventReminders(ctx context.Context, offsets []time.Duration) (int, error)
+}
+
+// EventReminderJob is a background job that reminds attendees with confirmed
+// bookings about upcoming events at the configured offsets before the event.
+type EventReminderJob struct {
+	eventService reminderService
+	offsets      []time.Duration
+}
+
+// NewEventReminderJob creates a new instance of EventReminderJob.
+func NewEventReminderJob(eventSvc reminderService, offsets []time.Duration) *EventReminderJob {
+	return &EventReminderJob{
+		eventService: eventSvc,
+		offsets:      offsets,
+	}
+}
+
+// Name returns the name of the job.
+func (j *EventReminderJob) Name() string {
+	return "EventReminderJob"
+}
+
+// Schedule returns the cron schedule for the job.
+func (j *EventReminderJob) Schedule() string {
+	return "0 * * * * *" // runs every minute
+}
+
+// Run executes the job logic: enqueue due reminders.
+func (j *EventReminderJob) Run(ctx context.Context) (int, error) {
+	n, err := j.eventService.SendEventReminders(ctx, j.offsets)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send event reminders: %w", err)
+	}
+
+	if n > 0 {
+		zlog.Logger.Printf("enqueued %d event reminders", n)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/model"
+	eventrepo "github.com/aliskhannn/event-booker/internal/repository/event"
+)
+
+const (
+	// expiryRetryDelay is how long to wait before retrying a booking the database
+	// did not consider expired yet, e.g. because of clock skew.
+	expiryRetryDelay = time.Second
+
+	// expiryMaxRetries bounds retries of a booking that stays not expired,
+	// which is also what a confirmed or cancelled booking looks like.
+	expiryMaxRetries = 2
+)
+
+// expiryService defines the event-related business logic interface
+// that the ExpiryQueue depends on.
+type expiryService interface {
+	// GetPendingBookings returns all pending bookings.
+	GetPendingBookings(ctx context.Context) ([]*model.Booking, error)
+
+	// CancelExpiredBooking cancels a booking and enqueues the expiry notification (background job).
+	CancelExpiredBooking(ctx context.Context, bookingID uuid.UUID) error
+}
+
+// expiryItem is a booking waiting for its expiration time.
+type expiryItem struct {
+	bookingID uuid.UUID
+	at        time.Time
+	retries   int
+}
+
+// expiryHeap is a min-heap of expiry items ordered by expiration time.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int           { return len(h) }
+func (h expiryHeap) Less(i, j int) bool { return h[i].at.Before(h[j].at) }
+func (h expiryHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *expiryHeap) Push(x any)        { *h = append(*h, x.(*expiryItem)) }
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
+
+// ExpiryQueue is an in-process delay queue that cancels each pending booking
+// as soon as it expires, instead of waiting for the next polling run.
+//
+// It is fed when bookings are created and rebuilt from the database on start.
+// Bookings created on other instances are left to the polling job.
+type ExpiryQueue struct {
+	mu    sync.Mutex
+	items expiryHeap
+	wake  chan struct{}
+
+	// context is passed to cancellations and cancelled by Stop.
+	context context.Context
+	cancel  context.CancelFunc
+
+	stop     chan struct{} // closed by Stop to end the run loop
+	stopOnce sync.Once
+	running  sync.WaitGroup // the run loop
+}
+
+// NewExpiryQueue creates a new empty ExpiryQueue.
+//
+// Cancellations get a context that is only cancelled by Stop, so a shutdown
+// signal does not interrupt them before Stop's deadline.
+func NewExpiryQueue() *ExpiryQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &ExpiryQueue{
+		wake:    make(chan struct{}, 1),
+		context: ctx,
+		cancel:  cancel,
+		stop:    make(chan struct{}),
+	}
+}
+
+// Add schedules a booking to be cancelled at expiresAt.
+// Adding a booking that is later confirmed or cancelled is harmless.
+func (q *ExpiryQueue) Add(bookingID uuid.UUID, expiresAt time.Time) {
+	q.push(&expiryItem{bookingID: bookingID, at: expiresAt})
+}
+
+// Start loads all pending bookings into the queue and then cancels bookings
+// as they expire until Stop is called. It returns once the queue is rebuilt.
+func (q *ExpiryQueue) Start(ctx context.Context, svc expiryService) error {
+	bookings, err := svc.GetPendingBookings(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load pending bookings: %w", err)
+	}
+
+	for _, b := range bookings {
+		q.Add(b.ID, b.ExpiresAt)
+	}
+	zlog.Logger.Printf("expiry queue rebuilt with %d pending bookings", len(bookings))
+
+	q.running.Add(1)
+	go q.run(svc)
+
+	return nil
+}
+
+// Stop stops cancelling bookings and waits for a running cancellation to
+// finish. If ctx is done first, the cancellation's context is cancelled and
+// Stop waits for it to return before returning ctx's error.
+func (q *ExpiryQueue) Stop(ctx context.Context) error {
+	q.stopOnce.Do(func() { close(q.stop) })
+
+	done := make(chan struct{})
+	go func() {
+		q.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		q.cancel()
+		return nil
+	case <-ctx.Done():
+		zlog.Logger.Warn().Msg("timeout waiting for the expiry queue, cancelling it")
+		q.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// run waits for the earliest booking to expire and cancels it, until Stop.
+func (q *ExpiryQueue) run(svc expiryService) {
+	defer q.running.Done()
+
+	timer := time.NewTimer(0)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		default:
+		}
+
+		item, wait := q.next()
+		if item != nil {
+			q.expire(q.context, svc, item)
+			continue
+		}
+
+		// An empty queue has no deadline: wait for a new booking.
+		var deadline <-chan time.Time
+		if wait > 0 {
+			timer.Reset(wait)
+			deadline = timer.C
+		}
+
+		select {
+		case <-q.stop:
+			return
+		case <-q.wake:
+		case <-deadline:
+		}
+
+		timer.Stop()
+	}
+}
+
+// next pops the earliest item if it is due. Otherwise, it returns how long
+// to wait for it, or zero if the queue is empty.
+func (q *ExpiryQueue) next() (*expiryItem, time.Duration) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return nil, 0
+	}
+
+	wait := time.Until(q.items[0].at)
+	if wait > 0 {
+		return nil, wait
+	}
+
+	return heap.Pop(&q.items).(*expiryItem), 0
+}
+
+// expire cancels an expired booking. A booking the database does not see
+// as pending and expired yet is retried a few times before being dropped.
+func (q *ExpiryQueue) expire(ctx context.Context, svc expiryService, item *expiryItem) {
+	err := svc.CancelExpiredBooking(ctx, item.bookingID)
+	if err == nil {
+		return
+	}
+
+	if errors.Is(err, eventrepo.ErrBookingNotFoundOrAlreadyCancelled) {
+		if item.retries < expiryMaxRetries {
+			item.retries++
+			item.at = time.Now().Add(expiryRetryDelay)
+			q.push(item)
+		}
+		return
+	}
+
+	zlog.Logger.Printf("failed to cancel booking %s: %v", item.bookingID, err)
+}
+
+// push adds an item and wakes up the run loop if it became the earliest.
+func (q *ExpiryQueue) push(item *expiryItem) {
+	q.mu.Lock()
+	heap.Push(&q.items, item)
+	earliest := q.items[0] == item
+	q.mu.Unlock()
+
+	if earliest {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+)
+
+// holdWarningService defines the event-related business logic interface
+// that the HoldExpiryWarningJob depends on.
+type holdWarningService interface {
+	// SendHoldExpiryWarnings warns holders of pending bookings that are about to expire (background job).
+	SendHoldExpiryWarnings(ctx context.Context, lead time.Duration, fraction float64, baseURL string) (int, error)
+}
+
+// HoldExpiryWarningJob is a background job that warns users shortly before
+// their pending booking expires, with a link to confirm it.
+type HoldExpiryWarningJob struct {
+	eventService holdWarningService
+	lead         time.Duration
+	fraction     float64
+	baseURL      string
+}
+
+// NewHoldExpiryWarningJob creates a new instance of HoldExpiryWarningJob.
+// A booking is warned when lead is left before it expires, or, if fraction
+// is set, when that fraction of its booking TTL is left.
+func NewHoldExpiryWarningJob(
+	eventSvc holdWarningService,
+	lead time.Duration,
+	fraction float64,
+	baseURL string,
+) *HoldExpiryWarningJob {
+	return &HoldExpiryWarningJob{
+		eventService: eventSvc,
+		lead:         lead,
+		fraction:     fraction,
+		baseURL:      baseURL,
+	}
+}
+
+// Name returns the name of the job.
+func (j *HoldExpiryWarningJob) Name() string {
+	return "HoldExpiryWarningJob"
+}
+
+// Schedule returns the cron schedule for the job.
+func (j *HoldExpiryWarningJob) Schedule() string {
+	return "*/15 * * * * *" // runs every 15 seconds
+}
+
+// Run executes the job logic: enqueue warnings for bookings about to expire.
+func (j *HoldExpiryWarningJob) Run(ctx context.Context) (int, error) {
+	n, err := j.eventService.SendHoldExpiryWarnings(ctx, j.lead, j.fraction, j.baseURL)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send hold expiry warnings: %w", err)
+	}
+
+	if n > 0 {
+		zlog.Logger.Printf("enqueued %d hold expiry warnings", n)
+	}
+
+	return n, nil
+}
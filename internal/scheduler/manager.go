@@ -2,11 +2,31 @@ package scheduler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/robfig/cron/v3"
 	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// recordTimeout bounds how long recording a run may take, even if the job's context is done.
+const recordTimeout = 5 * time.Second
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobDisabled = errors.New("job is disabled")
+	ErrStopped     = errors.New("scheduler is stopped")
 )
 
+// parser parses cron specs with a leading seconds field.
+var parser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
 // Job interface defines the structure for any job that will be scheduled.
 type Job interface {
 	// Name returns the name of the job.
@@ -15,46 +35,407 @@ type Job interface {
 	// Schedule returns the cron schedule for the job.
 	Schedule() string
 
-	// Run executes the job logic.
-	Run(ctx context.Context) error
+	// Run executes the job logic and returns the number of items processed.
+	Run(ctx context.Context) (int, error)
+}
+
+// locker defines the interface for cluster-wide locks that make sure
+// a job never runs on two instances at once.
+type locker interface {
+	// TryLock tries to take the lock for key without waiting.
+	// If the lock is acquired, it returns a function that releases it.
+	TryLock(ctx context.Context, key string) (func(), bool, error)
+}
+
+// store defines the interface for persisting job runs and job states.
+type store interface {
+	// StartRun records the start of a job run and returns its id.
+	StartRun(ctx context.Context, jobName, instance, trigger string) (uuid.UUID, error)
+
+	// FinishRun records the end of a job run after the given number of attempts.
+	// An empty errMsg marks the run as succeeded.
+	FinishRun(ctx context.Context, runID uuid.UUID, attempts, itemsProcessed int, errMsg string) error
+
+	// GetRuns retrieves the most recent runs of a job.
+	GetRuns(ctx context.Context, jobName string, limit int) ([]*model.JobRun, error)
+
+	// ClaimTick claims the scheduled tick of a job for a run, returning false if it was already claimed.
+	ClaimTick(ctx context.Context, jobName string, tick time.Time) (bool, error)
+
+	// IsPaused checks whether a job is paused.
+	IsPaused(ctx context.Context, jobName string) (bool, error)
+
+	// SetPaused pauses or resumes a job.
+	SetPaused(ctx context.Context, jobName string, paused bool) error
+}
+
+// registration is a registered job with its parsed schedule and configuration.
+type registration struct {
+	job      Job
+	spec     string
+	schedule cron.Schedule
+	cfg      config.Job
 }
 
 // JobManager manages all scheduled jobs.
 type JobManager struct {
 	cron    *cron.Cron
-	jobs    []Job
+	jobs    []*registration
+	entries map[string]cron.EntryID
+	locker  locker
+	store   store
+
+	// context is passed to running jobs and cancelled by Stop.
 	context context.Context
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup // in-flight runs, scheduled and manual
 }
 
 // NewJobManager creates a new JobManager instance.
-func NewJobManager(ctx context.Context) *JobManager {
+// Before each run, a job's lock is taken through locker; if another
+// instance holds it, the run is skipped. A scheduled run also claims its
+// tick in store and is skipped if another instance already ran the tick, as
+// instances whose clocks are slightly offset fire one after the other.
+// Runs and pause states are kept in store.
+//
+// Running jobs get a context that is only cancelled by Stop, so a shutdown
+// signal does not interrupt them before Stop's deadline.
+func NewJobManager(l locker, s store) *JobManager {
+	ctx, cancel := context.WithCancel(context.Background())
+
 	return &JobManager{
-		cron:    cron.New(cron.WithSeconds()), // enable seconds precision
-		jobs:    []Job{},
+		cron:    cron.New(cron.WithParser(parser)), // enable seconds precision
+		jobs:    []*registration{},
+		entries: make(map[string]cron.EntryID),
+		locker:  l,
+		store:   s,
 		context: ctx,
+		cancel:  cancel,
 	}
 }
 
 // RegisterJob adds a job to the job manager.
-func (jm *JobManager) RegisterJob(job Job) {
-	jm.jobs = append(jm.jobs, job)
+// The schedule in cfg overrides the job's own one. It returns an error
+// if the schedule is not a valid cron spec or cfg is otherwise invalid.
+func (jm *JobManager) RegisterJob(job Job, cfg config.Job) error {
+	spec := job.Schedule()
+	if cfg.Schedule != "" {
+		spec = cfg.Schedule
+	}
+
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("invalid schedule %q for job %s: %w", spec, job.Name(), err)
+	}
+
+	if cfg.Timeout < 0 || cfg.Retries < 0 || cfg.RetryBackoff < 0 {
+		return fmt.Errorf("invalid config for job %s: timeout, retries and retry backoff must not be negative", job.Name())
+	}
+
+	jm.jobs = append(jm.jobs, &registration{
+		job:      job,
+		spec:     spec,
+		schedule: schedule,
+		cfg:      cfg,
+	})
+
+	return nil
 }
 
-// StartScheduler starts the cron scheduler to execute jobs at their scheduled times.
+// StartScheduler starts the cron scheduler to execute enabled jobs at their scheduled times.
 func (jm *JobManager) StartScheduler() {
-	for _, job := range jm.jobs {
-		schedule := job.Schedule()
-
-		if _, err := jm.cron.AddFunc(schedule, func() {
-			if err := job.Run(jm.context); err != nil {
-				zlog.Logger.Error().Err(err).Str("job", job.Name()).Msg("failed to execute job")
-			} else {
-				zlog.Logger.Printf("job %s executed successfully", job.Name())
-			}
-		}); err != nil {
-			zlog.Logger.Error().Err(err).Str("job", job.Name()).Msg("failed to schedule job")
+	for _, r := range jm.jobs {
+		if !r.cfg.IsEnabled() {
+			zlog.Logger.Info().Str("job", r.job.Name()).Msg("job is disabled, not scheduling it")
+			continue
 		}
+
+		// Runs start only after cron.Start, once the entry id is set.
+		var id cron.EntryID
+		id = jm.cron.Schedule(r.schedule, cron.FuncJob(func() {
+			if jm.begin() {
+				defer jm.running.Done()
+				jm.runJob(r, model.JobTriggerSchedule, jm.cron.Entry(id).Prev)
+			}
+		}))
+		jm.entries[r.job.Name()] = id
 	}
 
 	jm.cron.Start()
 }
+
+// Stop stops scheduling new runs and waits for running jobs to finish.
+// If ctx is done first, the running jobs' contexts are cancelled and Stop
+// waits for them to return before returning ctx's error. Jobs pass their
+// context to every database call, so cancelled transactions are rolled back.
+func (jm *JobManager) Stop(ctx context.Context) error {
+	jm.mu.Lock()
+	jm.stopped = true
+	jm.mu.Unlock()
+
+	jm.cron.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		jm.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		jm.cancel()
+		return nil
+	case <-ctx.Done():
+		zlog.Logger.Warn().Msg("timeout waiting for running jobs, cancelling them")
+		jm.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// begin registers a new run unless the manager is stopped.
+// If it returns true, the caller must call jm.running.Done when the run ends.
+func (jm *JobManager) begin() bool {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	if jm.stopped {
+		return false
+	}
+
+	jm.running.Add(1)
+
+	return true
+}
+
+// Jobs returns all registered jobs with their pause state and next run time.
+func (jm *JobManager) Jobs(ctx context.Context) ([]*model.Job, error) {
+	jobs := make([]*model.Job, 0, len(jm.jobs))
+	for _, r := range jm.jobs {
+		name := r.job.Name()
+
+		paused, err := jm.store.IsPaused(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("check if job %s is paused: %w", name, err)
+		}
+
+		j := &model.Job{
+			Name:     name,
+			Schedule: r.spec,
+			Enabled:  r.cfg.IsEnabled(),
+			Paused:   paused,
+			Retries:  r.cfg.Retries,
+		}
+		if r.cfg.Timeout > 0 {
+			j.Timeout = r.cfg.Timeout.String()
+		}
+
+		if id, ok := jm.entries[name]; ok {
+			entry := jm.cron.Entry(id)
+			if !entry.Next.IsZero() {
+				j.NextRun = &entry.Next
+			}
+			if !entry.Prev.IsZero() {
+				j.PrevRun = &entry.Prev
+			}
+		}
+
+		jobs = append(jobs, j)
+	}
+
+	return jobs, nil
+}
+
+// Runs returns the most recent runs of a job.
+func (jm *JobManager) Runs(ctx context.Context, name string, limit int) ([]*model.JobRun, error) {
+	if jm.job(name) == nil {
+		return nil, ErrJobNotFound
+	}
+
+	runs, err := jm.store.GetRuns(ctx, name, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get runs: %w", err)
+	}
+
+	return runs, nil
+}
+
+// Pause stops scheduled runs of a job on all instances until it is resumed.
+func (jm *JobManager) Pause(ctx context.Context, name string) error {
+	return jm.setPaused(ctx, name, true)
+}
+
+// Resume re-enables scheduled runs of a paused job.
+func (jm *JobManager) Resume(ctx context.Context, name string) error {
+	return jm.setPaused(ctx, name, false)
+}
+
+// Trigger starts a run of a job immediately in the background, even if the job is paused.
+// The run is still skipped if another instance is running the job.
+// Disabled jobs cannot be triggered.
+func (jm *JobManager) Trigger(name string) error {
+	r := jm.job(name)
+	if r == nil {
+		return ErrJobNotFound
+	}
+	if !r.cfg.IsEnabled() {
+		return ErrJobDisabled
+	}
+	if !jm.begin() {
+		return ErrStopped
+	}
+
+	go func() {
+		defer jm.running.Done()
+		jm.runJob(r, model.JobTriggerManual, time.Time{})
+	}()
+
+	return nil
+}
+
+// setPaused pauses or resumes a job.
+func (jm *JobManager) setPaused(ctx context.Context, name string, paused bool) error {
+	if jm.job(name) == nil {
+		return ErrJobNotFound
+	}
+
+	if err := jm.store.SetPaused(ctx, name, paused); err != nil {
+		return fmt.Errorf("set paused: %w", err)
+	}
+
+	return nil
+}
+
+// job returns the registered job with the given name, or nil.
+func (jm *JobManager) job(name string) *registration {
+	for _, r := range jm.jobs {
+		if r.job.Name() == name {
+			return r
+		}
+	}
+
+	return nil
+}
+
+// runJob runs a job if it is not paused and this instance can take its lock,
+// and records the run. Scheduled runs are for the scheduled time tick, which
+// they claim so that no other instance runs it too.
+func (jm *JobManager) runJob(r *registration, trigger string, tick time.Time) {
+	name := r.job.Name()
+
+	if trigger == model.JobTriggerSchedule {
+		paused, err := jm.store.IsPaused(jm.context, name)
+		if err != nil {
+			zlog.Logger.Error().Err(err).Str("job", name).Msg("failed to check if job is paused")
+			return
+		}
+		if paused {
+			return
+		}
+	}
+
+	release, acquired, err := jm.locker.TryLock(jm.context, "job:"+name)
+	if err != nil {
+		jobFailures.Add(name, 1)
+		zlog.Logger.Error().Err(err).Str("job", name).Str("instance", instanceID).Msg("failed to take job lock")
+		return
+	}
+	if !acquired {
+		jobSkipped.Add(name, 1)
+		zlog.Logger.Debug().Str("job", name).Str("instance", instanceID).Msg("job lock held by another instance, skipping run")
+		return
+	}
+
+	jobLockHeld.Add(name, 1)
+	defer func() {
+		release()
+		jobLockHeld.Add(name, -1)
+	}()
+
+	zlog.Logger.Debug().Str("job", name).Str("instance", instanceID).Msg("job lock acquired")
+
+	if trigger == model.JobTriggerSchedule {
+		claimed, err := jm.store.ClaimTick(jm.context, name, tick)
+		if err != nil {
+			jobFailures.Add(name, 1)
+			zlog.Logger.Error().Err(err).Str("job", name).Str("instance", instanceID).Msg("failed to claim job tick")
+			return
+		}
+		if !claimed {
+			jobSkipped.Add(name, 1)
+			zlog.Logger.Debug().Str("job", name).Str("instance", instanceID).Time("tick", tick).
+				Msg("tick already run by another instance, skipping run")
+			return
+		}
+	}
+
+	// Run history is best effort: a failure to record it does not stop the job.
+	runID, err := jm.store.StartRun(jm.context, name, instanceID, trigger)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("job", name).Msg("failed to record job run start")
+	}
+
+	jobRuns.Add(name, 1)
+	items, attempts, runErr := jm.execute(r)
+
+	var errMsg string
+	if runErr != nil {
+		errMsg = runErr.Error()
+		jobFailures.Add(name, 1)
+		zlog.Logger.Error().Err(runErr).Str("job", name).Str("instance", instanceID).Msg("failed to execute job")
+	} else {
+		zlog.Logger.Printf("job %s executed successfully on %s in %d attempt(s), %d items processed", name, instanceID, attempts, items)
+	}
+
+	if runID != uuid.Nil {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(jm.context), recordTimeout)
+		defer cancel()
+
+		if err := jm.store.FinishRun(ctx, runID, attempts, items, errMsg); err != nil {
+			zlog.Logger.Error().Err(err).Str("job", name).Msg("failed to record job run end")
+		}
+	}
+}
+
+// execute runs a job, retrying failed attempts with exponential backoff
+// as configured. It returns the items processed across all attempts.
+func (jm *JobManager) execute(r *registration) (items, attempts int, err error) {
+	backoff := r.cfg.RetryBackoff
+
+	for attempts = 1; ; attempts++ {
+		var n int
+		n, err = jm.attempt(r)
+		items += n
+
+		if err == nil || attempts > r.cfg.Retries {
+			return items, attempts, err
+		}
+
+		jobRetries.Add(r.job.Name(), 1)
+		zlog.Logger.Warn().Err(err).Str("job", r.job.Name()).Int("attempt", attempts).
+			Dur("backoff", backoff).Msg("job attempt failed, retrying")
+
+		select {
+		case <-jm.context.Done():
+			return items, attempts, err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// attempt runs a job once, bounded by its configured timeout.
+func (jm *JobManager) attempt(r *registration) (int, error) {
+	ctx := jm.context
+	if r.cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.cfg.Timeout)
+		defer cancel()
+	}
+
+	return r.job.Run(ctx)
+}
@@ -0,0 +1,26 @@
+package scheduler
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+)
+
+// Job metrics, published with expvar and keyed by job name.
+var (
+	jobRuns     = expvar.NewMap("scheduler_job_runs")      // runs executed on this instance
+	jobFailures = expvar.NewMap("scheduler_job_failures")  // runs that returned an error
+	jobRetries  = expvar.NewMap("scheduler_job_retries")   // attempts retried after an error
+	jobSkipped  = expvar.NewMap("scheduler_job_skipped")   // runs skipped because another instance held the lock
+	jobLockHeld = expvar.NewMap("scheduler_job_lock_held") // 1 while this instance holds the job's lock
+)
+
+// instanceID identifies this process in logs, e.g. "event-booker-1:42".
+var instanceID = func() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
@@ -0,0 +1,45 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+)
+
+// retentionService defines the data retention interface
+// that the RetentionJob depends on.
+type retentionService interface {
+	// Apply archives past events and deletes old cancelled bookings, login records, job runs and expired
+	// tokens, returning the affected rows.
+	Apply(ctx context.Context) (int, error)
+}
+
+// RetentionJob is a background job that moves completed events and their
+// bookings into the archive tables and deletes old cancelled holds.
+type RetentionJob struct {
+	retentionService retentionService
+}
+
+// NewRetentionJob creates a new instance of RetentionJob.
+func NewRetentionJob(retentionSvc retentionService) *RetentionJob {
+	return &RetentionJob{retentionService: retentionSvc}
+}
+
+// Name returns the name of the job.
+func (j *RetentionJob) Name() string {
+	return "RetentionJob"
+}
+
+// Schedule returns the cron schedule for the job.
+func (j *RetentionJob) Schedule() string {
+	return "0 30 3 * * *" // runs every night at 03:30
+}
+
+// Run executes the job logic: apply the retention policy.
+func (j *RetentionJob) Run(ctx context.Context) (int, error) {
+	n, err := j.retentionService.Apply(ctx)
+	if err != nil {
+		return n, fmt.Errorf("failed to apply retention policy: %w", err)
+	}
+
+	return n, nil
+}
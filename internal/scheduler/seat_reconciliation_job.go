@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// seatService defines the event-related business logic interface
+// that the SeatReconciliationJob depends on.
+type seatService interface {
+	// ReconcileSeats records seat drifts and, if correct is true, fixes them.
+	ReconcileSeats(ctx context.Context, correct bool) ([]*model.SeatDrift, error)
+}
+
+// SeatReconciliationJob is a background job that checks that each event's
+// available seats equal its total seats minus its active bookings.
+// Every drift is logged and recorded; if autoCorrect is set, it is also fixed.
+type SeatReconciliationJob struct {
+	eventService seatService
+	autoCorrect  bool
+}
+
+// NewSeatReconciliationJob creates a new instance of SeatReconciliationJob.
+func NewSeatReconciliationJob(eventSvc seatService, autoCorrect bool) *SeatReconciliationJob {
+	return &SeatReconciliationJob{
+		eventService: eventSvc,
+		autoCorrect:  autoCorrect,
+	}
+}
+
+// Name returns the name of the job.
+func (j *SeatReconciliationJob) Name() string {
+	return "SeatReconciliationJob"
+}
+
+// Schedule returns the cron schedule for the job.
+func (j *SeatReconciliationJob) Schedule() string {
+	return "0 0 3 * * *" // runs every night at 03:00
+}
+
+// Run executes the job logic: detect, record and optionally correct seat drifts.
+func (j *SeatReconciliationJob) Run(ctx context.Context) (int, error) {
+	drifts, err := j.eventService.ReconcileSeats(ctx, j.autoCorrect)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reconcile seats: %w", err)
+	}
+
+	for _, d := range drifts {
+		zlog.Logger.Warn().
+			Str("event_id", d.EventID.String()).
+			Int("available_seats", d.AvailableSeats).
+			Int("expected_seats", d.ExpectedSeats).
+			Int("drift", d.Drift).
+			Bool("corrected", j.autoCorrect).
+			Msg("available seats drifted from bookings")
+	}
+
+	return len(drifts), nil
+}
@@ -0,0 +1,182 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/model"
+	apikeyrepo "github.com/aliskhannn/event-booker/internal/repository/apikey"
+)
+
+// keyPrefix starts every API key, so that leaked keys are easy to recognize.
+const keyPrefix = "ebk_"
+
+// shownPrefixLen is the length of the start of a key kept to tell keys apart.
+const shownPrefixLen = len(keyPrefix) + 8
+
+// lastUseResolution bounds how often the last use of a key is written.
+const lastUseResolution = time.Minute
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidScope   = errors.New("invalid scope")
+	ErrNoScopes       = errors.New("at least one scope is required")
+)
+
+// repository defines the interface for API key data access.
+type repository interface {
+	// CreateAPIKey stores a new API key and sets its id and creation time.
+	CreateAPIKey(ctx context.Context, k *model.APIKey) error
+
+	// GetAPIKeys retrieves all API keys of a user.
+	GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error)
+
+	// GetActiveAPIKey retrieves the unrevoked, unexpired API key with the given hash and its owner's role.
+	GetActiveAPIKey(ctx context.Context, keyHash string) (*model.APIKey, error)
+
+	// TouchAPIKey records that an API key was used from ip, unless it was recorded after since.
+	TouchAPIKey(ctx context.Context, keyID uuid.UUID, ip string, since time.Time) error
+
+	// RevokeAPIKey revokes an API key of a user.
+	RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error
+}
+
+// Service manages the API keys organizers create for server-to-server integrations.
+type Service struct {
+	repository repository
+}
+
+// NewService creates a new API key service.
+func NewService(r repository) *Service {
+	return &Service{repository: r}
+}
+
+// CreateAPIKey creates an API key for a user with the given name and scopes,
+// expiring at expiresAt unless it is nil. The returned key is not stored and
+// cannot be retrieved again.
+// Returns ErrNoScopes or ErrInvalidScope for missing or unknown scopes.
+func (s *Service) CreateAPIKey(
+	ctx context.Context,
+	userID uuid.UUID,
+	name string,
+	scopes []string,
+	expiresAt *time.Time,
+) (*model.NewAPIKey, error) {
+	if len(scopes) == 0 {
+		return nil, ErrNoScopes
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(model.APIKeyScopes, scope) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	scopes = slices.Clone(scopes)
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	key, err := generateKey()
+	if err != nil {
+		return nil, fmt.Errorf("generate api key: %w", err)
+	}
+
+	k := &model.APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    key[:shownPrefixLen],
+		KeyHash:   hashKey(key),
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.repository.CreateAPIKey(ctx, k); err != nil {
+		return nil, fmt.Errorf("create api key: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Str("api_key_id", k.ID.String()).
+		Strs("scopes", scopes).Msg("api key created")
+
+	return &model.NewAPIKey{APIKey: k, Key: key}, nil
+}
+
+// GetAPIKeys returns the API keys of a user, without the keys themselves.
+func (s *Service) GetAPIKeys(ctx context.Context, userID uuid.UUID) ([]*model.APIKey, error) {
+	keys, err := s.repository.GetAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get api keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes an API key of a user; requests with it are rejected at once.
+// Returns ErrAPIKeyNotFound if the user has no such unrevoked key.
+func (s *Service) RevokeAPIKey(ctx context.Context, userID, keyID uuid.UUID) error {
+	if err := s.repository.RevokeAPIKey(ctx, userID, keyID); err != nil {
+		if errors.Is(err, apikeyrepo.ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+
+		return fmt.Errorf("revoke api key: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Str("api_key_id", keyID.String()).Msg("api key revoked")
+
+	return nil
+}
+
+// VerifyAPIKey returns the active API key a request from ip presented and
+// records its use. It returns nil if the key is unknown, revoked or expired,
+// or its owner is no longer an organizer or admin.
+func (s *Service) VerifyAPIKey(ctx context.Context, key, ip string) (*model.APIKey, error) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return nil, nil
+	}
+
+	k, err := s.repository.GetActiveAPIKey(ctx, hashKey(key))
+	if err != nil {
+		if errors.Is(err, apikeyrepo.ErrAPIKeyNotFound) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("get api key: %w", err)
+	}
+
+	if k.OwnerRole != model.RoleOrganizer && k.OwnerRole != model.RoleAdmin {
+		return nil, nil
+	}
+
+	// A failure to record the use must not fail the request.
+	if err := s.repository.TouchAPIKey(ctx, k.ID, ip, time.Now().Add(-lastUseResolution)); err != nil {
+		zlog.Logger.Error().Err(err).Str("api_key_id", k.ID.String()).Msg("failed to record api key use")
+	}
+
+	return k, nil
+}
+
+// generateKey returns a new random API key.
+func generateKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return keyPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashKey returns the SHA-256 hash of an API key, which is what is stored.
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/database"
 	"github.com/aliskhannn/event-booker/internal/model"
 	eventrepo "github.com/aliskhannn/event-booker/internal/repository/event"
 )
@@ -15,6 +19,11 @@ import (
 var (
 	ErrNoSeatsAvailable = errors.New("no seats available")
 	ErrEventNotFound    = errors.New("event not found")
+	ErrBookingNotFound  = errors.New("booking not found")
+	ErrForbidden        = errors.New("not allowed to manage this event or booking")
+	ErrTooManyGuests    = errors.New("too many guest bookings are pending for this event, try again later")
+	ErrInvalidSeats     = errors.New("available seats must equal total seats")
+	ErrGuestHoldEnded   = errors.New("the seat is no longer held, the booking was confirmed or has expired")
 )
 
 // repository defines the interface for event booking-related data access.
@@ -25,12 +34,21 @@ type repository interface {
 	// CreateBooking adds a new booking to the database.
 	CreateBooking(ctx context.Context, booking *model.Booking) (uuid.UUID, error)
 
+	// CreateSlotBooking adds a new booking for an event using the slots strategy.
+	CreateSlotBooking(ctx context.Context, booking *model.Booking) (uuid.UUID, error)
+
 	// GetAllEvents retrieves all events from the database.
 	GetAllEvents(ctx context.Context) ([]*model.Event, error)
 
 	// GetEventByID retrieves an event by its id.
 	GetEventByID(ctx context.Context, eventID uuid.UUID) (*model.Event, error)
 
+	// GetAttendees retrieves the users holding pending or confirmed bookings for an event.
+	GetAttendees(ctx context.Context, eventID uuid.UUID) ([]*model.Attendee, error)
+
+	// GetBookingByID retrieves a booking by id.
+	GetBookingByID(ctx context.Context, bookingID uuid.UUID) (*model.Booking, error)
+
 	// ConfirmBooking sets booking status to confirmed.
 	ConfirmBooking(ctx context.Context, bookingID uuid.UUID) error
 
@@ -40,34 +58,109 @@ type repository interface {
 	// CancelExpiredBooking sets the status of an expired booking to 'cancelled'.
 	CancelExpiredBooking(ctx context.Context, bookingID uuid.UUID) error
 
-	// GetExpiredBookings retrieves all expired pending bookings.
-	GetExpiredBookings(ctx context.Context) ([]*model.Booking, error)
+	// CancelExpiredBookingsBatch cancels up to limit expired pending bookings in a single statement.
+	CancelExpiredBookingsBatch(ctx context.Context, limit int) (int, error)
+
+	// CountPendingGuestBookings counts the pending bookings made through guest checkout for an event.
+	CountPendingGuestBookings(ctx context.Context, eventID uuid.UUID) (int, error)
+
+	// GetPendingBookings retrieves all pending bookings, expired or not.
+	GetPendingBookings(ctx context.Context) ([]*model.Booking, error)
+
+	// EnqueueEventReminders enqueues reminders for confirmed bookings of events starting within (after, before].
+	EnqueueEventReminders(ctx context.Context, after, before time.Duration) (int, error)
+
+	// EnqueueHoldExpiryWarnings warns holders of pending bookings that are about to expire, once per booking.
+	EnqueueHoldExpiryWarnings(ctx context.Context, lead time.Duration, fraction float64, baseURL string) (int, error)
+
+	// GetSeatDrifts retrieves all events whose available seats drifted from their bookings.
+	GetSeatDrifts(ctx context.Context) ([]*model.SeatDrift, error)
+
+	// ReconcileSeats records seat drifts and, if correct is true, fixes them.
+	ReconcileSeats(ctx context.Context, correct bool) ([]*model.SeatDrift, error)
+}
+
+// expiryScheduler defines the interface for scheduling the cancellation of a booking when it expires.
+type expiryScheduler interface {
+	// Add schedules a booking to be cancelled at expiresAt.
+	Add(bookingID uuid.UUID, expiresAt time.Time)
+}
+
+// guestAccounts defines the interface for the accounts of guests who book without logging in.
+type guestAccounts interface {
+	// GuestUser returns the user to book for a guest with an email address, creating a lightweight
+	// unverified one if there is none, or nil if the address has an account, which is emailed a login link.
+	GuestUser(ctx context.Context, email, name string, event *model.Event) (*model.User, error)
+
+	// SendGuestBooking emails a guest the hold on their seat with a link that confirms the booking.
+	SendGuestBooking(ctx context.Context, user *model.User, event *model.Event, booking *model.Booking)
+
+	// ClaimGuestBooking checks a guest booking link's token for eventID, verifies the guest's email
+	// and returns the guest and the id of the booking to confirm.
+	ClaimGuestBooking(ctx context.Context, token string, eventID uuid.UUID) (*model.User, uuid.UUID, error)
+
+	// LoginGuest logs in a guest who followed a guest booking link.
+	LoginGuest(ctx context.Context, user *model.User, ip string) (*model.LoginResult, error)
+}
+
+// rateLimiter defines the interface for limiting requests per client or email.
+type rateLimiter interface {
+	// Allow counts a request for key and returns a *ratelimit.LimitedError if limit requests were already counted.
+	Allow(ctx context.Context, key string, limit int) error
 }
 
 // Service contains business logic for event booking management.
 type Service struct {
-	repository repository
+	repository  repository
+	expiry      expiryScheduler
+	guests      guestAccounts
+	limiter     rateLimiter
+	guestLimits config.GuestLimits
 }
 
-// NewService creates a new event service with the provided repository.
-func NewService(r repository) *Service {
-	return &Service{repository: r}
+// NewService creates a new event service with the provided repository, the
+// scheduler that cancels new bookings when they expire, the guest accounts
+// and the limiter of guest booking requests.
+func NewService(r repository, e expiryScheduler, g guestAccounts, l rateLimiter, guestLimits config.GuestLimits) *Service {
+	return &Service{
+		repository:  r,
+		expiry:      e,
+		guests:      g,
+		limiter:     l,
+		guestLimits: guestLimits,
+	}
 }
 
-// CreateEvent creates new event.
+// CreateEvent creates new event organized by the user organizerID.
+// The seat strategy defaults to the counter strategy if empty.
+// Returns ErrInvalidSeats if not all seats are available.
 func (s *Service) CreateEvent(
 	ctx context.Context,
+	organizerID uuid.UUID,
 	title string,
 	date time.Time,
 	totalSeats, availableSeats int,
 	bookingTTL time.Duration,
+	seatStrategy string,
 ) (uuid.UUID, error) {
+	if seatStrategy == "" {
+		seatStrategy = model.SeatStrategyCounter
+	}
+
+	// A new event has no bookings, so all seats are available; seat
+	// reconciliation and the slots created for a slots event rely on it.
+	if availableSeats != totalSeats {
+		return uuid.Nil, ErrInvalidSeats
+	}
+
 	event := &model.Event{
 		Title:          title,
 		Date:           date,
 		TotalSeats:     totalSeats,
 		AvailableSeats: availableSeats,
 		BookingTTL:     bookingTTL,
+		SeatStrategy:   seatStrategy,
+		OrganizerID:    &organizerID,
 	}
 
 	id, err := s.repository.CreateEvent(ctx, event)
@@ -80,32 +173,152 @@ func (s *Service) CreateEvent(
 
 // BookEvent reserves seats for a user at an event.
 func (s *Service) BookEvent(ctx context.Context, userID, eventID uuid.UUID) (uuid.UUID, error) {
-	// Load event to check availability and TTL.
+	// Reads deciding on a booking must not see a stale replica.
+	ctx = database.WithMaster(ctx)
+
+	event, err := s.bookableEvent(ctx, eventID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	booking, err := s.book(ctx, userID, event, false)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	return booking.ID, nil
+}
+
+// BookEventAsGuest reserves a seat at an event for the holder of an email
+// address who is not logged in. The seat is held for the event's booking TTL
+// like any other booking, in the account of a lightweight unverified user,
+// and the guest is emailed a link that confirms the booking, see
+// ConfirmGuestBooking. An address with an account is emailed a login link
+// instead and nothing is booked. Requests are limited per client, the IP or
+// API key that sent them, and per email.
+// Returns a *ratelimit.LimitedError if either is over its limit and
+// ErrTooManyGuests if the event has as many pending guest bookings as allowed.
+func (s *Service) BookEventAsGuest(ctx context.Context, email, name, client string, eventID uuid.UUID) error {
+	if err := s.limiter.Allow(ctx, "guest-client:"+client, s.guestLimits.PerClient); err != nil {
+		return err
+	}
+	if err := s.limiter.Allow(ctx, "guest-email:"+strings.ToLower(email), s.guestLimits.PerEmail); err != nil {
+		return err
+	}
+
+	ctx = database.WithMaster(ctx)
+
+	// Check the event first, so that no user is created for a booking that fails.
+	event, err := s.bookableEvent(ctx, eventID)
+	if err != nil {
+		return err
+	}
+
+	if s.guestLimits.MaxPending > 0 {
+		pending, err := s.repository.CountPendingGuestBookings(ctx, eventID)
+		if err != nil {
+			return fmt.Errorf("count pending guest bookings: %w", err)
+		}
+		if pending >= s.guestLimits.MaxPending {
+			return ErrTooManyGuests
+		}
+	}
+
+	user, err := s.guests.GuestUser(ctx, email, name, event)
+	if err != nil {
+		return fmt.Errorf("get guest user: %w", err)
+	}
+	if user == nil {
+		// The address has an account, which books after logging in.
+		return nil
+	}
+
+	booking, err := s.book(ctx, user.ID, event, true)
+	if err != nil {
+		return err
+	}
+
+	s.guests.SendGuestBooking(ctx, user, event, booking)
+
+	return nil
+}
+
+// ConfirmGuestBooking confirms the booking held for a guest with the token of
+// the link emailed by BookEventAsGuest and logs the guest in.
+// Returns ErrGuestHoldEnded if the booking is no longer pending, e.g. because
+// the hold expired, or the errors of ClaimGuestBooking for an unusable link.
+func (s *Service) ConfirmGuestBooking(ctx context.Context, token, ip string, eventID uuid.UUID) (*model.GuestBooking, error) {
+	ctx = database.WithMaster(ctx)
+
+	user, bookingID, err := s.guests.ClaimGuestBooking(ctx, token, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("claim guest booking: %w", err)
+	}
+
+	if err := s.repository.ConfirmBooking(ctx, bookingID); err != nil {
+		if errors.Is(err, eventrepo.ErrBookingNotFoundOrAlreadyConfirmed) {
+			return nil, ErrGuestHoldEnded
+		}
+
+		return nil, fmt.Errorf("confirm booking: %w", err)
+	}
+
+	result, err := s.guests.LoginGuest(ctx, user, ip)
+	if err != nil {
+		return nil, fmt.Errorf("login guest: %w", err)
+	}
+
+	return &model.GuestBooking{LoginResult: result, BookingID: bookingID}, nil
+}
+
+// bookableEvent loads an event to check availability and TTL.
+func (s *Service) bookableEvent(ctx context.Context, eventID uuid.UUID) (*model.Event, error) {
 	event, err := s.repository.GetEventByID(ctx, eventID)
 	if err != nil {
 		if errors.Is(err, eventrepo.ErrEventNotFound) {
-			return uuid.Nil, ErrEventNotFound
+			return nil, ErrEventNotFound
 		}
 
-		return uuid.Nil, fmt.Errorf("get event: %w", err)
+		return nil, fmt.Errorf("get event: %w", err)
 	}
 	if event.AvailableSeats <= 0 {
-		return uuid.Nil, ErrNoSeatsAvailable
+		return nil, ErrNoSeatsAvailable
 	}
 
+	return event, nil
+}
+
+// book creates a pending booking of a seat at event for a user and schedules
+// its expiry. guest marks bookings made through guest checkout.
+func (s *Service) book(ctx context.Context, userID uuid.UUID, event *model.Event, guest bool) (*model.Booking, error) {
 	booking := &model.Booking{
-		EventID:   eventID,
+		EventID:   event.ID,
 		UserID:    userID,
 		Status:    "pending",
+		Guest:     guest,
 		ExpiresAt: time.Now().Add(event.BookingTTL), // calculate expiration time
 	}
 
-	id, err := s.repository.CreateBooking(ctx, booking)
+	createBooking := s.repository.CreateBooking
+	if event.SeatStrategy == model.SeatStrategySlots {
+		createBooking = s.repository.CreateSlotBooking
+	}
+
+	id, err := createBooking(ctx, booking)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("create booking: %w", err)
+		// The last seats may have been taken since the event was loaded.
+		if errors.Is(err, eventrepo.ErrNoSeatsAvailable) {
+			return nil, ErrNoSeatsAvailable
+		}
+
+		return nil, fmt.Errorf("create booking: %w", err)
 	}
+	booking.ID = id
 
-	return id, nil
+	// Release the seat as soon as the hold expires.
+	s.expiry.Add(id, booking.ExpiresAt)
+
+	return booking, nil
 }
 
 // GetEvents retrieves all events.
@@ -127,8 +340,41 @@ func (s *Service) GetEventByID(ctx context.Context, eventID uuid.UUID) (*model.E
 	return event, nil
 }
 
-// ConfirmBookingPayment confirms the payment of a booking.
-func (s *Service) ConfirmBookingPayment(ctx context.Context, bookingID uuid.UUID) error {
+// GetAttendees returns the users holding pending or confirmed bookings for an
+// event, to its organizer or an admin.
+// Returns ErrEventNotFound if there is no such event and ErrForbidden if the
+// actor may not manage it.
+func (s *Service) GetAttendees(ctx context.Context, actor *model.Actor, eventID uuid.UUID) ([]*model.Attendee, error) {
+	event, err := s.repository.GetEventByID(ctx, eventID)
+	if err != nil {
+		if errors.Is(err, eventrepo.ErrEventNotFound) {
+			return nil, ErrEventNotFound
+		}
+
+		return nil, fmt.Errorf("get event: %w", err)
+	}
+
+	if !canManageEvent(actor, event) {
+		return nil, ErrForbidden
+	}
+
+	attendees, err := s.repository.GetAttendees(ctx, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("get attendees: %w", err)
+	}
+
+	return attendees, nil
+}
+
+// ConfirmBookingPayment confirms the payment of a booking, by the user who
+// holds it, the event's organizer or an admin.
+// Returns ErrBookingNotFound if there is no such booking and ErrForbidden if
+// the actor may not manage it.
+func (s *Service) ConfirmBookingPayment(ctx context.Context, actor *model.Actor, bookingID uuid.UUID) error {
+	if err := s.authorizeBooking(ctx, actor, bookingID); err != nil {
+		return err
+	}
+
 	err := s.repository.ConfirmBooking(ctx, bookingID)
 	if err != nil {
 		return fmt.Errorf("confirm booking payment: %w", err)
@@ -137,17 +383,24 @@ func (s *Service) ConfirmBookingPayment(ctx context.Context, bookingID uuid.UUID
 	return nil
 }
 
-// GetExpiredBookings returns all expired bookings (background job).
-func (s *Service) GetExpiredBookings(ctx context.Context) ([]*model.Booking, error) {
-	bookings, err := s.repository.GetExpiredBookings(ctx)
+// GetPendingBookings returns all pending bookings (background job).
+func (s *Service) GetPendingBookings(ctx context.Context) ([]*model.Booking, error) {
+	bookings, err := s.repository.GetPendingBookings(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("get expired bookings: %w", err)
+		return nil, fmt.Errorf("get pending bookings: %w", err)
 	}
 	return bookings, nil
 }
 
-// CancelBooking cancels a booking (by user).
-func (s *Service) CancelBooking(ctx context.Context, bookingID uuid.UUID) error {
+// CancelBooking cancels a booking, by the user who holds it, the event's
+// organizer or an admin.
+// Returns ErrBookingNotFound if there is no such booking and ErrForbidden if
+// the actor may not manage it.
+func (s *Service) CancelBooking(ctx context.Context, actor *model.Actor, bookingID uuid.UUID) error {
+	if err := s.authorizeBooking(ctx, actor, bookingID); err != nil {
+		return err
+	}
+
 	err := s.repository.CancelBooking(ctx, bookingID)
 	if err != nil {
 		return fmt.Errorf("cancel booking: %w", err)
@@ -156,7 +409,57 @@ func (s *Service) CancelBooking(ctx context.Context, bookingID uuid.UUID) error
 	return nil
 }
 
-// CancelExpiredBooking cancels a booking (background job).
+// authorizeBooking checks that actor may manage a booking: users their own
+// bookings, organizers and their API keys the bookings of their events, and
+// admins any booking.
+func (s *Service) authorizeBooking(ctx context.Context, actor *model.Actor, bookingID uuid.UUID) error {
+	booking, err := s.repository.GetBookingByID(ctx, bookingID)
+	if err != nil {
+		if errors.Is(err, eventrepo.ErrBookingNotFound) {
+			return ErrBookingNotFound
+		}
+
+		return fmt.Errorf("get booking: %w", err)
+	}
+
+	if !actor.APIKey && booking.UserID == actor.UserID {
+		return nil
+	}
+
+	event, err := s.repository.GetEventByID(ctx, booking.EventID)
+	if err != nil {
+		return fmt.Errorf("get event: %w", err)
+	}
+
+	if !canManageEvent(actor, event) {
+		return ErrForbidden
+	}
+
+	return nil
+}
+
+// CancelExpiredBookings cancels all expired bookings in batches of batchSize
+// and returns how many were cancelled (background job).
+func (s *Service) CancelExpiredBookings(ctx context.Context, batchSize int) (int, error) {
+	var total int
+	for {
+		n, err := s.repository.CancelExpiredBookingsBatch(ctx, batchSize)
+		if err != nil {
+			return total, fmt.Errorf("cancel expired bookings batch: %w", err)
+		}
+
+		total += n
+		if n < batchSize {
+			return total, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// CancelExpiredBooking cancels a booking and enqueues the expiry notification (background job).
 func (s *Service) CancelExpiredBooking(ctx context.Context, bookingID uuid.UUID) error {
 	err := s.repository.CancelExpiredBooking(ctx, bookingID)
 	if err != nil {
@@ -165,3 +468,82 @@ func (s *Service) CancelExpiredBooking(ctx context.Context, bookingID uuid.UUID)
 
 	return nil
 }
+
+// SendEventReminders enqueues reminders for confirmed bookings of upcoming events (background job).
+//
+// Offsets are how long before the event a reminder is sent, e.g. 24h and 1h.
+// An event is only reminded about under the smallest offset it falls within,
+// so a booking confirmed 30 minutes before the event gets one reminder, not two.
+func (s *Service) SendEventReminders(ctx context.Context, offsets []time.Duration) (int, error) {
+	sorted := slices.Sorted(slices.Values(offsets))
+
+	var total int
+	var after time.Duration
+	for _, offset := range sorted {
+		n, err := s.repository.EnqueueEventReminders(ctx, after, offset)
+		if err != nil {
+			return total, fmt.Errorf("enqueue event reminders for offset %s: %w", offset, err)
+		}
+
+		total += n
+		after = offset
+	}
+
+	return total, nil
+}
+
+// SendHoldExpiryWarnings warns holders of pending bookings that are about to expire (background job).
+// The warning links to baseURL, the public address of the web UI, where the booking can be confirmed.
+func (s *Service) SendHoldExpiryWarnings(
+	ctx context.Context,
+	lead time.Duration,
+	fraction float64,
+	baseURL string,
+) (int, error) {
+	n, err := s.repository.EnqueueHoldExpiryWarnings(ctx, lead, fraction, baseURL)
+	if err != nil {
+		return 0, fmt.Errorf("enqueue hold expiry warnings: %w", err)
+	}
+
+	return n, nil
+}
+
+// GetSeatDrifts returns the events whose available seats currently do not
+// match their total seats minus active bookings.
+func (s *Service) GetSeatDrifts(ctx context.Context) ([]*model.SeatDrift, error) {
+	drifts, err := s.repository.GetSeatDrifts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get seat drifts: %w", err)
+	}
+
+	return drifts, nil
+}
+
+// ReconcileSeats records events whose available seats drifted from their bookings
+// and, if correct is true, resets their counters (background job).
+func (s *Service) ReconcileSeats(ctx context.Context, correct bool) ([]*model.SeatDrift, error) {
+	drifts, err := s.repository.ReconcileSeats(ctx, correct)
+	if err != nil {
+		return nil, fmt.Errorf("reconcile seats: %w", err)
+	}
+
+	return drifts, nil
+}
+
+// canManageEvent reports whether actor may manage an event and its bookings.
+// Organizers manage the events they created, as long as they are organizers,
+// like the routes only they may use. Admins manage every event, but API keys
+// are limited to their owner's events even if the owner is an admin.
+func canManageEvent(actor *model.Actor, event *model.Event) bool {
+	switch actor.Role {
+	case model.RoleAdmin:
+		if !actor.APIKey {
+			return true
+		}
+	case model.RoleOrganizer:
+	default:
+		return false
+	}
+
+	return event.OrganizerID != nil && *event.OrganizerID == actor.UserID
+}
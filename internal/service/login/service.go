@@ -0,0 +1,234 @@
+package login
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+var ErrTooManyAttempts = errors.New("too many login attempts, try again later")
+
+// ThrottledError is returned for login attempts rejected by the guard.
+// It unwraps to ErrTooManyAttempts.
+type ThrottledError struct {
+	Locked     bool          // the account or IP is locked out, not just delayed
+	RetryAfter time.Duration // how long to wait before the next attempt
+}
+
+// Error implements the error interface.
+func (e *ThrottledError) Error() string {
+	return ErrTooManyAttempts.Error()
+}
+
+// Unwrap returns ErrTooManyAttempts.
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// counters defines the interface for counting login attempts per key.
+// It is implemented by the Postgres repository and by in-memory counters.
+type counters interface {
+	// Hit counts an attempt for key and returns the number of attempts before it,
+	// the previous attempt's time and this attempt's time.
+	Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, time.Time, error)
+
+	// Undo takes back a rejected attempt counted at at and restores the previous attempt's time.
+	Undo(ctx context.Context, key string, prevAt, at time.Time) error
+
+	// Forgive takes back one counted attempt for key.
+	Forgive(ctx context.Context, key string) error
+
+	// Reset clears the attempts counted for key.
+	Reset(ctx context.Context, key string) error
+}
+
+// auditLog defines the interface for recording failed logins.
+type auditLog interface {
+	// RecordFailure stores an audit record of a failed login attempt.
+	RecordFailure(ctx context.Context, f *model.LoginFailure) error
+
+	// GetFailures retrieves the most recent failed login attempts, optionally only those for email.
+	GetFailures(ctx context.Context, email string, limit int) ([]*model.LoginFailure, error)
+}
+
+// Service protects logins against brute-force attacks with progressive
+// delays and lockouts per account and per client IP.
+type Service struct {
+	counters counters
+	audit    auditLog
+	cfg      config.LoginGuard
+}
+
+// NewService creates a new login guard service.
+func NewService(c counters, a auditLog, cfg config.LoginGuard) *Service {
+	return &Service{
+		counters: c,
+		audit:    a,
+		cfg:      cfg,
+	}
+}
+
+// Attempt counts a login attempt for the email and client IP before the
+// password is checked. It returns a *ThrottledError if the attempt comes too
+// soon after the previous one or the account or IP is locked out.
+//
+// Attempts are counted up front, so parallel requests cannot get more
+// guesses than the lockout allows. Rejected attempts are taken back, so that
+// they do not extend the delay or lockout: otherwise anyone could keep an
+// account locked out by trying it now and then.
+func (s *Service) Attempt(ctx context.Context, email, ip string) error {
+	account, ipAddr := accountKey(email), ipKey(ip)
+
+	accountPrev, accountPrevAt, accountAt, err := s.counters.Hit(ctx, account, s.cfg.Window)
+	if err != nil {
+		return fmt.Errorf("count account attempt: %w", err)
+	}
+
+	ipPrev, ipPrevAt, ipAt, err := s.counters.Hit(ctx, ipAddr, s.cfg.Window)
+	if err != nil {
+		return fmt.Errorf("count ip attempt: %w", err)
+	}
+
+	throttled := stricter(
+		s.check(accountPrev, accountPrevAt, s.cfg.Account),
+		s.check(ipPrev, ipPrevAt, s.cfg.IP),
+	)
+	if throttled == nil {
+		return nil
+	}
+
+	if err := s.counters.Undo(ctx, account, accountPrevAt, accountAt); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to undo account login attempt")
+	}
+	if err := s.counters.Undo(ctx, ipAddr, ipPrevAt, ipAt); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to undo ip login attempt")
+	}
+
+	reason := model.LoginFailureThrottled
+	if throttled.Locked {
+		reason = model.LoginFailureLocked
+	}
+	s.record(ctx, email, ip, nil, reason)
+
+	return throttled
+}
+
+// Fail records a failed login attempt for the reason, such as wrong
+// credentials or a wrong second-factor code. userID is nil if no user has the email.
+func (s *Service) Fail(ctx context.Context, email, ip string, userID *uuid.UUID, reason string) {
+	s.record(ctx, email, ip, userID, reason)
+}
+
+// Succeed clears the account's count and takes back the IP's count of a
+// successful login, so that users sharing an address are not locked out.
+// Failures are only logged: the login has already succeeded.
+func (s *Service) Succeed(ctx context.Context, email, ip string) {
+	if err := s.counters.Reset(ctx, accountKey(email)); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to reset account login attempts")
+	}
+
+	if err := s.counters.Forgive(ctx, ipKey(ip)); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to forgive ip login attempt")
+	}
+}
+
+// GetFailures returns the most recent failed login attempts, optionally only those for email.
+func (s *Service) GetFailures(ctx context.Context, email string, limit int) ([]*model.LoginFailure, error) {
+	failures, err := s.audit.GetFailures(ctx, normalizeEmail(email), limit)
+	if err != nil {
+		return nil, fmt.Errorf("get login failures: %w", err)
+	}
+
+	return failures, nil
+}
+
+// check decides whether an attempt following prev attempts, the last one at
+// prevAt, must be rejected under limit.
+func (s *Service) check(prev int, prevAt time.Time, limit config.LoginLimit) *ThrottledError {
+	if limit.Lockout > 0 && prev >= limit.Lockout {
+		return &ThrottledError{Locked: true, RetryAfter: s.cfg.Window - time.Since(prevAt)}
+	}
+
+	if wait := s.delay(prev, limit) - time.Since(prevAt); wait > 0 {
+		return &ThrottledError{RetryAfter: wait}
+	}
+
+	return nil
+}
+
+// delay returns how long an attempt must wait after the previous one when
+// attempts were made before it: nothing for the first free attempts of limit,
+// then BaseDelay, doubling with each further attempt up to MaxDelay.
+func (s *Service) delay(attempts int, limit config.LoginLimit) time.Duration {
+	if attempts < limit.FreeAttempts || s.cfg.BaseDelay <= 0 {
+		return 0
+	}
+
+	d := s.cfg.BaseDelay
+	for i := limit.FreeAttempts; i < attempts && d < s.cfg.MaxDelay; i++ {
+		d *= 2
+	}
+
+	return min(d, s.cfg.MaxDelay)
+}
+
+// record stores an audit record of a failed login. Failures are only logged,
+// so that a broken audit log does not lock users out.
+func (s *Service) record(ctx context.Context, email, ip string, userID *uuid.UUID, reason string) {
+	zlog.Logger.Warn().Str("email", email).Str("ip", ip).Str("reason", reason).Msg("login failed")
+
+	err := s.audit.RecordFailure(ctx, &model.LoginFailure{
+		Email:  normalizeEmail(email),
+		UserID: userID,
+		IP:     ip,
+		Reason: reason,
+	})
+	if err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to record login failure")
+	}
+}
+
+// stricter returns the stricter of two throttling decisions, either of which may be nil:
+// a lockout over a delay, then the longer wait.
+func stricter(a, b *ThrottledError) *ThrottledError {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.Locked != b.Locked:
+		if a.Locked {
+			return a
+		}
+		return b
+	case b.RetryAfter > a.RetryAfter:
+		return b
+	default:
+		return a
+	}
+}
+
+// accountKey returns the counter key of the account with the email.
+// Emails are counted whether or not they are registered, so lockouts do not
+// reveal which ones are.
+func accountKey(email string) string {
+	return "account:" + normalizeEmail(email)
+}
+
+// ipKey returns the counter key of a client IP.
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// normalizeEmail lower-cases an email, so that case variants share a counter.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
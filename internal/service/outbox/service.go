@@ -0,0 +1,184 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/model"
+	"github.com/aliskhannn/event-booker/internal/notification"
+)
+
+// repository defines the interface for outbox data access.
+type repository interface {
+	// ClaimDueMessages locks pending messages that are due and hides them from other dispatchers for lease.
+	ClaimDueMessages(ctx context.Context, limit int, lease time.Duration) ([]*model.OutboxMessage, error)
+
+	// MarkSent marks a message as delivered over the given channels.
+	MarkSent(ctx context.Context, messageID uuid.UUID, delivered []string) error
+
+	// MarkFailed records a failed delivery attempt and the channels the message was already delivered to.
+	MarkFailed(
+		ctx context.Context,
+		messageID uuid.UUID,
+		delivered []string,
+		lastError string,
+		nextAttemptAt time.Time,
+		dead bool,
+	) error
+
+	// GetMessages retrieves the most recently updated messages with the given status.
+	GetMessages(ctx context.Context, status string, limit int) ([]*model.OutboxMessage, error)
+
+	// ReplayMessage moves a dead message back to pending.
+	ReplayMessage(ctx context.Context, messageID uuid.UUID) error
+}
+
+// renderer defines an interface for rendering notification templates.
+type renderer interface {
+	// Render renders the named template for the given locale with data.
+	Render(name, locale string, data any) (*notification.Message, error)
+}
+
+// router defines an interface for delivering notifications over the user's channels.
+type router interface {
+	// Send delivers msg over the channels selected in prefs, skipping those already delivered,
+	// and returns the updated list of delivered channels.
+	Send(
+		ctx context.Context,
+		prefs *model.NotificationPreferences,
+		to notification.Recipient,
+		template string,
+		msg *notification.Message,
+		delivered []string,
+	) ([]string, error)
+}
+
+// preferences defines an interface for looking up users' notification preferences.
+type preferences interface {
+	// GetNotificationPreferences returns the notification preferences of a user.
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error)
+}
+
+// Service contains business logic for delivering outbox notifications.
+type Service struct {
+	repository  repository
+	renderer    renderer
+	router      router
+	preferences preferences
+	cfg         config.Outbox
+}
+
+// NewService creates a new outbox service.
+func NewService(r repository, rd renderer, rt router, p preferences, cfg config.Outbox) *Service {
+	return &Service{
+		repository:  r,
+		renderer:    rd,
+		router:      rt,
+		preferences: p,
+		cfg:         cfg,
+	}
+}
+
+// Dispatch delivers one batch of due messages and returns how many were claimed.
+// Failed deliveries are retried with exponential backoff until MaxAttempts
+// is reached, after which the message is dead-lettered.
+func (s *Service) Dispatch(ctx context.Context) (int, error) {
+	messages, err := s.repository.ClaimDueMessages(ctx, s.cfg.BatchSize, s.cfg.Lease)
+	if err != nil {
+		return 0, fmt.Errorf("claim due messages: %w", err)
+	}
+
+	for _, m := range messages {
+		delivered, err := s.deliver(ctx, m)
+		if err != nil {
+			attempts := m.Attempts + 1
+			dead := attempts >= s.cfg.MaxAttempts
+			nextAttemptAt := time.Now().Add(s.backoff(attempts))
+
+			zlog.Logger.Error().Err(err).
+				Str("message_id", m.ID.String()).
+				Int("attempts", attempts).
+				Bool("dead", dead).
+				Msg("failed to deliver notification")
+
+			if err := s.repository.MarkFailed(ctx, m.ID, delivered, err.Error(), nextAttemptAt, dead); err != nil {
+				return len(messages), fmt.Errorf("mark message %s failed: %w", m.ID, err)
+			}
+			continue
+		}
+
+		if err := s.repository.MarkSent(ctx, m.ID, delivered); err != nil {
+			return len(messages), fmt.Errorf("mark message %s sent: %w", m.ID, err)
+		}
+	}
+
+	return len(messages), nil
+}
+
+// GetMessages returns the most recently updated messages with the given status.
+func (s *Service) GetMessages(ctx context.Context, status string, limit int) ([]*model.OutboxMessage, error) {
+	messages, err := s.repository.GetMessages(ctx, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("get messages: %w", err)
+	}
+
+	return messages, nil
+}
+
+// ReplayMessage schedules a dead message for immediate redelivery.
+func (s *Service) ReplayMessage(ctx context.Context, messageID uuid.UUID) error {
+	if err := s.repository.ReplayMessage(ctx, messageID); err != nil {
+		return fmt.Errorf("replay message: %w", err)
+	}
+
+	return nil
+}
+
+// deliver renders a message and sends it over the recipient's preferred channels.
+// It returns the channels the message has been delivered to so far.
+func (s *Service) deliver(ctx context.Context, m *model.OutboxMessage) ([]string, error) {
+	var data map[string]any
+	if err := json.Unmarshal(m.Payload, &data); err != nil {
+		return m.DeliveredChannels, fmt.Errorf("decode payload: %w", err)
+	}
+
+	msg, err := s.renderer.Render(m.Template, m.Locale, data)
+	if err != nil {
+		return m.DeliveredChannels, fmt.Errorf("render: %w", err)
+	}
+
+	prefs, err := s.preferences.GetNotificationPreferences(ctx, m.UserID)
+	if err != nil {
+		return m.DeliveredChannels, fmt.Errorf("get preferences: %w", err)
+	}
+
+	to := notification.Recipient{
+		UserID:         m.UserID,
+		Email:          m.Recipient,
+		WebhookURL:     prefs.WebhookURL,
+		TelegramChatID: prefs.TelegramChatID,
+	}
+
+	delivered, err := s.router.Send(ctx, prefs, to, m.Template, msg, m.DeliveredChannels)
+	if err != nil {
+		return delivered, fmt.Errorf("send: %w", err)
+	}
+
+	return delivered, nil
+}
+
+// backoff returns the delay before the next attempt after the given number of attempts.
+func (s *Service) backoff(attempts int) time.Duration {
+	delay := s.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < s.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, s.cfg.MaxBackoff)
+}
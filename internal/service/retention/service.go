@@ -0,0 +1,155 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/wb-go/wbf/zlog"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/model"
+)
+
+// defaultBatchSize is used when the configured batch size is not positive.
+const defaultBatchSize = 500
+
+// repository defines the interface for moving old data out of the hot tables.
+type repository interface {
+	// ArchiveEvents moves up to limit events older than olderThan, with their bookings, into the archive tables.
+	ArchiveEvents(ctx context.Context, olderThan time.Duration, limit int) (int, int, error)
+
+	// DeleteCancelledBookings deletes up to limit bookings cancelled more than olderThan ago.
+	DeleteCancelledBookings(ctx context.Context, olderThan time.Duration, limit int) (int, error)
+
+	// DeleteExpiredTokens deletes up to limit expired refresh tokens, access token revocations, user tokens
+	// and OpenID Connect logins each.
+	DeleteExpiredTokens(ctx context.Context, limit int) (int, error)
+
+	// DeleteLoginRecords deletes up to limit failed login records and attempt counters older than olderThan each.
+	DeleteLoginRecords(ctx context.Context, olderThan time.Duration, limit int) (int, error)
+
+	// DeleteJobRuns deletes up to limit finished job runs started more than olderThan ago.
+	DeleteJobRuns(ctx context.Context, olderThan time.Duration, limit int) (int, error)
+
+	// GetReport retrieves the archived volumes.
+	GetReport(ctx context.Context) (*model.RetentionReport, error)
+}
+
+// Service applies the data retention policy.
+type Service struct {
+	repository repository
+	cfg        config.Retention
+}
+
+// NewService creates a new retention service.
+func NewService(r repository, cfg config.Retention) *Service {
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultBatchSize
+	}
+
+	return &Service{
+		repository: r,
+		cfg:        cfg,
+	}
+}
+
+// Apply archives past events with their bookings, deletes old cancelled
+// bookings, login records and job runs, batch by batch, as configured, and
+// purges expired tokens. It returns the number of affected rows (background job).
+func (s *Service) Apply(ctx context.Context) (int, error) {
+	var total int
+
+	if s.cfg.ArchiveEventsAfter > 0 {
+		// Batches are counted in events; their bookings are moved along.
+		var bookings int
+		events, err := s.drain(ctx, "archive events", func(ctx context.Context) (int, error) {
+			events, n, err := s.repository.ArchiveEvents(ctx, s.cfg.ArchiveEventsAfter, s.cfg.BatchSize)
+			bookings += n
+			return events, err
+		})
+		total += events + bookings
+		if bookings > 0 {
+			zlog.Logger.Printf("archived %d bookings of past events", bookings)
+		}
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if s.cfg.DeleteCancelledAfter > 0 {
+		n, err := s.drain(ctx, "delete cancelled bookings", func(ctx context.Context) (int, error) {
+			return s.repository.DeleteCancelledBookings(ctx, s.cfg.DeleteCancelledAfter, s.cfg.BatchSize)
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if s.cfg.DeleteLoginsAfter > 0 {
+		n, err := s.drain(ctx, "delete login records", func(ctx context.Context) (int, error) {
+			return s.repository.DeleteLoginRecords(ctx, s.cfg.DeleteLoginsAfter, s.cfg.BatchSize)
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if s.cfg.DeleteJobRunsAfter > 0 {
+		n, err := s.drain(ctx, "delete job runs", func(ctx context.Context) (int, error) {
+			return s.repository.DeleteJobRuns(ctx, s.cfg.DeleteJobRunsAfter, s.cfg.BatchSize)
+		})
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+
+	n, err := s.drain(ctx, "delete expired tokens", func(ctx context.Context) (int, error) {
+		return s.repository.DeleteExpiredTokens(ctx, s.cfg.BatchSize)
+	})
+	total += n
+	if err != nil {
+		return total, err
+	}
+
+	return total, nil
+}
+
+// drain calls fn, which processes one batch of up to the batch size, until a
+// batch comes back short or ctx is done. It returns the number of rows
+// processed, including those of the batches committed before an error.
+func (s *Service) drain(ctx context.Context, what string, fn func(context.Context) (int, error)) (int, error) {
+	var total int
+
+	for {
+		n, err := fn(ctx)
+		if err != nil {
+			return total, fmt.Errorf("%s: %w", what, err)
+		}
+
+		total += n
+		if n > 0 {
+			zlog.Logger.Printf("%s: %d rows", what, n)
+		}
+		if n < s.cfg.BatchSize {
+			return total, nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// GetReport returns the archived volumes.
+func (s *Service) GetReport(ctx context.Context) (*model.RetentionReport, error) {
+	report, err := s.repository.GetReport(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("get report: %w", err)
+	}
+
+	return report, nil
+}
@@ -2,22 +2,59 @@ package user
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/wb-go/wbf/zlog"
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/aliskhannn/event-booker/internal/config"
 	"github.com/aliskhannn/event-booker/internal/model"
+	"github.com/aliskhannn/event-booker/internal/notification"
+	"github.com/aliskhannn/event-booker/internal/oidc"
+	mfarepo "github.com/aliskhannn/event-booker/internal/repository/mfa"
+	sessionrepo "github.com/aliskhannn/event-booker/internal/repository/session"
 	userrepo "github.com/aliskhannn/event-booker/internal/repository/user"
+	"github.com/aliskhannn/event-booker/internal/totp"
 )
 
 var (
-	ErrUserAlreadyExists  = errors.New("user already exists")
-	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrUserAlreadyExists      = errors.New("user already exists")
+	ErrInvalidCredentials     = errors.New("invalid credentials")
+	ErrInvalidRefreshToken    = errors.New("invalid refresh token")
+	ErrInvalidResetToken      = errors.New("invalid or expired reset token")
+	ErrInvalidVerifyToken     = errors.New("invalid or expired verification link")
+	ErrEmailAlreadyVerified   = errors.New("email address is already verified")
+	ErrInvalidChallenge       = errors.New("invalid or expired login challenge")
+	ErrInvalidMFACode         = errors.New("invalid two-factor code")
+	ErrMFAAlreadyEnabled      = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnrolled         = errors.New("two-factor authentication is not set up")
+	ErrOIDCDisabled           = errors.New("single sign-on is not enabled")
+	ErrInvalidOIDCState       = errors.New("invalid or expired single sign-on state")
+	ErrOIDCFailed             = errors.New("single sign-on failed")
+	ErrOIDCEmailNotVerified   = errors.New("the identity provider has not verified the email address")
+	ErrInvalidMagicLink       = errors.New("invalid or expired login link")
+	ErrInvalidGuestBooking    = errors.New("invalid or expired guest booking link")
+	ErrWebhookURLRequired     = errors.New("webhook_url is required for the webhook channel")
+	ErrTelegramChatIDRequired = errors.New("telegram_chat_id is required for the telegram channel")
+	ErrWrongPassword          = errors.New("current password is incorrect")
+	ErrPasswordNotSet         = errors.New("the account has no password, set one with a password reset link")
+	ErrEmailUnchanged         = errors.New("the new email address is the current one")
+	ErrInvalidName            = errors.New("name must not be blank")
 )
 
 // repository defines the interface for user-related data access.
@@ -31,27 +68,215 @@ type repository interface {
 	// GetUserByEmail retrieves a user by their email.
 	GetUserByEmail(ctx context.Context, email string) (*model.User, error)
 
+	// GetUserByEmailFold retrieves a user by their email, ignoring case. A verified user is preferred
+	// if the email matches several users.
+	GetUserByEmailFold(ctx context.Context, email string) (*model.User, error)
+
 	// CheckUserExistsByEmail checks if a user exists for the given email.
 	CheckUserExistsByEmail(ctx context.Context, email string) (bool, error)
+
+	// GetUserByIdentity retrieves the user linked to an account at an OpenID Connect provider.
+	GetUserByIdentity(ctx context.Context, issuer, subject string) (*model.User, error)
+
+	// LinkIdentity links a provider account to an existing user and marks the user's email verified,
+	// claiming an unverified user as ClaimUnverifiedUser does.
+	LinkIdentity(ctx context.Context, identity *model.UserIdentity) error
+
+	// ClaimUnverifiedUser marks the user's email verified, removing the password, notification preferences
+	// and pending email of a user whose email was unverified.
+	ClaimUnverifiedUser(ctx context.Context, userID uuid.UUID) error
+
+	// CreateGuestUser creates a user without a password and with an unverified email for a guest booking.
+	CreateGuestUser(ctx context.Context, user *model.User) error
+
+	// CreateUserWithIdentity creates a user with a verified email and no password, linked to a provider account.
+	CreateUserWithIdentity(ctx context.Context, user *model.User, identity *model.UserIdentity) error
+
+	// MarkEmailVerified marks the email address of a user as verified if it is still the user's email.
+	MarkEmailVerified(ctx context.Context, userID uuid.UUID, email string) error
+
+	// GetPasswordHash retrieves the password hash of a user, empty if the user has no password.
+	GetPasswordHash(ctx context.Context, userID uuid.UUID) (string, error)
+
+	// UpdateProfile updates the name, locale and time zone of a user and, if prefs is not nil, replaces
+	// the user's notification preferences, in one transaction.
+	UpdateProfile(ctx context.Context, user *model.User, prefs *model.NotificationPreferences) error
+
+	// SetPendingEmail records a new email address for a user, to be confirmed before it replaces the email.
+	SetPendingEmail(ctx context.Context, userID uuid.UUID, email string) error
+
+	// ConfirmPendingEmail makes the pending email address the user's verified email and invalidates links sent to the old one.
+	ConfirmPendingEmail(ctx context.Context, userID uuid.UUID, email string) error
+
+	// GetNotificationPreferences retrieves the notification preferences of a user.
+	GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error)
+
+	// SaveNotificationPreferences creates or replaces the notification preferences of a user.
+	SaveNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) error
+}
+
+// sessionRepository defines the interface for refresh token and revocation data access.
+type sessionRepository interface {
+	// CreateRefreshToken stores a new refresh token and sets its id.
+	CreateRefreshToken(ctx context.Context, t *model.RefreshToken) error
+
+	// GetRefreshToken retrieves a refresh token by its hash.
+	GetRefreshToken(ctx context.Context, tokenHash string) (*model.RefreshToken, error)
+
+	// RotateRefreshToken revokes the refresh token oldID and stores next as its replacement.
+	RotateRefreshToken(ctx context.Context, oldID uuid.UUID, next *model.RefreshToken) error
+
+	// RevokeFamily revokes all refresh tokens of a family and their access tokens.
+	RevokeFamily(ctx context.Context, familyID uuid.UUID) error
+
+	// RevokeFamilyOfAccessToken revokes the token family the given access token belongs to.
+	RevokeFamilyOfAccessToken(ctx context.Context, jti uuid.UUID) error
+
+	// RevokeUserTokens revokes all refresh and access tokens of a user.
+	RevokeUserTokens(ctx context.Context, userID uuid.UUID) error
+
+	// IsAccessTokenRevoked checks whether an access token was revoked.
+	IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error)
+
+	// CreateUserToken stores a new single-use user token, invalidating older ones of the same purpose.
+	CreateUserToken(ctx context.Context, t *model.UserToken) error
+
+	// GetUserTokenOwner returns the user of a valid, unused user token without consuming it.
+	GetUserTokenOwner(ctx context.Context, tokenHash, purpose string) (uuid.UUID, error)
+
+	// ConsumeUserToken marks a valid, unused user token as used and returns its user.
+	ConsumeUserToken(ctx context.Context, tokenHash, purpose string) (uuid.UUID, error)
+
+	// ResetPassword consumes a password reset token, sets the new password hash, drops a pending email change
+	// and revokes all sessions.
+	ResetPassword(ctx context.Context, tokenHash, passwordHash string) (uuid.UUID, error)
+
+	// ChangePassword sets a new password hash, drops a pending email change, invalidates reset links and
+	// revokes all sessions.
+	ChangePassword(ctx context.Context, userID uuid.UUID, passwordHash string) error
+
+	// CreateOIDCState stores a started OpenID Connect login.
+	CreateOIDCState(ctx context.Context, st *model.OIDCState) error
+
+	// ConsumeOIDCState deletes an unexpired OpenID Connect login and returns it.
+	ConsumeOIDCState(ctx context.Context, stateHash string) (*model.OIDCState, error)
+}
+
+// mfaRepository defines the interface for second factor data access.
+type mfaRepository interface {
+	// SavePendingSecret stores the secret of a new, unverified enrollment.
+	SavePendingSecret(ctx context.Context, userID uuid.UUID, secret string) error
+
+	// GetMFA retrieves the second factor of a user.
+	GetMFA(ctx context.Context, userID uuid.UUID) (*model.MFA, error)
+
+	// Confirm verifies the pending enrollment of a user and replaces the recovery codes.
+	Confirm(ctx context.Context, userID uuid.UUID, step int64, codeHashes []string) error
+
+	// UseStep records that the TOTP code of a time step was used.
+	UseStep(ctx context.Context, userID uuid.UUID, step int64) error
+
+	// UseRecoveryCode marks an unused recovery code of a user as used.
+	UseRecoveryCode(ctx context.Context, userID uuid.UUID, codeHash string) error
+
+	// DeleteMFA removes the second factor and the recovery codes of a user.
+	DeleteMFA(ctx context.Context, userID uuid.UUID) error
+}
+
+// loginGuard defines the interface for brute-force protection of logins.
+type loginGuard interface {
+	// Attempt counts a login attempt and returns an error if it must be rejected.
+	Attempt(ctx context.Context, email, ip string) error
+
+	// Fail records a failed login attempt for the reason.
+	Fail(ctx context.Context, email, ip string, userID *uuid.UUID, reason string)
+
+	// Succeed clears the counts of a successful login.
+	Succeed(ctx context.Context, email, ip string)
+}
+
+// tokenSigner defines an interface for signing access tokens.
+type tokenSigner interface {
+	// Sign signs the claims with the current signing key.
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// identityProvider defines an interface for logins with an OpenID Connect provider.
+type identityProvider interface {
+	// AuthCodeURL returns the provider URL that starts a login.
+	AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error)
+
+	// Authenticate exchanges an authorization code and returns the verified identity.
+	Authenticate(ctx context.Context, code, codeVerifier, nonce string) (*oidc.Identity, error)
+}
+
+// renderer defines an interface for rendering notification templates.
+type renderer interface {
+	// Render renders the named template for the given locale with data.
+	Render(name, locale string, data any) (*notification.Message, error)
+}
+
+// mailer defines an interface for sending emails.
+type mailer interface {
+	// Send sends a rendered notification to the recipient's email address.
+	Send(ctx context.Context, to notification.Recipient, msg *notification.Message) error
 }
 
+// mailTimeout bounds the delivery of an account email.
+const mailTimeout = 30 * time.Second
+
+// maxNameLength is the length in characters of users.name.
+const maxNameLength = 50
+
+// recoveryCodeCount is the number of recovery codes issued when two-factor authentication is enabled.
+const recoveryCodeCount = 10
+
 // Service contains business logic for user management such as registration and authentication.
 type Service struct {
 	repository repository
+	sessions   sessionRepository
+	mfa        mfaRepository
+	guard      loginGuard
+	signer     tokenSigner
+	idp        identityProvider
+	renderer   renderer
+	mailer     mailer
 	cfg        *config.Config
+
+	sending sync.WaitGroup // account emails being sent
 }
 
-// NewService creates a new user service with the provided repository and configuration.
-func NewService(r repository, cfg *config.Config) *Service {
+// NewService creates a new user service with the provided repositories,
+// login guard, access token signer, OpenID Connect provider, account email
+// delivery and configuration.
+func NewService(
+	r repository,
+	sessions sessionRepository,
+	mfa mfaRepository,
+	guard loginGuard,
+	signer tokenSigner,
+	idp identityProvider,
+	rd renderer,
+	m mailer,
+	cfg *config.Config,
+) *Service {
 	return &Service{
 		repository: r,
+		sessions:   sessions,
+		mfa:        mfa,
+		guard:      guard,
+		signer:     signer,
+		idp:        idp,
+		renderer:   rd,
+		mailer:     m,
 		cfg:        cfg,
 	}
 }
 
-// Register creates a new user account with the given email, name, and password.
+// Register creates a new user account with the given email, name, password,
+// preferred locale and time zone.
 // It returns the created user's ID or an error if the user already exists or persistence fails.
-func (s *Service) Register(ctx context.Context, email, name, password string) (uuid.UUID, error) {
+func (s *Service) Register(ctx context.Context, email, name, password, locale, timezone string) (uuid.UUID, error) {
 	// Check if user already exists.
 	exists, err := s.repository.CheckUserExistsByEmail(ctx, email)
 	if err != nil {
@@ -67,10 +292,19 @@ func (s *Service) Register(ctx context.Context, email, name, password string) (u
 		return uuid.Nil, fmt.Errorf("hash password: %w", err)
 	}
 
+	if locale == "" {
+		locale = model.DefaultLocale
+	}
+	if timezone == "" {
+		timezone = model.DefaultTimezone
+	}
+
 	user := &model.User{
 		Email:    email,
 		Name:     name,
 		Password: hashedPassword,
+		Locale:   locale,
+		Timezone: timezone,
 	}
 
 	id, err := s.repository.CreateUser(ctx, user)
@@ -78,73 +312,1378 @@ func (s *Service) Register(ctx context.Context, email, name, password string) (u
 		return uuid.Nil, fmt.Errorf("create user: %w", err)
 	}
 
+	// Ask the user to verify their email address.
+	s.background(ctx, func(ctx context.Context) {
+		s.sendVerificationEmail(ctx, user)
+	})
+
 	return id, nil
 }
 
-// Login authenticates a user by email and password and returns a signed JWT token if successful.
-// Returns ErrInvalidCredentials if the user does not exist or the password is incorrect.
-func (s *Service) Login(ctx context.Context, email, password string) (string, error) {
+// VerifyEmail marks the email address of a user as verified using the token
+// from a verification link. Verifying twice is not an error. A token from an
+// email change link makes the pending address the user's email instead.
+// Returns ErrInvalidVerifyToken if the token is forged or expired, or the
+// user's email has changed since the link was sent, and ErrUserAlreadyExists
+// if another user took the new address in the meantime.
+func (s *Service) VerifyEmail(ctx context.Context, token string) error {
+	userID, email, err := parseVerificationToken(token, s.cfg.Auth.LinkSecret)
+	if err != nil {
+		return s.confirmEmailChange(ctx, token)
+	}
+
+	if err := s.repository.MarkEmailVerified(ctx, userID, email); err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return ErrInvalidVerifyToken
+		}
+
+		return fmt.Errorf("mark email verified: %w", err)
+	}
+
+	return nil
+}
+
+// confirmEmailChange makes the pending address of a user their email using
+// the token from an email change link. Errors are those of VerifyEmail.
+func (s *Service) confirmEmailChange(ctx context.Context, token string) error {
+	userID, email, err := parseEmailChangeToken(token, s.cfg.Auth.LinkSecret)
+	if err != nil {
+		return ErrInvalidVerifyToken
+	}
+
+	if err := s.repository.ConfirmPendingEmail(ctx, userID, email); err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return ErrInvalidVerifyToken
+		}
+		if errors.Is(err, userrepo.ErrEmailTaken) {
+			return ErrUserAlreadyExists
+		}
+
+		return fmt.Errorf("confirm pending email: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("email changed")
+
+	return nil
+}
+
+// ResendVerificationEmail sends a new verification link to the user.
+// Returns ErrEmailAlreadyVerified if the email address is verified.
+func (s *Service) ResendVerificationEmail(ctx context.Context, userID uuid.UUID) error {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("get user by id: %w", err)
+	}
+
+	if user.VerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	s.background(ctx, func(ctx context.Context) {
+		s.sendVerificationEmail(ctx, user)
+	})
+
+	return nil
+}
+
+// IsEmailVerified checks whether the email address of a user is verified.
+func (s *Service) IsEmailVerified(ctx context.Context, userID uuid.UUID) (bool, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return false, fmt.Errorf("get user by id: %w", err)
+	}
+
+	return user.VerifiedAt != nil, nil
+}
+
+// Login authenticates a user by email and password, coming from the client ip,
+// and returns a short-lived access token and a refresh token if successful.
+// Users with two-factor authentication get a challenge token instead, to be
+// completed with CompleteMFALogin.
+// Returns ErrInvalidCredentials if the user does not exist or the password is
+// incorrect, and the login guard's error if there were too many attempts.
+func (s *Service) Login(ctx context.Context, email, password, ip string) (*model.LoginResult, error) {
+	if err := s.guard.Attempt(ctx, email, ip); err != nil {
+		return nil, err
+	}
+
 	user, err := s.repository.GetUserByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, userrepo.ErrUserNotFound) {
-			return "", ErrInvalidCredentials
+			s.guard.Fail(ctx, email, ip, nil, model.LoginFailureInvalidCredentials)
+			return nil, ErrInvalidCredentials
 		}
 
-		return "", fmt.Errorf("get user by email: %w", err)
+		return nil, fmt.Errorf("get user by email: %w", err)
 	}
 
 	// Verify password.
 	if err := verifyPassword(password, user.Password); err != nil {
-		return "", ErrInvalidCredentials
+		s.guard.Fail(ctx, email, ip, &user.ID, model.LoginFailureInvalidCredentials)
+		return nil, ErrInvalidCredentials
 	}
 
-	// Generate JWT token.
-	token, err := generateToken(user, s.cfg.JWT.Secret, s.cfg.JWT.TTL)
+	return s.completeLogin(ctx, user, ip)
+}
+
+// CompleteMFALogin completes a login challenged for a second factor with a
+// TOTP code or an unused recovery code, and returns the tokens.
+// Wrong codes count against the login guard like wrong passwords.
+// Returns ErrInvalidChallenge if the challenge token is unknown, used or
+// expired, and ErrInvalidMFACode if the code is wrong or was already used.
+func (s *Service) CompleteMFALogin(ctx context.Context, challengeToken, code, ip string) (*model.Tokens, error) {
+	tokenHash := hashToken(challengeToken)
+
+	userID, err := s.sessions.GetUserTokenOwner(ctx, tokenHash, model.TokenPurposeMFAChallenge)
+	if err != nil {
+		if errors.Is(err, sessionrepo.ErrUserTokenNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+
+		return nil, fmt.Errorf("get challenge: %w", err)
+	}
+
+	user, err := s.repository.GetUserByID(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("generate token: %w", err)
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	if err := s.guard.Attempt(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkSecondFactor(ctx, user.ID, code); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			s.guard.Fail(ctx, user.Email, ip, &user.ID, model.LoginFailureInvalidMFACode)
+		}
+
+		return nil, err
+	}
+
+	// The challenge is single-use: a concurrent completion may have won.
+	if _, err := s.sessions.ConsumeUserToken(ctx, tokenHash, model.TokenPurposeMFAChallenge); err != nil {
+		if errors.Is(err, sessionrepo.ErrUserTokenNotFound) {
+			return nil, ErrInvalidChallenge
+		}
+
+		return nil, fmt.Errorf("consume challenge: %w", err)
 	}
 
-	return token, nil
+	s.guard.Succeed(ctx, user.Email, ip)
+
+	return s.issueTokens(ctx, user, uuid.New(), uuid.Nil, true)
 }
 
-// GetUserByID returns user info.
-func (s *Service) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+// StartOIDCLogin starts a login with the OpenID Connect provider and returns
+// the provider URL to send the user to. The state, nonce and PKCE verifier are
+// kept for CompleteOIDCLogin; only a hash of the state is stored.
+// Returns ErrOIDCDisabled if OpenID Connect login is not enabled.
+func (s *Service) StartOIDCLogin(ctx context.Context) (string, error) {
+	if !s.cfg.OIDC.Enabled {
+		return "", ErrOIDCDisabled
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := generateOpaqueToken()
+		if err != nil {
+			return "", fmt.Errorf("generate oidc secret: %w", err)
+		}
+		secrets[i] = secret
+	}
+	state, nonce, verifier := secrets[0], secrets[1], secrets[2]
+
+	err := s.sessions.CreateOIDCState(ctx, &model.OIDCState{
+		StateHash:    hashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(s.cfg.OIDC.StateTTL),
+	})
+	if err != nil {
+		return "", fmt.Errorf("store oidc state: %w", err)
+	}
+
+	authURL, err := s.idp.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", fmt.Errorf("build authorization url: %w", err)
+	}
+
+	return authURL, nil
+}
+
+// CompleteOIDCLogin completes a login with the OpenID Connect provider using
+// the state and authorization code it returned, coming from the client ip.
+// The provider account is linked to the user with its verified email, or a
+// new user is created on first login. Users with two-factor authentication
+// get a challenge like after a password login.
+// Returns ErrInvalidOIDCState if the state is unknown, used or expired,
+// ErrOIDCFailed if the provider rejects the code or returns an invalid ID
+// token, and ErrOIDCEmailNotVerified if a new account's email is not verified.
+func (s *Service) CompleteOIDCLogin(ctx context.Context, state, code, ip string) (*model.LoginResult, error) {
+	if !s.cfg.OIDC.Enabled {
+		return nil, ErrOIDCDisabled
+	}
+
+	st, err := s.sessions.ConsumeOIDCState(ctx, hashToken(state))
+	if err != nil {
+		if errors.Is(err, sessionrepo.ErrOIDCStateNotFound) {
+			return nil, ErrInvalidOIDCState
+		}
+
+		return nil, fmt.Errorf("consume oidc state: %w", err)
+	}
+
+	identity, err := s.idp.Authenticate(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		if errors.Is(err, oidc.ErrExchangeFailed) || errors.Is(err, oidc.ErrInvalidIDToken) {
+			zlog.Logger.Warn().Err(err).Msg("oidc authentication rejected")
+			return nil, ErrOIDCFailed
+		}
+
+		return nil, fmt.Errorf("authenticate with oidc provider: %w", err)
+	}
+
+	user, err := s.oidcUser(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.completeLogin(ctx, user, ip)
+}
+
+// oidcUser returns the user linked to a provider account. An unlinked account
+// is linked to the user with the same email, or to a new user, if the
+// provider has verified the email.
+func (s *Service) oidcUser(ctx context.Context, identity *oidc.Identity) (*model.User, error) {
+	user, err := s.repository.GetUserByIdentity(ctx, identity.Issuer, identity.Subject)
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, userrepo.ErrUserNotFound) {
+		return nil, fmt.Errorf("get user by identity: %w", err)
+	}
+
+	// Linking by an unverified email would hand over the account of whoever owns it.
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrOIDCEmailNotVerified
+	}
+
+	link := &model.UserIdentity{
+		Issuer:  identity.Issuer,
+		Subject: identity.Subject,
+		Email:   identity.Email,
+	}
+
+	// Providers do not keep the case of the address the user registered with.
+	user, err = s.repository.GetUserByEmailFold(ctx, identity.Email)
+	switch {
+	case err == nil:
+		// The provider has vouched for the email's owner; linking removes
+		// the password of an unverified account.
+		if user.VerifiedAt == nil {
+			if err := s.evictUnverifiedUser(ctx, user.ID); err != nil {
+				return nil, err
+			}
+		}
+
+		link.UserID = user.ID
+		if err := s.repository.LinkIdentity(ctx, link); err != nil {
+			return nil, fmt.Errorf("link identity: %w", err)
+		}
+
+		zlog.Logger.Info().Str("user_id", user.ID.String()).Str("issuer", identity.Issuer).
+			Msg("oidc identity linked to existing user")
+
+		return user, nil
+	case errors.Is(err, userrepo.ErrUserNotFound):
+		user = &model.User{
+			Email:    identity.Email,
+			Name:     normalizeName(identity.Name),
+			Locale:   model.DefaultLocale,
+			Timezone: model.DefaultTimezone,
+		}
+		if err := s.repository.CreateUserWithIdentity(ctx, user, link); err != nil {
+			return nil, fmt.Errorf("create user with identity: %w", err)
+		}
+
+		zlog.Logger.Info().Str("user_id", user.ID.String()).Str("issuer", identity.Issuer).
+			Msg("user created on first oidc login")
+
+		return user, nil
+	default:
+		return nil, fmt.Errorf("get user by email: %w", err)
+	}
+}
+
+// RequestMagicLink emails a single-use login link to the user with the given
+// email. Like ForgotPassword, unknown emails are silently ignored and the
+// email is sent in the background.
+func (s *Service) RequestMagicLink(ctx context.Context, email string) error {
+	user, err := s.repository.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return nil
+		}
+
+		return fmt.Errorf("get user by email: %w", err)
+	}
+
+	return s.sendMagicLink(ctx, user, "")
+}
+
+// sendMagicLink emails a user a single-use login link, replacing older ones,
+// that opens the page next of the web UI if it is set.
+func (s *Service) sendMagicLink(ctx context.Context, user *model.User, next string) error {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("generate magic link token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.Auth.MagicLinkTTL)
+	err = s.sessions.CreateUserToken(ctx, &model.UserToken{
+		UserID:    user.ID,
+		Purpose:   model.TokenPurposeMagicLink,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("store magic link token: %w", err)
+	}
+
+	loginURL := s.cfg.App.BaseURL + "/magic-link?token=" + token
+	if next != "" {
+		loginURL += "&next=" + url.QueryEscape(next)
+	}
+
+	s.background(ctx, func(ctx context.Context) {
+		s.sendEmail(ctx, user, notification.TemplateMagicLink, map[string]any{
+			"UserName":  user.Name,
+			"LoginURL":  loginURL,
+			"ExpiresAt": expiresAt,
+			"Timezone":  user.Timezone,
+		})
+	})
+
+	return nil
+}
+
+// LoginWithMagicLink logs a user in with the token from a login link.
+// The token can be used once. Opening the link proves
+// that the user owns the email address, so it is marked verified. If it was
+// not verified before, whoever registered the account is logged out and the
+// password, second factor and notification preferences they set up are removed.
+// Users with two-factor authentication get a challenge token, as with Login.
+// Returns ErrInvalidMagicLink if the token is unknown, used or expired.
+func (s *Service) LoginWithMagicLink(ctx context.Context, token, ip string) (*model.LoginResult, error) {
+	userID, err := s.sessions.ConsumeUserToken(ctx, hashToken(token), model.TokenPurposeMagicLink)
+	if err != nil {
+		if errors.Is(err, sessionrepo.ErrUserTokenNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+
+		return nil, fmt.Errorf("consume magic link token: %w", err)
+	}
+
 	user, err := s.repository.GetUserByID(ctx, userID)
 	if err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return nil, ErrInvalidMagicLink
+		}
+
 		return nil, fmt.Errorf("get user by id: %w", err)
 	}
-	return user, nil
+
+	// The link proves owning the email; claiming removes the password of an
+	// unverified account.
+	if user.VerifiedAt == nil {
+		if err := s.evictUnverifiedUser(ctx, user.ID); err != nil {
+			return nil, err
+		}
+		if err := s.repository.ClaimUnverifiedUser(ctx, user.ID); err != nil {
+			return nil, fmt.Errorf("claim unverified user: %w", err)
+		}
+	}
+
+	return s.completeLogin(ctx, user, ip)
 }
 
-// hashPassword generates a bcrypt hash for the given password.
-func hashPassword(password string) (string, error) {
-	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// evictUnverifiedUser logs out all sessions of an unverified user and removes
+// their second factor. The account may have been registered by someone else
+// ahead of the email's owner, who has now proved owning it.
+func (s *Service) evictUnverifiedUser(ctx context.Context, userID uuid.UUID) error {
+	if err := s.sessions.RevokeUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("revoke user tokens: %w", err)
+	}
+	if err := s.mfa.DeleteMFA(ctx, userID); err != nil {
+		return fmt.Errorf("delete mfa: %w", err)
+	}
 
-	return string(hash), err
+	return nil
 }
 
-// verifyPassword verifies if the given password matches the stored hash.
-func verifyPassword(password, hash string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+// GuestUser returns the user a guest booking with an email address is made
+// for, creating a lightweight one without a password and with an unverified
+// email if there is none. Users created this way are reused by later guest
+// bookings until their email is verified. An address with another account
+// gets a login link that opens the event instead, so that only the account
+// itself books with it, and nil is returned. The email is sent in the
+// background, so the response does not reveal whether the address has an
+// account.
+func (s *Service) GuestUser(ctx context.Context, email, name string, event *model.Event) (*model.User, error) {
+	user, err := s.repository.GetUserByEmail(ctx, email)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		user = &model.User{
+			Email:    email,
+			Name:     normalizeName(name),
+			Locale:   model.DefaultLocale,
+			Timezone: model.DefaultTimezone,
+		}
+
+		err = s.repository.CreateGuestUser(ctx, user)
+		if err == nil {
+			zlog.Logger.Info().Str("user_id", user.ID.String()).Msg("guest user created")
+			return user, nil
+		}
+
+		// Another request created the user in the meantime.
+		if errors.Is(err, userrepo.ErrEmailTaken) {
+			user, err = s.repository.GetUserByEmail(ctx, email)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get guest user: %w", err)
+	}
+
+	if user.VerifiedAt == nil && user.Password == "" {
+		return user, nil
+	}
+
+	return nil, s.sendMagicLink(ctx, user, "/events/"+event.ID.String())
 }
 
-// generateToken creates a signed JWT token containing the user's ID, name, and email.
-// The token expires after the configured TTL.
-func generateToken(user *model.User, secret string, ttl time.Duration) (string, error) {
-	expTime := time.Now().Add(ttl)
+// SendGuestBooking emails a guest the hold on their seat at event with a
+// link that confirms the booking, see ClaimGuestBooking. The link expires
+// with the hold. The email is sent in the background.
+func (s *Service) SendGuestBooking(ctx context.Context, user *model.User, event *model.Event, booking *model.Booking) {
+	fields := []string{event.ID.String(), booking.ID.String(), user.ID.String(), user.Email}
+	token := signLinkToken(guestBookingPrefix, fields, booking.ExpiresAt, s.cfg.Auth.LinkSecret)
 
-	// Create the JWT claims.
-	claims := jwt.MapClaims{
-		"user_id": user.ID.String(),
-		"name":    user.Name,
-		"email":   user.Email,
-		"exp":     expTime.Unix(),    // expiration time
-		"iat":     time.Now().Unix(), // issued at time
+	s.background(ctx, func(ctx context.Context) {
+		s.sendEmail(ctx, user, notification.TemplateGuestBooking, map[string]any{
+			"UserName":   user.Name,
+			"EventTitle": event.Title,
+			"ExpiresAt":  booking.ExpiresAt,
+			"Timezone":   user.Timezone,
+			"ConfirmURL": s.cfg.App.BaseURL + "/guest-booking?event=" + event.ID.String() + "&token=" + token,
+		})
+	})
+}
+
+// ClaimGuestBooking checks the token of a guest booking link for eventID and
+// returns the guest and the id of the booking it confirms. Following the
+// link proves that the guest owns the address, so their email is marked
+// verified. Guests have no password; they log in with emailed links or set a
+// password with ForgotPassword.
+// Returns ErrInvalidGuestBooking if the token is invalid, expired, for
+// another event or the guest's email has changed.
+func (s *Service) ClaimGuestBooking(ctx context.Context, token string, eventID uuid.UUID) (*model.User, uuid.UUID, error) {
+	fields, err := parseLinkToken(token, guestBookingPrefix, 4, s.cfg.Auth.LinkSecret)
+	if err != nil || fields[0] != eventID.String() {
+		return nil, uuid.Nil, ErrInvalidGuestBooking
+	}
+
+	bookingID, err := uuid.Parse(fields[1])
+	if err != nil {
+		return nil, uuid.Nil, ErrInvalidGuestBooking
+	}
+	userID, err := uuid.Parse(fields[2])
+	if err != nil {
+		return nil, uuid.Nil, ErrInvalidGuestBooking
+	}
+
+	if err := s.repository.MarkEmailVerified(ctx, userID, fields[3]); err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return nil, uuid.Nil, ErrInvalidGuestBooking
+		}
+
+		return nil, uuid.Nil, fmt.Errorf("mark email verified: %w", err)
+	}
+
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, uuid.Nil, fmt.Errorf("get user by id: %w", err)
 	}
 
-	// Create the token with claims.
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return user, bookingID, nil
+}
+
+// LoginGuest logs in a guest who followed a guest booking link, see ClaimGuestBooking.
+func (s *Service) LoginGuest(ctx context.Context, user *model.User, ip string) (*model.LoginResult, error) {
+	return s.completeLogin(ctx, user, ip)
+}
+
+// EnrollMFA starts two-factor enrollment for a user with a new TOTP secret,
+// replacing an unverified one. The enrollment has no effect until it is
+// verified with ConfirmMFA.
+// Returns ErrMFAAlreadyEnabled if the user has two-factor authentication enabled.
+func (s *Service) EnrollMFA(ctx context.Context, userID uuid.UUID) (*model.MFAEnrollment, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("generate totp secret: %w", err)
+	}
+
+	if err := s.mfa.SavePendingSecret(ctx, userID, secret); err != nil {
+		if errors.Is(err, mfarepo.ErrMFAAlreadyEnabled) {
+			return nil, ErrMFAAlreadyEnabled
+		}
+
+		return nil, fmt.Errorf("save totp secret: %w", err)
+	}
+
+	return &model.MFAEnrollment{
+		Secret:          secret,
+		ProvisioningURI: totp.ProvisioningURI(s.cfg.Auth.MFAIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFA verifies a pending enrollment with a code from the authenticator
+// app, enables two-factor authentication and returns new recovery codes.
+// The codes are stored hashed, so they are only shown here.
+// Returns ErrMFANotEnrolled if there is no pending enrollment,
+// ErrMFAAlreadyEnabled if it is already verified and ErrInvalidMFACode if the code is wrong.
+func (s *Service) ConfirmMFA(ctx context.Context, userID uuid.UUID, code string) ([]string, error) {
+	m, err := s.mfa.GetMFA(ctx, userID)
+	if err != nil {
+		if errors.Is(err, mfarepo.ErrMFANotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+
+		return nil, fmt.Errorf("get mfa: %w", err)
+	}
+	if m.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, ok := totp.Validate(m.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		if codes[i], err = generateRecoveryCode(); err != nil {
+			return nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		hashes[i] = hashToken(normalizeRecoveryCode(codes[i]))
+	}
+
+	if err := s.mfa.Confirm(ctx, userID, step, hashes); err != nil {
+		if errors.Is(err, mfarepo.ErrMFANotFound) {
+			return nil, ErrMFANotEnrolled
+		}
+
+		return nil, fmt.Errorf("confirm mfa: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("two-factor authentication enabled")
+
+	return codes, nil
+}
+
+// DisableMFA turns off two-factor authentication after checking a TOTP code
+// or a recovery code, and revokes all sessions of the user.
+// Returns ErrMFANotEnrolled if it is not enabled and ErrInvalidMFACode if the code is wrong.
+func (s *Service) DisableMFA(ctx context.Context, userID uuid.UUID, code string) error {
+	if err := s.checkSecondFactor(ctx, userID, code); err != nil {
+		return err
+	}
+
+	if err := s.mfa.DeleteMFA(ctx, userID); err != nil {
+		return fmt.Errorf("delete mfa: %w", err)
+	}
+
+	if err := s.sessions.RevokeUserTokens(ctx, userID); err != nil {
+		return fmt.Errorf("revoke user tokens: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("two-factor authentication disabled, sessions revoked")
+
+	return nil
+}
+
+// completeLogin finishes a login whose first factor is checked: users with
+// two-factor authentication get a challenge, everybody else gets tokens.
+func (s *Service) completeLogin(ctx context.Context, user *model.User, ip string) (*model.LoginResult, error) {
+	m, err := s.mfa.GetMFA(ctx, user.ID)
+	if err != nil && !errors.Is(err, mfarepo.ErrMFANotFound) {
+		return nil, fmt.Errorf("get mfa: %w", err)
+	}
+
+	if m.Enabled() {
+		// Keep the counts until the second factor is checked too.
+		return s.challenge(ctx, user)
+	}
+
+	s.guard.Succeed(ctx, user.Email, ip)
+
+	// Every login starts a new token family.
+	tokens, err := s.issueTokens(ctx, user, uuid.New(), uuid.Nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LoginResult{Tokens: tokens}, nil
+}
+
+// challenge stores a single-use login challenge for a user with two-factor authentication.
+func (s *Service) challenge(ctx context.Context, user *model.User) (*model.LoginResult, error) {
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate challenge token: %w", err)
+	}
+
+	err = s.sessions.CreateUserToken(ctx, &model.UserToken{
+		UserID:    user.ID,
+		Purpose:   model.TokenPurposeMFAChallenge,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.cfg.Auth.MFAChallengeTTL),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("store challenge token: %w", err)
+	}
+
+	return &model.LoginResult{
+		MFARequired:        true,
+		ChallengeToken:     token,
+		ChallengeExpiresIn: int64(s.cfg.Auth.MFAChallengeTTL.Seconds()),
+	}, nil
+}
+
+// checkSecondFactor checks a 6-digit TOTP code, which can be used once, or
+// else an unused recovery code, which is used up.
+// Returns ErrMFANotEnrolled if two-factor authentication is not enabled and
+// ErrInvalidMFACode if the code is wrong.
+func (s *Service) checkSecondFactor(ctx context.Context, userID uuid.UUID, code string) error {
+	m, err := s.mfa.GetMFA(ctx, userID)
+	if err != nil && !errors.Is(err, mfarepo.ErrMFANotFound) {
+		return fmt.Errorf("get mfa: %w", err)
+	}
+	if !m.Enabled() {
+		return ErrMFANotEnrolled
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(m.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		if err := s.mfa.UseStep(ctx, userID, step); err != nil {
+			if errors.Is(err, mfarepo.ErrCodeAlreadyUsed) {
+				return ErrInvalidMFACode
+			}
+
+			return fmt.Errorf("use totp step: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := s.mfa.UseRecoveryCode(ctx, userID, hashToken(normalizeRecoveryCode(code))); err != nil {
+		if errors.Is(err, mfarepo.ErrRecoveryCodeNotFound) {
+			return ErrInvalidMFACode
+		}
+
+		return fmt.Errorf("use recovery code: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("recovery code used")
+
+	return nil
+}
+
+// Refresh exchanges a refresh token for a new access token and a new refresh
+// token; the presented one can no longer be used.
+//
+// If an already rotated refresh token is presented, it has probably been
+// stolen, so its whole family is revoked. Returns ErrInvalidRefreshToken
+// if the token is unknown, revoked or expired.
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*model.Tokens, error) {
+	rt, err := s.sessions.GetRefreshToken(ctx, hashToken(refreshToken))
+	if err != nil {
+		if errors.Is(err, sessionrepo.ErrRefreshTokenNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		return nil, fmt.Errorf("get refresh token: %w", err)
+	}
+
+	if rt.RevokedAt != nil {
+		if rt.ReplacedBy != nil {
+			s.revokeReusedFamily(ctx, rt)
+		}
+
+		return nil, ErrInvalidRefreshToken
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Load the user again, so the new access token carries the current role.
+	user, err := s.repository.GetUserByID(ctx, rt.UserID)
+	if err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return nil, ErrInvalidRefreshToken
+		}
+
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	tokens, err := s.issueTokens(ctx, user, rt.FamilyID, rt.ID, rt.MFA)
+	if err != nil {
+		// Another request rotated the token first: it was presented twice.
+		if errors.Is(err, sessionrepo.ErrRefreshTokenRevoked) {
+			s.revokeReusedFamily(ctx, rt)
+			return nil, ErrInvalidRefreshToken
+		}
+
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Logout revokes the session the access token jti belongs to, or all sessions
+// of the user if all is true.
+func (s *Service) Logout(ctx context.Context, userID, jti uuid.UUID, all bool) error {
+	if all {
+		if err := s.sessions.RevokeUserTokens(ctx, userID); err != nil {
+			return fmt.Errorf("revoke user tokens: %w", err)
+		}
+
+		return nil
+	}
+
+	if err := s.sessions.RevokeFamilyOfAccessToken(ctx, jti); err != nil {
+		return fmt.Errorf("revoke token family: %w", err)
+	}
+
+	return nil
+}
+
+// IsAccessTokenRevoked checks whether the access token jti was revoked.
+func (s *Service) IsAccessTokenRevoked(ctx context.Context, jti uuid.UUID) (bool, error) {
+	revoked, err := s.sessions.IsAccessTokenRevoked(ctx, jti)
+	if err != nil {
+		return false, fmt.Errorf("check if access token is revoked: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// ForgotPassword emails a single-use password reset link to the user with the
+// given email. Unknown emails are silently ignored and the email is sent in the
+// background, so the caller cannot tell whether the email is registered.
+func (s *Service) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.repository.GetUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, userrepo.ErrUserNotFound) {
+			return nil
+		}
+
+		return fmt.Errorf("get user by email: %w", err)
+	}
+
+	token, err := generateOpaqueToken()
+	if err != nil {
+		return fmt.Errorf("generate reset token: %w", err)
+	}
+
+	expiresAt := time.Now().Add(s.cfg.Auth.PasswordResetTTL)
+	err = s.sessions.CreateUserToken(ctx, &model.UserToken{
+		UserID:    user.ID,
+		Purpose:   model.TokenPurposePasswordReset,
+		TokenHash: hashToken(token),
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("store reset token: %w", err)
+	}
+
+	s.background(ctx, func(ctx context.Context) {
+		s.sendEmail(ctx, user, notification.TemplatePasswordReset, map[string]any{
+			"UserName":  user.Name,
+			"ResetURL":  s.cfg.App.BaseURL + "/reset-password?token=" + token,
+			"ExpiresAt": expiresAt,
+			"Timezone":  user.Timezone,
+		})
+	})
+
+	return nil
+}
+
+// ResetPassword sets a new password using a token from a password reset email.
+// The token can be used once. All sessions of the user are revoked and a
+// pending email change is dropped.
+// Returns ErrInvalidResetToken if the token is unknown, used or expired.
+func (s *Service) ResetPassword(ctx context.Context, token, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	userID, err := s.sessions.ResetPassword(ctx, hashToken(token), hash)
+	if err != nil {
+		if errors.Is(err, sessionrepo.ErrUserTokenNotFound) {
+			return ErrInvalidResetToken
+		}
+
+		return fmt.Errorf("reset password: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("password reset, sessions revoked")
+
+	return nil
+}
+
+// sendVerificationEmail emails a signed verification link to the user.
+func (s *Service) sendVerificationEmail(ctx context.Context, user *model.User) {
+	expiresAt := time.Now().Add(s.cfg.Auth.EmailVerificationTTL)
+	token := signVerificationToken(user.ID, user.Email, expiresAt, s.cfg.Auth.LinkSecret)
+
+	s.sendEmail(ctx, user, notification.TemplateVerifyEmail, map[string]any{
+		"UserName":  user.Name,
+		"VerifyURL": s.cfg.App.BaseURL + "/verify-email?token=" + token,
+		"ExpiresAt": expiresAt,
+		"Timezone":  user.Timezone,
+	})
+}
+
+// sendEmailChangeEmails emails a signed confirmation link to the new address
+// of the user and a notice of the requested change to their current address.
+func (s *Service) sendEmailChangeEmails(ctx context.Context, user *model.User, email string) {
+	expiresAt := time.Now().Add(s.cfg.Auth.EmailVerificationTTL)
+	token := signEmailChangeToken(user.ID, email, expiresAt, s.cfg.Auth.LinkSecret)
+
+	pending := *user
+	pending.Email = email
+	s.sendEmail(ctx, &pending, notification.TemplateEmailChange, map[string]any{
+		"UserName":   user.Name,
+		"ConfirmURL": s.cfg.App.BaseURL + "/verify-email?token=" + token,
+		"ExpiresAt":  expiresAt,
+		"Timezone":   user.Timezone,
+	})
+
+	s.sendEmail(ctx, user, notification.TemplateEmailChangeNotice, map[string]any{
+		"UserName": user.Name,
+		"NewEmail": email,
+	})
+}
+
+// background sends account emails with fn outside the request, with a
+// context that is not cancelled with ctx. Wait waits for them.
+func (s *Service) background(ctx context.Context, fn func(ctx context.Context)) {
+	s.sending.Add(1)
+	go func() {
+		defer s.sending.Done()
+		fn(context.WithoutCancel(ctx))
+	}()
+}
+
+// Wait waits for the account emails being sent, e.g. before the application
+// exits, so a request that was answered does not lose its email. If ctx is
+// done first, Wait returns ctx's error and the emails are given up.
+func (s *Service) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.sending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		zlog.Logger.Warn().Msg("timeout waiting for account emails")
+		return ctx.Err()
+	}
+}
+
+// sendEmail renders the named template in the user's locale and emails it.
+// Failures are only logged: account emails are sent outside the request.
+func (s *Service) sendEmail(ctx context.Context, user *model.User, template string, data map[string]any) {
+	ctx, cancel := context.WithTimeout(ctx, mailTimeout)
+	defer cancel()
+
+	msg, err := s.renderer.Render(template, user.Locale, data)
+	if err != nil {
+		zlog.Logger.Error().Err(err).Str("template", template).Msg("failed to render email")
+		return
+	}
+
+	to := notification.Recipient{UserID: user.ID, Email: user.Email}
+	if err := s.mailer.Send(ctx, to, msg); err != nil {
+		zlog.Logger.Error().Err(err).Str("template", template).Str("user_id", user.ID.String()).
+			Msg("failed to send email")
+	}
+}
+
+// issueTokens issues an access token and a refresh token in the given family.
+// If replaces is set, the refresh token with that id is rotated out. mfa
+// records whether the family's login was completed with a second factor.
+func (s *Service) issueTokens(
+	ctx context.Context,
+	user *model.User,
+	familyID, replaces uuid.UUID,
+	mfa bool,
+) (*model.Tokens, error) {
+	jti := uuid.New()
+	accessExpiresAt := time.Now().Add(s.cfg.JWT.TTL)
+
+	// Generate JWT token.
+	accessToken, err := generateToken(user, jti, mfa, s.signer, accessExpiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("generate token: %w", err)
+	}
+
+	refreshToken, err := generateOpaqueToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate refresh token: %w", err)
+	}
+
+	rt := &model.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        familyID,
+		TokenHash:       hashToken(refreshToken),
+		AccessJTI:       jti,
+		AccessExpiresAt: accessExpiresAt,
+		ExpiresAt:       time.Now().Add(s.cfg.JWT.RefreshTTL),
+		MFA:             mfa,
+	}
+
+	if replaces == uuid.Nil {
+		err = s.sessions.CreateRefreshToken(ctx, rt)
+	} else {
+		err = s.sessions.RotateRefreshToken(ctx, replaces, rt)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("store refresh token: %w", err)
+	}
+
+	return &model.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(s.cfg.JWT.TTL.Seconds()),
+	}, nil
+}
+
+// revokeReusedFamily revokes the family of a refresh token that was presented
+// after it had been rotated. Failures are only logged: the refresh is rejected anyway.
+func (s *Service) revokeReusedFamily(ctx context.Context, rt *model.RefreshToken) {
+	zlog.Logger.Warn().Str("user_id", rt.UserID.String()).Str("family_id", rt.FamilyID.String()).
+		Msg("rotated refresh token reused, revoking its family")
+
+	if err := s.sessions.RevokeFamily(ctx, rt.FamilyID); err != nil {
+		zlog.Logger.Error().Err(err).Msg("failed to revoke token family")
+	}
+}
+
+// GetUserByID returns user info.
+func (s *Service) GetUserByID(ctx context.Context, userID uuid.UUID) (*model.User, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+	return user, nil
+}
+
+// GetNotificationPreferences returns the notification preferences of a user.
+func (s *Service) GetNotificationPreferences(ctx context.Context, userID uuid.UUID) (*model.NotificationPreferences, error) {
+	prefs, err := s.repository.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get notification preferences: %w", err)
+	}
+
+	return prefs, nil
+}
+
+// UpdateNotificationPreferences replaces the notification preferences of a user.
+// Channels that need an address require it to be set.
+func (s *Service) UpdateNotificationPreferences(ctx context.Context, prefs *model.NotificationPreferences) error {
+	if err := checkNotificationPreferences(prefs); err != nil {
+		return err
+	}
+
+	if err := s.repository.SaveNotificationPreferences(ctx, prefs); err != nil {
+		return fmt.Errorf("save notification preferences: %w", err)
+	}
+
+	return nil
+}
+
+// checkNotificationPreferences drops duplicate channels and checks that the
+// channels that need an address have it.
+func checkNotificationPreferences(prefs *model.NotificationPreferences) error {
+	prefs.Channels = slices.Compact(slices.Sorted(slices.Values(prefs.Channels)))
+
+	if slices.Contains(prefs.Channels, notification.ChannelWebhook) && prefs.WebhookURL == "" {
+		return ErrWebhookURLRequired
+	}
+	if slices.Contains(prefs.Channels, notification.ChannelTelegram) && prefs.TelegramChatID == "" {
+		return ErrTelegramChatIDRequired
+	}
+
+	return nil
+}
+
+// GetProfile returns the account and notification preferences of a user.
+func (s *Service) GetProfile(ctx context.Context, userID uuid.UUID) (*model.Profile, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	prefs, err := s.repository.GetNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get notification preferences: %w", err)
+	}
+
+	return &model.Profile{User: user, NotificationPreferences: prefs}, nil
+}
+
+// UpdateProfile applies a partial update to the profile of a user and returns
+// the updated profile. Everything is checked first and then saved in one
+// transaction, so a failed update changes nothing.
+// Names are kept on one line, like those of guests and SSO users.
+// Notification preferences are replaced as with UpdateNotificationPreferences.
+// Returns ErrInvalidName if the name is blank.
+func (s *Service) UpdateProfile(ctx context.Context, userID uuid.UUID, update *model.ProfileUpdate) (*model.Profile, error) {
+	if update.Name != nil {
+		name := normalizeName(*update.Name)
+		if name == "" {
+			return nil, ErrInvalidName
+		}
+		update.Name = &name
+	}
+
+	if update.NotificationPreferences != nil {
+		update.NotificationPreferences.UserID = userID
+		if err := checkNotificationPreferences(update.NotificationPreferences); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	if update.Name != nil {
+		user.Name = *update.Name
+	}
+	if update.Locale != nil {
+		user.Locale = *update.Locale
+	}
+	if update.Timezone != nil {
+		user.Timezone = *update.Timezone
+	}
+
+	if err := s.repository.UpdateProfile(ctx, user, update.NotificationPreferences); err != nil {
+		return nil, fmt.Errorf("update profile: %w", err)
+	}
+
+	return s.GetProfile(ctx, userID)
+}
+
+// ChangePassword sets a new password after checking the current one, coming
+// from the client ip. Reset links are invalidated, a pending email change is
+// dropped and all sessions of the user are revoked. Wrong passwords count against the login guard.
+// Returns ErrPasswordNotSet if the user signs in without a password,
+// ErrWrongPassword if the current password is wrong, and the login guard's
+// error if there were too many attempts.
+func (s *Service) ChangePassword(ctx context.Context, userID uuid.UUID, current, password, ip string) error {
+	if _, err := s.checkPassword(ctx, userID, current, ip); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	if err := s.sessions.ChangePassword(ctx, userID, hash); err != nil {
+		return fmt.Errorf("change password: %w", err)
+	}
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("password changed, sessions revoked")
+
+	return nil
+}
+
+// ChangeEmail requests a new email address after checking the password,
+// coming from the client ip. The address becomes pending: a confirmation
+// link is emailed to it and the email only changes once the link is opened,
+// so the user keeps their verified address until then. The old address is
+// told about the request. Returns the user with the pending address.
+// Returns ErrPasswordNotSet or ErrWrongPassword as ChangePassword does,
+// ErrEmailUnchanged if the address is the current one and
+// ErrUserAlreadyExists if another user has it.
+func (s *Service) ChangeEmail(ctx context.Context, userID uuid.UUID, password, email, ip string) (*model.User, error) {
+	user, err := s.checkPassword(ctx, userID, password, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	if email == user.Email {
+		return nil, ErrEmailUnchanged
+	}
+
+	exists, err := s.repository.CheckUserExistsByEmail(ctx, email)
+	if err != nil {
+		return nil, fmt.Errorf("check if user exists: %w", err)
+	}
+	if exists {
+		return nil, ErrUserAlreadyExists
+	}
+
+	if err := s.repository.SetPendingEmail(ctx, userID, email); err != nil {
+		return nil, fmt.Errorf("set pending email: %w", err)
+	}
+
+	user.PendingEmail = &email
+
+	zlog.Logger.Info().Str("user_id", userID.String()).Msg("email change requested")
+
+	// Ask the user to confirm the new address, and let the old one know.
+	s.background(ctx, func(ctx context.Context) {
+		s.sendEmailChangeEmails(ctx, user, email)
+	})
+
+	return user, nil
+}
+
+// checkPassword re-authenticates a signed-in user with their password before
+// a sensitive change and returns the user. Attempts go through the login guard.
+func (s *Service) checkPassword(ctx context.Context, userID uuid.UUID, password, ip string) (*model.User, error) {
+	user, err := s.repository.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user by id: %w", err)
+	}
+
+	hash, err := s.repository.GetPasswordHash(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("get password hash: %w", err)
+	}
+
+	// Guests and single sign-on users have no password to check.
+	if hash == "" {
+		return nil, ErrPasswordNotSet
+	}
+
+	if err := s.guard.Attempt(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	if err := verifyPassword(password, hash); err != nil {
+		s.guard.Fail(ctx, user.Email, ip, &user.ID, model.LoginFailureInvalidCredentials)
+		return nil, ErrWrongPassword
+	}
+
+	s.guard.Succeed(ctx, user.Email, ip)
+
+	return user, nil
+}
+
+// hashPassword generates a bcrypt hash for the given password.
+func hashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+
+	return string(hash), err
+}
+
+// verifyPassword verifies if the given password matches the stored hash.
+func verifyPassword(password, hash string) error {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+}
+
+// generateToken creates a signed JWT token containing the user's ID, name, email and role,
+// and whether the login was completed with a second factor.
+// The token is identified by jti, so it can be revoked, and expires at expiresAt.
+func generateToken(user *model.User, jti uuid.UUID, mfa bool, signer tokenSigner, expiresAt time.Time) (string, error) {
+	// Create the JWT claims.
+	claims := jwt.MapClaims{
+		"jti":     jti.String(),
+		"user_id": user.ID.String(),
+		"name":    user.Name,
+		"email":   user.Email,
+		"role":    user.Role,
+		"mfa":     mfa,
+		"exp":     expiresAt.Unix(),  // expiration time
+		"iat":     time.Now().Unix(), // issued at time
+	}
+
+	// Sign the token with the current signing key and return.
+	return signer.Sign(claims)
+}
+
+// generateOpaqueToken returns a random URL-safe token with 256 bits of entropy.
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex-encoded SHA-256 hash under which an opaque token is stored.
+// Opaque tokens are random, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// generateRecoveryCode returns a random recovery code with 80 bits of entropy,
+// formatted as four groups of four lowercase base32 characters.
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// normalizeName keeps a name on one line and cuts it to maxNameLength
+// characters, so names from outside the API fit in users.name.
+func normalizeName(name string) string {
+	name = strings.Join(strings.Fields(name), " ")
+	if r := []rune(name); len(r) > maxNameLength {
+		name = strings.TrimSpace(string(r[:maxNameLength]))
+	}
+
+	return name
+}
+
+// normalizeRecoveryCode drops separators and case, so a code is accepted however it is typed.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// Prefixes separating the signatures of the link tokens signed with the link secret.
+const (
+	verificationPrefix = "verify-email\n"
+	emailChangePrefix  = "change-email\n"
+	guestBookingPrefix = "guest-booking\n"
+)
+
+// signVerificationToken returns a token for an email verification link. It
+// binds the user id, the email address and the expiry time, signed with
+// HMAC-SHA256, so no server-side state is needed.
+func signVerificationToken(userID uuid.UUID, email string, expiresAt time.Time, secret string) string {
+	return signLinkToken(verificationPrefix, []string{userID.String(), email}, expiresAt, secret)
+}
+
+// parseVerificationToken checks the signature and expiry of a verification
+// token and returns the user id and email address it was issued for.
+func parseVerificationToken(token, secret string) (uuid.UUID, string, error) {
+	fields, err := parseLinkToken(token, verificationPrefix, 2, secret)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	userID, err := uuid.Parse(fields[0])
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("parse user id: %w", err)
+	}
+
+	return userID, fields[1], nil
+}
+
+// signEmailChangeToken returns a token for an email change link, binding the
+// user id and the new email address like a verification token.
+func signEmailChangeToken(userID uuid.UUID, email string, expiresAt time.Time, secret string) string {
+	return signLinkToken(emailChangePrefix, []string{userID.String(), email}, expiresAt, secret)
+}
+
+// parseEmailChangeToken checks the signature and expiry of an email change
+// token and returns the user id and new email address it was issued for.
+func parseEmailChangeToken(token, secret string) (uuid.UUID, string, error) {
+	fields, err := parseLinkToken(token, emailChangePrefix, 2, secret)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+
+	userID, err := uuid.Parse(fields[0])
+	if err != nil {
+		return uuid.Nil, "", fmt.Errorf("parse user id: %w", err)
+	}
+
+	return userID, fields[1], nil
+}
+
+// signLinkToken returns a token binding fields, which must not contain
+// newlines, and the expiry time, signed with HMAC-SHA256 after prefix.
+func signLinkToken(prefix string, fields []string, expiresAt time.Time, secret string) string {
+	payload := strings.Join(fields, "\n") + "\n" + strconv.FormatInt(expiresAt.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(prefix + payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." +
+		base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// parseLinkToken checks the signature and expiry of a token signed with
+// signLinkToken after prefix and returns its n fields.
+func parseLinkToken(token, prefix string, n int, secret string) ([]string, error) {
+	encPayload, encSig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errors.New("malformed token")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encPayload)
+	if err != nil {
+		return nil, fmt.Errorf("decode payload: %w", err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(encSig)
+	if err != nil {
+		return nil, fmt.Errorf("decode signature: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(prefix))
+	mac.Write(payload)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid signature")
+	}
+
+	parts := strings.Split(string(payload), "\n")
+	if len(parts) != n+1 {
+		return nil, errors.New("malformed payload")
+	}
+
+	exp, err := strconv.ParseInt(parts[n], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parse expiry: %w", err)
+	}
+	if time.Now().Unix() > exp {
+		return nil, errors.New("token expired")
+	}
 
-	// Sign the token with a secret key and return.
-	return token.SignedString([]byte(secret))
+	return parts[:n], nil
 }
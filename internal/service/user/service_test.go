@@ -0,0 +1,389 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aliskhannn/event-booker/internal/config"
+	"github.com/aliskhannn/event-booker/internal/model"
+	"github.com/aliskhannn/event-booker/internal/notification"
+	"github.com/aliskhannn/event-booker/internal/oidc"
+	userrepo "github.com/aliskhannn/event-booker/internal/repository/user"
+)
+
+// fakeRepository keeps users and linked provider accounts in memory.
+// Methods the tests do not use panic through the nil embedded interface.
+type fakeRepository struct {
+	repository
+
+	users          []*model.User
+	identities     []*model.UserIdentity
+	profileUpdates int
+}
+
+func (r *fakeRepository) GetUserByIdentity(_ context.Context, issuer, subject string) (*model.User, error) {
+	for _, id := range r.identities {
+		if id.Issuer == issuer && id.Subject == subject {
+			return r.user(id.UserID)
+		}
+	}
+
+	return nil, userrepo.ErrUserNotFound
+}
+
+func (r *fakeRepository) GetUserByEmailFold(_ context.Context, email string) (*model.User, error) {
+	for _, u := range r.users {
+		if strings.EqualFold(u.Email, email) {
+			return u, nil
+		}
+	}
+
+	return nil, userrepo.ErrUserNotFound
+}
+
+func (r *fakeRepository) LinkIdentity(_ context.Context, identity *model.UserIdentity) error {
+	u, err := r.user(identity.UserID)
+	if err != nil {
+		return err
+	}
+
+	if u.VerifiedAt == nil {
+		now := time.Now()
+		u.VerifiedAt = &now
+		u.Password = ""
+	}
+	r.identities = append(r.identities, identity)
+
+	return nil
+}
+
+func (r *fakeRepository) CreateUserWithIdentity(_ context.Context, user *model.User, identity *model.UserIdentity) error {
+	now := time.Now()
+	user.ID = uuid.New()
+	user.VerifiedAt = &now
+	identity.UserID = user.ID
+
+	r.users = append(r.users, user)
+	r.identities = append(r.identities, identity)
+
+	return nil
+}
+
+func (r *fakeRepository) GetUserByEmail(_ context.Context, email string) (*model.User, error) {
+	for _, u := range r.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+
+	return nil, userrepo.ErrUserNotFound
+}
+
+func (r *fakeRepository) GetUserByID(_ context.Context, userID uuid.UUID) (*model.User, error) {
+	return r.user(userID)
+}
+
+func (r *fakeRepository) CreateGuestUser(_ context.Context, user *model.User) error {
+	user.ID = uuid.New()
+	r.users = append(r.users, user)
+
+	return nil
+}
+
+func (r *fakeRepository) MarkEmailVerified(_ context.Context, userID uuid.UUID, email string) error {
+	u, err := r.user(userID)
+	if err != nil || u.Email != email {
+		return userrepo.ErrUserNotFound
+	}
+
+	if u.VerifiedAt == nil {
+		now := time.Now()
+		u.VerifiedAt = &now
+	}
+
+	return nil
+}
+
+func (r *fakeRepository) UpdateProfile(_ context.Context, user *model.User, _ *model.NotificationPreferences) error {
+	r.profileUpdates++
+	return nil
+}
+
+func (r *fakeRepository) user(id uuid.UUID) (*model.User, error) {
+	for _, u := range r.users {
+		if u.ID == id {
+			return u, nil
+		}
+	}
+
+	return nil, userrepo.ErrUserNotFound
+}
+
+// fakeSessions records the users whose tokens were revoked.
+type fakeSessions struct {
+	sessionRepository
+
+	revoked []uuid.UUID
+}
+
+func (s *fakeSessions) RevokeUserTokens(_ context.Context, userID uuid.UUID) error {
+	s.revoked = append(s.revoked, userID)
+	return nil
+}
+
+// fakeMFA records the users whose second factor was deleted.
+type fakeMFA struct {
+	mfaRepository
+
+	deleted []uuid.UUID
+}
+
+func (m *fakeMFA) DeleteMFA(_ context.Context, userID uuid.UUID) error {
+	m.deleted = append(m.deleted, userID)
+	return nil
+}
+
+func TestOIDCUser(t *testing.T) {
+	const issuer = "https://idp.example.com"
+
+	verifiedAt := time.Now().Add(-time.Hour)
+	linked := &model.User{ID: uuid.New(), Email: "linked@corp.com", Name: "Linked", VerifiedAt: &verifiedAt}
+	verified := &model.User{ID: uuid.New(), Email: "john.doe@corp.com", Name: "John", Password: "hash", VerifiedAt: &verifiedAt}
+	unverified := &model.User{ID: uuid.New(), Email: "jane@corp.com", Name: "Jane", Password: "hash"}
+
+	tests := []struct {
+		name        string
+		identity    oidc.Identity
+		wantUser    *model.User // nil if a user is created
+		wantName    string      // name of the created user
+		wantEvicted bool
+		wantErr     error
+		wantUsers   int
+	}{
+		{
+			name:      "already linked",
+			identity:  oidc.Identity{Issuer: issuer, Subject: "linked", Email: "other@corp.com"},
+			wantUser:  linked,
+			wantUsers: 3,
+		},
+		{
+			name:      "linked by verified email ignoring case",
+			identity:  oidc.Identity{Issuer: issuer, Subject: "john", Email: "John.Doe@Corp.com", EmailVerified: true},
+			wantUser:  verified,
+			wantUsers: 3,
+		},
+		{
+			name:        "unverified account claimed",
+			identity:    oidc.Identity{Issuer: issuer, Subject: "jane", Email: "jane@corp.com", EmailVerified: true},
+			wantUser:    unverified,
+			wantEvicted: true,
+			wantUsers:   3,
+		},
+		{
+			name:      "created on first login",
+			identity:  oidc.Identity{Issuer: issuer, Subject: "new", Email: "new@corp.com", EmailVerified: true, Name: " New \n  User "},
+			wantName:  "New User",
+			wantUsers: 4,
+		},
+		{
+			name: "long name cut on creation",
+			identity: oidc.Identity{
+				Issuer: issuer, Subject: "long", Email: "long@corp.com", EmailVerified: true,
+				Name: strings.Repeat("é", maxNameLength+10),
+			},
+			wantName:  strings.Repeat("é", maxNameLength),
+			wantUsers: 4,
+		},
+		{
+			name:      "unverified email not linked",
+			identity:  oidc.Identity{Issuer: issuer, Subject: "john", Email: "john.doe@corp.com"},
+			wantErr:   ErrOIDCEmailNotVerified,
+			wantUsers: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Copies, as linking changes the users.
+			l, v, u := *linked, *verified, *unverified
+			repo := &fakeRepository{
+				users:      []*model.User{&l, &v, &u},
+				identities: []*model.UserIdentity{{UserID: linked.ID, Issuer: issuer, Subject: "linked"}},
+			}
+			sessions := &fakeSessions{}
+			mfa := &fakeMFA{}
+			s := NewService(repo, sessions, mfa, nil, nil, nil, nil, nil, nil)
+
+			got, err := s.oidcUser(context.Background(), &tt.identity)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if len(repo.users) != tt.wantUsers {
+				t.Errorf("users = %d, want %d", len(repo.users), tt.wantUsers)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if tt.wantUser != nil && got.ID != tt.wantUser.ID {
+				t.Errorf("user = %s, want %s", got.Email, tt.wantUser.Email)
+			}
+			if tt.wantUser == nil && got.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", got.Name, tt.wantName)
+			}
+			if got.VerifiedAt == nil {
+				t.Error("user not verified")
+			}
+
+			linkedTo, err := repo.GetUserByIdentity(context.Background(), tt.identity.Issuer, tt.identity.Subject)
+			if err != nil || linkedTo.ID != got.ID {
+				t.Errorf("identity not linked to the user")
+			}
+
+			evicted := len(sessions.revoked) == 1 && len(mfa.deleted) == 1
+			if evicted != tt.wantEvicted {
+				t.Errorf("evicted = %v, want %v", evicted, tt.wantEvicted)
+			}
+			if tt.wantEvicted && got.Password != "" {
+				t.Error("password of the claimed account kept")
+			}
+		})
+	}
+}
+
+func TestGuestUser(t *testing.T) {
+	repo := &fakeRepository{}
+	s := NewService(repo, &fakeSessions{}, &fakeMFA{}, nil, nil, nil, nil, nil, nil)
+	event := &model.Event{ID: uuid.New(), Title: "Go Meetup"}
+
+	created, err := s.GuestUser(context.Background(), "guest@example.com", " Jane\n Doe ", event)
+	if err != nil {
+		t.Fatalf("GuestUser() error = %v", err)
+	}
+	if created.VerifiedAt != nil || created.Password != "" {
+		t.Error("guest user created verified or with a password")
+	}
+	if created.Name != "Jane Doe" {
+		t.Errorf("name = %q, want %q", created.Name, "Jane Doe")
+	}
+
+	// A later guest booking with the same address reuses the user.
+	reused, err := s.GuestUser(context.Background(), "guest@example.com", "", event)
+	if err != nil {
+		t.Fatalf("GuestUser() error = %v", err)
+	}
+	if reused.ID != created.ID || len(repo.users) != 1 {
+		t.Error("guest user not reused")
+	}
+}
+
+func TestClaimGuestBooking(t *testing.T) {
+	const secret = "test-secret"
+
+	guest := &model.User{ID: uuid.New(), Email: "guest@example.com"}
+	eventID, bookingID := uuid.New(), uuid.New()
+	expiresAt := time.Now().Add(15 * time.Minute)
+
+	sign := func(eventID uuid.UUID, email string, expiresAt time.Time) string {
+		fields := []string{eventID.String(), bookingID.String(), guest.ID.String(), email}
+		return signLinkToken(guestBookingPrefix, fields, expiresAt, secret)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "valid", token: sign(eventID, guest.Email, expiresAt)},
+		{name: "other event", token: sign(uuid.New(), guest.Email, expiresAt), wantErr: ErrInvalidGuestBooking},
+		{name: "hold expired", token: sign(eventID, guest.Email, time.Now().Add(-time.Minute)), wantErr: ErrInvalidGuestBooking},
+		{name: "email changed", token: sign(eventID, "old@example.com", expiresAt), wantErr: ErrInvalidGuestBooking},
+		{name: "malformed", token: "not-a-token", wantErr: ErrInvalidGuestBooking},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := *guest
+			repo := &fakeRepository{users: []*model.User{&g}}
+			cfg := &config.Config{Auth: config.Auth{LinkSecret: secret}}
+			s := NewService(repo, &fakeSessions{}, &fakeMFA{}, nil, nil, nil, nil, nil, cfg)
+
+			user, gotBookingID, err := s.ClaimGuestBooking(context.Background(), tt.token, eventID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if g.VerifiedAt != nil {
+					t.Error("email verified by an unusable link")
+				}
+				return
+			}
+
+			if user.ID != guest.ID || gotBookingID != bookingID {
+				t.Errorf("got user %s and booking %s, want %s and %s", user.ID, gotBookingID, guest.ID, bookingID)
+			}
+			if user.VerifiedAt == nil {
+				t.Error("email not verified")
+			}
+		})
+	}
+}
+
+func TestUpdateProfileBlankName(t *testing.T) {
+	s := NewService(&fakeRepository{}, &fakeSessions{}, &fakeMFA{}, nil, nil, nil, nil, nil, nil)
+
+	for _, name := range []string{"", "   ", "\n\t"} {
+		_, err := s.UpdateProfile(context.Background(), uuid.New(), &model.ProfileUpdate{Name: &name})
+		if !errors.Is(err, ErrInvalidName) {
+			t.Errorf("UpdateProfile(%q) error = %v, want %v", name, err, ErrInvalidName)
+		}
+	}
+}
+
+func TestUpdateProfileInvalidPreferences(t *testing.T) {
+	user := &model.User{ID: uuid.New(), Email: "jane@example.com", Name: "Jane"}
+	repo := &fakeRepository{users: []*model.User{user}}
+	s := NewService(repo, &fakeSessions{}, &fakeMFA{}, nil, nil, nil, nil, nil, nil)
+
+	name := "Jane Doe"
+	_, err := s.UpdateProfile(context.Background(), user.ID, &model.ProfileUpdate{
+		Name:                    &name,
+		NotificationPreferences: &model.NotificationPreferences{Channels: []string{notification.ChannelWebhook}},
+	})
+	if !errors.Is(err, ErrWebhookURLRequired) {
+		t.Fatalf("error = %v, want %v", err, ErrWebhookURLRequired)
+	}
+	if repo.profileUpdates != 0 {
+		t.Error("profile saved despite invalid notification preferences")
+	}
+}
+
+func TestWaitForEmails(t *testing.T) {
+	s := NewService(&fakeRepository{}, &fakeSessions{}, &fakeMFA{}, nil, nil, nil, nil, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	release := make(chan struct{})
+	s.background(ctx, func(ctx context.Context) {
+		<-release
+		if ctx.Err() != nil {
+			t.Error("email context cancelled with the request")
+		}
+	})
+	cancel() // the request is answered
+
+	short, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+	if err := s.Wait(short); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() with an email being sent = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	close(release)
+	if err := s.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() = %v", err)
+	}
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS locale TEXT NOT NULL DEFAULT 'en',
+    ADD COLUMN IF NOT EXISTS role   TEXT NOT NULL DEFAULT 'user' CHECK ( role IN ('user', 'organizer', 'admin') );
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN IF EXISTS role,
+    DROP COLUMN IF EXISTS locale;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS outbox
+(
+    id              UUID PRIMARY KEY                                       DEFAULT gen_random_uuid(),
+    user_id         UUID        NOT NULL REFERENCES users (id),
+    recipient       TEXT        NOT NULL,
+    template        TEXT        NOT NULL,
+    locale          TEXT        NOT NULL                                   DEFAULT 'en',
+    payload         JSONB       NOT NULL                                   DEFAULT '{}',
+    status          TEXT CHECK ( status IN ('pending', 'sent', 'dead') ) DEFAULT 'pending',
+    attempts        INT         NOT NULL                                   DEFAULT 0,
+    last_error      TEXT,
+    next_attempt_at TIMESTAMPTZ NOT NULL                                   DEFAULT NOW(),
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ                                            DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ                                            DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS outbox_pending_next_attempt_at_idx ON outbox (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS outbox_dead_idx ON outbox (updated_at) WHERE status = 'dead';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS outbox;
+-- +goose StatementEnd
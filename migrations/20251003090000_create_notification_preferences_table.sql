@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS notification_preferences
+(
+    user_id               UUID PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    channels              TEXT[]  NOT NULL DEFAULT '{email}',
+    webhook_url           TEXT    NOT NULL DEFAULT '',
+    telegram_chat_id      TEXT    NOT NULL DEFAULT '',
+    opt_out_non_essential BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at            TIMESTAMPTZ      DEFAULT NOW()
+);
+
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS delivered_channels TEXT[] NOT NULL DEFAULT '{}';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS delivered_channels;
+
+DROP TABLE IF EXISTS notification_preferences;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN IF NOT EXISTS timezone TEXT NOT NULL DEFAULT 'UTC';
+
+CREATE TABLE IF NOT EXISTS booking_reminders
+(
+    booking_id     UUID   NOT NULL REFERENCES bookings (id) ON DELETE CASCADE,
+    offset_seconds BIGINT NOT NULL,
+    sent_at        TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (booking_id, offset_seconds)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS booking_reminders;
+
+ALTER TABLE users
+    DROP COLUMN IF EXISTS timezone;
+-- +goose StatementEnd
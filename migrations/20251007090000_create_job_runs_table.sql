@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS job_runs
+(
+    id              UUID PRIMARY KEY                                                   DEFAULT gen_random_uuid(),
+    job_name        TEXT        NOT NULL,
+    instance        TEXT        NOT NULL,
+    trigger         TEXT CHECK ( trigger IN ('schedule', 'manual') )                   DEFAULT 'schedule',
+    status          TEXT CHECK ( status IN ('running', 'succeeded', 'failed') )         DEFAULT 'running',
+    items_processed INT         NOT NULL                                               DEFAULT 0,
+    error           TEXT,
+    started_at      TIMESTAMPTZ NOT NULL                                               DEFAULT NOW(),
+    finished_at     TIMESTAMPTZ,
+    duration_ms     BIGINT
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_job_name_started_at_idx ON job_runs (job_name, started_at DESC);
+
+CREATE TABLE IF NOT EXISTS job_states
+(
+    job_name   TEXT PRIMARY KEY,
+    paused     BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at TIMESTAMPTZ      DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS job_states;
+DROP TABLE IF EXISTS job_runs;
+-- +goose StatementEnd
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS seat_drifts
+(
+    id              UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    event_id        UUID        NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    total_seats     INT         NOT NULL,
+    available_seats INT         NOT NULL,
+    active_bookings INT         NOT NULL,
+    corrected       BOOLEAN     NOT NULL DEFAULT FALSE,
+    detected_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS seat_drifts_detected_at_idx ON seat_drifts (detected_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS seat_drifts;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE events
+    ADD COLUMN IF NOT EXISTS seat_strategy TEXT NOT NULL DEFAULT 'counter' CHECK ( seat_strategy IN ('counter', 'slots') );
+
+CREATE TABLE IF NOT EXISTS seat_slots
+(
+    event_id   UUID NOT NULL REFERENCES events (id) ON DELETE CASCADE,
+    slot_no    INT  NOT NULL,
+    booking_id UUID REFERENCES bookings (id) ON DELETE SET NULL,
+    PRIMARY KEY (event_id, slot_no)
+);
+
+CREATE INDEX IF NOT EXISTS seat_slots_free_idx ON seat_slots (event_id) WHERE booking_id IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS seat_slots_booking_id_idx ON seat_slots (booking_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS seat_slots;
+
+ALTER TABLE events
+    DROP COLUMN IF EXISTS seat_strategy;
+-- +goose StatementEnd
@@ -0,0 +1,43 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS archived_events
+(
+    id              UUID PRIMARY KEY,
+    title           TEXT        NOT NULL,
+    date            TIMESTAMPTZ NOT NULL,
+    total_seats     INT         NOT NULL,
+    available_seats INT         NOT NULL,
+    booking_ttl     BIGINT      NOT NULL,
+    seat_strategy   TEXT        NOT NULL,
+    created_at      TIMESTAMPTZ,
+    updated_at      TIMESTAMPTZ,
+    archived_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS archived_bookings
+(
+    id          UUID PRIMARY KEY,
+    event_id    UUID,
+    user_id     UUID,
+    status      TEXT,
+    expires_at  TIMESTAMPTZ NOT NULL,
+    warned_at   TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS archived_bookings_event_id_idx ON archived_bookings (event_id);
+CREATE INDEX IF NOT EXISTS archived_bookings_user_id_idx ON archived_bookings (user_id);
+
+CREATE INDEX IF NOT EXISTS events_date_idx ON events (date);
+CREATE INDEX IF NOT EXISTS bookings_cancelled_updated_at_idx ON bookings (updated_at) WHERE status = 'cancelled';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS bookings_cancelled_updated_at_idx;
+DROP INDEX IF EXISTS events_date_idx;
+DROP TABLE IF EXISTS archived_bookings;
+DROP TABLE IF EXISTS archived_events;
+-- +goose StatementEnd
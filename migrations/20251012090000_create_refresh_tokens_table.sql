@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS refresh_tokens
+(
+    id                UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    user_id           UUID        NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    family_id         UUID        NOT NULL,
+    token_hash        TEXT        NOT NULL UNIQUE,
+    access_jti        UUID        NOT NULL,
+    access_expires_at TIMESTAMPTZ NOT NULL,
+    expires_at        TIMESTAMPTZ NOT NULL,
+    revoked_at        TIMESTAMPTZ,
+    replaced_by       UUID,
+    created_at        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_family_id_idx ON refresh_tokens (family_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+CREATE INDEX IF NOT EXISTS refresh_tokens_access_jti_idx ON refresh_tokens (access_jti);
+
+CREATE TABLE IF NOT EXISTS revoked_tokens
+(
+    jti        UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS revoked_tokens;
+DROP TABLE IF EXISTS refresh_tokens;
+-- +goose StatementEnd
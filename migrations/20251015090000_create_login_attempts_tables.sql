@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS login_counters
+(
+    key             TEXT PRIMARY KEY, -- "account:<email>" or "ip:<address>"
+    attempts        INT         NOT NULL,
+    last_attempt_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS login_failures
+(
+    id         UUID PRIMARY KEY     DEFAULT gen_random_uuid(),
+    email      TEXT        NOT NULL,
+    user_id    UUID        REFERENCES users (id) ON DELETE SET NULL,
+    ip         TEXT        NOT NULL,
+    reason     TEXT        NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS login_failures_created_at_idx ON login_failures (created_at);
+CREATE INDEX IF NOT EXISTS login_failures_email_idx ON login_failures (email);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS login_failures;
+DROP TABLE IF EXISTS login_counters;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The user who created the event. Events created before owners were recorded
+-- have none and are managed by admins only.
+ALTER TABLE events ADD COLUMN IF NOT EXISTS organizer_id UUID REFERENCES users (id) ON DELETE SET NULL;
+ALTER TABLE archived_events ADD COLUMN IF NOT EXISTS organizer_id UUID;
+
+CREATE INDEX IF NOT EXISTS events_organizer_id_idx ON events (organizer_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS events_organizer_id_idx;
+ALTER TABLE archived_events DROP COLUMN IF EXISTS organizer_id;
+ALTER TABLE events DROP COLUMN IF EXISTS organizer_id;
+-- +goose StatementEnd
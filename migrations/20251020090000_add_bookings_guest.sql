@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Bookings made through guest checkout, capped per event while pending.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS guest BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE archived_bookings ADD COLUMN IF NOT EXISTS guest BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS bookings_pending_guest_idx ON bookings (event_id) WHERE guest AND status = 'pending';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS bookings_pending_guest_idx;
+ALTER TABLE archived_bookings DROP COLUMN IF EXISTS guest;
+ALTER TABLE bookings DROP COLUMN IF EXISTS guest;
+-- +goose StatementEnd
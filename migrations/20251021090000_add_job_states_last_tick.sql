@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The latest scheduled tick a job ran for, claimed by the instance that runs it.
+ALTER TABLE job_states ADD COLUMN IF NOT EXISTS last_tick TIMESTAMPTZ;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE job_states DROP COLUMN IF EXISTS last_tick;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A new email address requested by the user, set as the email once confirmed.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
+-- +goose StatementEnd
@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Seat drifts of archived events, moved with them so the audit trail survives.
+CREATE TABLE IF NOT EXISTS archived_seat_drifts
+(
+    id              UUID PRIMARY KEY,
+    event_id        UUID,
+    total_seats     INT         NOT NULL,
+    available_seats INT         NOT NULL,
+    active_bookings INT         NOT NULL,
+    corrected       BOOLEAN     NOT NULL,
+    detected_at     TIMESTAMPTZ NOT NULL,
+    archived_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS archived_seat_drifts_event_id_idx ON archived_seat_drifts (event_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS archived_seat_drifts;
+-- +goose StatementEnd
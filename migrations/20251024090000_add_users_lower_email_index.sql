@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Case-insensitive email lookups when linking OpenID Connect accounts.
+CREATE INDEX IF NOT EXISTS users_lower_email_idx ON users (lower(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS users_lower_email_idx;
+-- +goose StatementEnd
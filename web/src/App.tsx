@@ -6,8 +6,14 @@ import { AuthProvider } from "./context/AuthContext";
 import CreateEvent from "./pages/CreateEvent";
 import EventDetail from "./pages/EventDetail";
 import EventList from "./pages/EventList";
+import ForgotPassword from "./pages/ForgotPassword";
+import GuestBooking from "./pages/GuestBooking";
 import Login from "./pages/Login";
+import MagicLink from "./pages/MagicLink";
+import OIDCCallback from "./pages/OIDCCallback";
 import Register from "./pages/Register";
+import ResetPassword from "./pages/ResetPassword";
+import VerifyEmail from "./pages/VerifyEmail";
 
 const App: React.FC = () => {
   return (
@@ -19,7 +25,13 @@ const App: React.FC = () => {
             <Routes>
               <Route path="/" element={<EventList />} />
               <Route path="/login" element={<Login />} />
+              <Route path="/oidc/callback" element={<OIDCCallback />} />
+              <Route path="/magic-link" element={<MagicLink />} />
+              <Route path="/guest-booking" element={<GuestBooking />} />
               <Route path="/register" element={<Register />} />
+              <Route path="/forgot-password" element={<ForgotPassword />} />
+              <Route path="/reset-password" element={<ResetPassword />} />
+              <Route path="/verify-email" element={<VerifyEmail />} />
               <Route path="/create-event" element={<CreateEvent />} />
               <Route path="/events/:eventID" element={<EventDetail />} />
             </Routes>
@@ -6,6 +6,8 @@ const API_BASE_URL =
 
 const api = axios.create({
   baseURL: API_BASE_URL,
+  // Send the read-after-write cookie, so reads right after a booking see it.
+  withCredentials: true,
 });
 
 // Interceptor to add JWT token to requests
@@ -17,6 +19,55 @@ api.interceptors.request.use((config) => {
   return config;
 });
 
+// On 401, exchange the refresh token for a new pair once and retry the request.
+// Concurrent failures share one refresh, since a refresh token can be used only once.
+let refreshing: Promise<string | null> | null = null;
+
+const refreshTokens = async (): Promise<string | null> => {
+  const refreshToken = localStorage.getItem("refresh_token");
+  if (!refreshToken) {
+    return null;
+  }
+  try {
+    const response = await axios.post<AuthResponse>(
+      `${API_BASE_URL}/auth/refresh`,
+      { refresh_token: refreshToken },
+      { withCredentials: true }
+    );
+    localStorage.setItem("token", response.data.result.token);
+    localStorage.setItem("refresh_token", response.data.result.refresh_token);
+    return response.data.result.token;
+  } catch {
+    localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
+    return null;
+  }
+};
+
+api.interceptors.response.use(
+  (response) => response,
+  async (error) => {
+    const original = error.config;
+    if (
+      error.response?.status !== 401 ||
+      !original ||
+      original._retried ||
+      original.url?.startsWith("/auth/")
+    ) {
+      return Promise.reject(error);
+    }
+    original._retried = true;
+
+    refreshing = refreshing ?? refreshTokens().finally(() => (refreshing = null));
+    const token = await refreshing;
+    if (!token) {
+      return Promise.reject(error);
+    }
+    original.headers.Authorization = `Bearer ${token}`;
+    return api(original);
+  }
+);
+
 export interface RegisterRequest {
   email: string;
   password: string;
@@ -34,8 +85,13 @@ export interface CreateEventRequest {
   total_seats: number;
   available_seats: number;
   booking_ttl: string; // e.g., "10m"
+  seat_strategy?: SeatStrategy;
 }
 
+// "counter" decrements a per-event counter; "slots" claims pre-created seat rows
+// and scales better for events with many concurrent bookings.
+export type SeatStrategy = "counter" | "slots";
+
 export interface Event {
   id: string; // uuid as string
   title: string;
@@ -43,6 +99,7 @@ export interface Event {
   total_seats: number;
   available_seats: number;
   booking_ttl: string;
+  seat_strategy: SeatStrategy;
   created_at: string;
   updated_at: string;
 }
@@ -61,6 +118,20 @@ export interface Booking {
 export interface AuthResponse {
   result: {
     token: string;
+    refresh_token: string;
+    expires_in: number; // access token lifetime in seconds
+  };
+}
+
+// Users with two-factor authentication get a challenge instead of tokens.
+export interface LoginResponse {
+  result: {
+    token?: string;
+    refresh_token?: string;
+    expires_in?: number;
+    mfa_required?: boolean;
+    challenge_token?: string;
+    challenge_expires_in?: number;
   };
 }
 
@@ -71,6 +142,16 @@ export interface BookResponse {
   };
 }
 
+// A booking confirmed with a guest booking link, with the guest's session.
+export interface GuestBookingResponse {
+  result: {
+    booking_id: string;
+    token: string;
+    refresh_token: string;
+    expires_in: number;
+  };
+}
+
 export interface ActionResponse {
   result: {
     message: string;
@@ -103,11 +184,86 @@ export const register = async (
   return response.data;
 };
 
-export const login = async (data: LoginRequest): Promise<AuthResponse> => {
+export const login = async (data: LoginRequest): Promise<LoginResponse> => {
   const response = await api.post("/auth/login", data);
   return response.data;
 };
 
+// Completes a challenged login with a TOTP code or a recovery code.
+export const completeMFALogin = async (
+  challengeToken: string,
+  code: string
+): Promise<AuthResponse> => {
+  const response = await api.post("/auth/login/2fa", {
+    challenge_token: challengeToken,
+    code,
+  });
+  return response.data;
+};
+
+// Single sign-on: send the browser to the returned URL, then post back
+// the code and state the provider adds to the redirect URL.
+export const startOIDCLogin = async (): Promise<string> => {
+  const response = await api.get("/auth/oidc/authorize");
+  return response.data.result.authorization_url;
+};
+
+export const completeOIDCLogin = async (
+  state: string,
+  code: string
+): Promise<LoginResponse> => {
+  const response = await api.post("/auth/oidc/callback", { state, code });
+  return response.data;
+};
+
+// Always succeeds for a valid email, whether or not it is registered.
+export const requestMagicLink = async (
+  email: string
+): Promise<ActionResponse> => {
+  const response = await api.post("/auth/magic-link", { email });
+  return response.data;
+};
+
+// Logs in with the token of an emailed login link.
+export const loginWithMagicLink = async (
+  token: string
+): Promise<LoginResponse> => {
+  const response = await api.post("/auth/magic-link/verify", { token });
+  return response.data;
+};
+
+// Always succeeds for a valid email, whether or not it is registered.
+export const forgotPassword = async (email: string): Promise<ActionResponse> => {
+  const response = await api.post("/auth/password/forgot", { email });
+  return response.data;
+};
+
+export const resetPassword = async (
+  token: string,
+  password: string
+): Promise<ActionResponse> => {
+  const response = await api.post("/auth/password/reset", { token, password });
+  return response.data;
+};
+
+export const verifyEmail = async (token: string): Promise<ActionResponse> => {
+  const response = await api.post("/auth/verify-email", { token });
+  return response.data;
+};
+
+export const resendVerificationEmail = async (): Promise<ActionResponse> => {
+  const response = await api.post("/auth/verify-email/resend");
+  return response.data;
+};
+
+// Revokes the current session; pass all to sign out everywhere.
+export const logout = async (all = false): Promise<ActionResponse> => {
+  const response = await api.post("/auth/logout", null, {
+    params: all ? { all: true } : undefined,
+  });
+  return response.data;
+};
+
 // Events
 export const getEvents = async (): Promise<Event[]> => {
   const response = await api.get<EventsResponse>("/events");
@@ -131,6 +287,31 @@ export const bookEvent = async (eventID: string): Promise<BookResponse> => {
   return response.data;
 };
 
+// Books without logging in: a seat is held and the link to confirm it is emailed to the guest.
+export const bookEventAsGuest = async (
+  eventID: string,
+  email: string,
+  name?: string
+): Promise<ActionResponse> => {
+  const response = await api.post<ActionResponse>(`/events/${eventID}/book`, {
+    email,
+    name,
+  });
+  return response.data;
+};
+
+// Confirms a guest's booking with the token of an emailed guest booking link and logs the guest in.
+export const confirmGuestBooking = async (
+  eventID: string,
+  token: string
+): Promise<GuestBookingResponse> => {
+  const response = await api.post<GuestBookingResponse>(
+    `/events/${eventID}/book/confirm`,
+    { token }
+  );
+  return response.data;
+};
+
 export const confirmBooking = async (
   eventID: string,
   bookingID: string
@@ -1,11 +1,12 @@
 // src/context/AuthContext.tsx
 import type { ReactNode } from "react";
 import React, { createContext, useEffect, useState } from "react";
+import { logout as revokeSession } from "../api/api";
 
 interface AuthContextType {
   isAuthenticated: boolean;
-  login: (token: string) => void;
-  logout: () => void;
+  login: (token: string, refreshToken?: string) => void;
+  logout: () => Promise<void>;
 }
 
 export const AuthContext = createContext<AuthContextType | undefined>(
@@ -24,13 +25,19 @@ export const AuthProvider: React.FC<{ children: ReactNode }> = ({
     }
   }, []);
 
-  const login = (token: string) => {
+  const login = (token: string, refreshToken?: string) => {
     localStorage.setItem("token", token);
+    if (refreshToken) {
+      localStorage.setItem("refresh_token", refreshToken);
+    }
     setIsAuthenticated(true);
   };
 
-  const logout = () => {
+  const logout = async () => {
+    // Revoke the session server-side; local tokens are dropped either way.
+    await revokeSession().catch(() => undefined);
     localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
     setIsAuthenticated(false);
   };
 
@@ -1,6 +1,7 @@
 // src/pages/CreateEvent.tsx
 import React, { useContext, useState } from "react";
 import { useNavigate } from "react-router-dom";
+import type { SeatStrategy } from "../api/api";
 import { createEvent } from "../api/api";
 import { AuthContext } from "../context/AuthContext";
 
@@ -8,8 +9,8 @@ const CreateEvent: React.FC = () => {
   const [title, setTitle] = useState("");
   const [date, setDate] = useState("");
   const [totalSeats, setTotalSeats] = useState(0);
-  const [availableSeats, setAvailableSeats] = useState(0);
   const [bookingTTL, setBookingTTL] = useState("10m");
+  const [seatStrategy, setSeatStrategy] = useState<SeatStrategy>("counter");
   const [error, setError] = useState("");
   const navigate = useNavigate();
   const authContext = useContext(AuthContext);
@@ -33,8 +34,9 @@ const CreateEvent: React.FC = () => {
         title,
         date: formattedDate,
         total_seats: totalSeats,
-        available_seats: availableSeats,
+        available_seats: totalSeats, // a new event has all seats available
         booking_ttl: bookingTTL,
+        seat_strategy: seatStrategy,
       });
       navigate(`/events/${event.id}`);
     } catch (err: any) {
@@ -67,13 +69,6 @@ const CreateEvent: React.FC = () => {
           onChange={(e) => setTotalSeats(parseInt(e.target.value) || 0)}
           className="w-full mb-4 p-2 border rounded"
         />
-        <input
-          type="number"
-          placeholder="Available Seats"
-          value={availableSeats}
-          onChange={(e) => setAvailableSeats(parseInt(e.target.value) || 0)}
-          className="w-full mb-4 p-2 border rounded"
-        />
         <input
           type="text"
           placeholder="Booking TTL (e.g., 10m)"
@@ -81,6 +76,14 @@ const CreateEvent: React.FC = () => {
           onChange={(e) => setBookingTTL(e.target.value)}
           className="w-full mb-4 p-2 border rounded"
         />
+        <select
+          value={seatStrategy}
+          onChange={(e) => setSeatStrategy(e.target.value as SeatStrategy)}
+          className="w-full mb-4 p-2 border rounded"
+        >
+          <option value="counter">Seat counter</option>
+          <option value="slots">Seat slots (high-demand events)</option>
+        </select>
         <button
           type="submit"
           className="w-full bg-blue-500 text-white p-2 rounded"
@@ -1,8 +1,14 @@
 // src/pages/EventDetail.tsx
 import React, { useContext, useEffect, useState } from "react";
-import { useNavigate, useParams } from "react-router-dom";
+import { useNavigate, useParams, useSearchParams } from "react-router-dom";
 import type { Booking, Event } from "../api/api";
-import { bookEvent, cancelBooking, confirmBooking, getEvent } from "../api/api";
+import {
+  bookEvent,
+  bookEventAsGuest,
+  cancelBooking,
+  confirmBooking,
+  getEvent,
+} from "../api/api";
 import { AuthContext } from "../context/AuthContext";
 
 const EventDetail: React.FC = () => {
@@ -11,8 +17,27 @@ const EventDetail: React.FC = () => {
   const [booking, setBooking] = useState<Booking | null>(null);
   const [error, setError] = useState("");
   const [message, setMessage] = useState("");
+  const [guestEmail, setGuestEmail] = useState("");
+  const [guestName, setGuestName] = useState("");
+  const [guestBooked, setGuestBooked] = useState(false);
   const authContext = useContext(AuthContext);
   const navigate = useNavigate();
+  const [searchParams] = useSearchParams();
+
+  // Restore a pending booking from the link in the hold expiry email.
+  useEffect(() => {
+    const bookingID = searchParams.get("booking");
+    if (!eventID || !bookingID) return;
+    setBooking({
+      id: bookingID,
+      event_id: eventID,
+      user_id: "",
+      status: "pending",
+      expires_at: "",
+      created_at: "",
+      updated_at: "",
+    });
+  }, [eventID, searchParams]);
 
   useEffect(() => {
     const fetchEvent = async () => {
@@ -57,6 +82,20 @@ const EventDetail: React.FC = () => {
     }
   };
 
+  // Guests confirm the seat held for them with the link emailed to them, which logs them in.
+  const handleGuestBook = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!eventID) return;
+    try {
+      const response = await bookEventAsGuest(eventID, guestEmail, guestName);
+      setError("");
+      setMessage(response.result.message);
+      setGuestBooked(true);
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Failed to book");
+    }
+  };
+
   const handleConfirm = async () => {
     if (!eventID || !booking?.id) return;
     try {
@@ -108,10 +147,40 @@ const EventDetail: React.FC = () => {
             Book Seat
           </button>
         )}
+      {!booking &&
+        !guestBooked &&
+        !authContext?.isAuthenticated &&
+        event.available_seats > 0 && (
+          <form onSubmit={handleGuestBook} className="mt-4">
+            <p className="mb-2">Book without an account:</p>
+            <input
+              type="email"
+              placeholder="Email"
+              value={guestEmail}
+              onChange={(e) => setGuestEmail(e.target.value)}
+              className="w-full mb-2 p-2 border rounded"
+            />
+            <input
+              type="text"
+              placeholder="Name (optional)"
+              value={guestName}
+              onChange={(e) => setGuestName(e.target.value)}
+              className="w-full mb-2 p-2 border rounded"
+            />
+            <button
+              type="submit"
+              className="bg-green-500 text-white p-2 rounded"
+            >
+              Book as Guest
+            </button>
+          </form>
+        )}
       {booking && (
         <div className="mt-4">
           <p>Your Booking ID: {booking.id}</p>
-          <p>Expires At: {new Date(booking.expires_at).toLocaleString()}</p>
+          {booking.expires_at && (
+            <p>Expires At: {new Date(booking.expires_at).toLocaleString()}</p>
+          )}
           <button
             onClick={handleConfirm}
             className="bg-blue-500 text-white p-2 rounded mr-2"
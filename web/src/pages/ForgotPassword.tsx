@@ -0,0 +1,48 @@
+// src/pages/ForgotPassword.tsx
+import React, { useState } from "react";
+import { forgotPassword } from "../api/api";
+
+const ForgotPassword: React.FC = () => {
+  const [email, setEmail] = useState("");
+  const [message, setMessage] = useState("");
+  const [error, setError] = useState("");
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setError("");
+    try {
+      const response = await forgotPassword(email);
+      setMessage(response.result.message);
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Request failed");
+    }
+  };
+
+  return (
+    <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+      <h2 className="text-2xl mb-4">Forgot Password</h2>
+      {error && <p className="text-red-500">{error}</p>}
+      {message ? (
+        <p className="text-green-600">{message}</p>
+      ) : (
+        <form onSubmit={handleSubmit}>
+          <input
+            type="email"
+            placeholder="Email"
+            value={email}
+            onChange={(e) => setEmail(e.target.value)}
+            className="w-full mb-4 p-2 border rounded"
+          />
+          <button
+            type="submit"
+            className="w-full bg-blue-500 text-white p-2 rounded"
+          >
+            Send reset link
+          </button>
+        </form>
+      )}
+    </div>
+  );
+};
+
+export default ForgotPassword;
@@ -0,0 +1,49 @@
+// src/pages/GuestBooking.tsx
+import React, { useContext, useEffect, useRef, useState } from "react";
+import { Link, useNavigate, useSearchParams } from "react-router-dom";
+import { confirmGuestBooking } from "../api/api";
+import { AuthContext } from "../context/AuthContext";
+
+const GuestBooking: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [error, setError] = useState("");
+  const navigate = useNavigate();
+  const authContext = useContext(AuthContext);
+  const started = useRef(false); // the link can be used only once
+
+  const eventID = searchParams.get("event") || "";
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    confirmGuestBooking(eventID, searchParams.get("token") || "")
+      .then((response) => {
+        if (authContext) {
+          authContext.login(response.result.token, response.result.refresh_token);
+        }
+        navigate(`/events/${eventID}?booking=${response.result.booking_id}`);
+      })
+      .catch((err) =>
+        setError(err.response?.data?.error || "This booking link is invalid or has expired")
+      );
+  }, [eventID, searchParams, navigate, authContext]);
+
+  return (
+    <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+      <h2 className="text-2xl mb-4">Guest Booking</h2>
+      {error ? (
+        <>
+          <p className="text-red-500">{error}</p>
+          <Link to={eventID ? `/events/${eventID}` : "/"} className="block mt-4 text-blue-500">
+            Back to the event
+          </Link>
+        </>
+      ) : (
+        <p>Confirming your booking...</p>
+      )}
+    </div>
+  );
+};
+
+export default GuestBooking;
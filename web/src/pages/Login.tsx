@@ -1,13 +1,25 @@
 // src/pages/Login.tsx
 import React, { useContext, useState } from "react";
-import { useNavigate } from "react-router-dom";
-import { login } from "../api/api";
+import { Link, useLocation, useNavigate } from "react-router-dom";
+import {
+  completeMFALogin,
+  login,
+  requestMagicLink,
+  startOIDCLogin,
+} from "../api/api";
 import { AuthContext } from "../context/AuthContext";
 
 const Login: React.FC = () => {
   const [email, setEmail] = useState("");
   const [password, setPassword] = useState("");
+  const location = useLocation();
+  // A single sign-on or login link login may arrive here with a two-factor challenge.
+  const [challengeToken, setChallengeToken] = useState<string>(
+    (location.state as { challengeToken?: string } | null)?.challengeToken || ""
+  );
+  const [code, setCode] = useState("");
   const [error, setError] = useState("");
+  const [message, setMessage] = useState("");
   const navigate = useNavigate();
   const authContext = useContext(AuthContext);
 
@@ -15,8 +27,13 @@ const Login: React.FC = () => {
     e.preventDefault();
     try {
       const response = await login({ email, password });
-      if (authContext) {
-        authContext.login(response.result.token); // Access token from result
+      if (response.result.mfa_required && response.result.challenge_token) {
+        setError("");
+        setChallengeToken(response.result.challenge_token); // Ask for the second factor
+        return;
+      }
+      if (authContext && response.result.token) {
+        authContext.login(response.result.token, response.result.refresh_token); // Tokens from result
       }
       navigate("/");
     } catch (err: any) {
@@ -24,10 +41,72 @@ const Login: React.FC = () => {
     }
   };
 
+  const handleSSO = async () => {
+    try {
+      window.location.href = await startOIDCLogin();
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Single sign-on is unavailable");
+    }
+  };
+
+  const handleMagicLink = async () => {
+    if (!email) {
+      setError("Enter your email to get a login link");
+      return;
+    }
+    try {
+      const response = await requestMagicLink(email);
+      setError("");
+      setMessage(response.result.message);
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Could not send a login link");
+    }
+  };
+
+  const handleCodeSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      const response = await completeMFALogin(challengeToken, code);
+      if (authContext) {
+        authContext.login(response.result.token, response.result.refresh_token);
+      }
+      navigate("/");
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Verification failed");
+    }
+  };
+
+  if (challengeToken) {
+    return (
+      <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+        <h2 className="text-2xl mb-4">Two-factor authentication</h2>
+        {error && <p className="text-red-500">{error}</p>}
+        <form onSubmit={handleCodeSubmit}>
+          <input
+            type="text"
+            inputMode="numeric"
+            autoComplete="one-time-code"
+            placeholder="Code from your app or a recovery code"
+            value={code}
+            onChange={(e) => setCode(e.target.value)}
+            className="w-full mb-4 p-2 border rounded"
+          />
+          <button
+            type="submit"
+            className="w-full bg-blue-500 text-white p-2 rounded"
+          >
+            Verify
+          </button>
+        </form>
+      </div>
+    );
+  }
+
   return (
     <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
       <h2 className="text-2xl mb-4">Login</h2>
       {error && <p className="text-red-500">{error}</p>}
+      {message && <p className="text-green-600">{message}</p>}
       <form onSubmit={handleSubmit}>
         <input
           type="email"
@@ -50,6 +129,23 @@ const Login: React.FC = () => {
           Login
         </button>
       </form>
+      <button
+        type="button"
+        onClick={handleSSO}
+        className="w-full mt-4 border border-blue-500 text-blue-500 p-2 rounded"
+      >
+        Sign in with SSO
+      </button>
+      <button
+        type="button"
+        onClick={handleMagicLink}
+        className="w-full mt-4 border border-blue-500 text-blue-500 p-2 rounded"
+      >
+        Email me a login link
+      </button>
+      <Link to="/forgot-password" className="block mt-4 text-blue-500">
+        Forgot password?
+      </Link>
     </div>
   );
 };
@@ -0,0 +1,57 @@
+// src/pages/MagicLink.tsx
+import React, { useContext, useEffect, useRef, useState } from "react";
+import { Link, useNavigate, useSearchParams } from "react-router-dom";
+import { loginWithMagicLink } from "../api/api";
+import { AuthContext } from "../context/AuthContext";
+
+// Only paths within the app are followed after login.
+const safeNext = (next: string | null): string =>
+  next && next.startsWith("/") && !next.startsWith("//") ? next : "/";
+
+const MagicLink: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [error, setError] = useState("");
+  const navigate = useNavigate();
+  const authContext = useContext(AuthContext);
+  const started = useRef(false); // the link can be used only once
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    loginWithMagicLink(searchParams.get("token") || "")
+      .then((response) => {
+        if (response.result.mfa_required) {
+          navigate("/login", {
+            state: { challengeToken: response.result.challenge_token },
+          });
+          return;
+        }
+        if (authContext && response.result.token) {
+          authContext.login(response.result.token, response.result.refresh_token);
+        }
+        navigate(safeNext(searchParams.get("next")));
+      })
+      .catch((err) =>
+        setError(err.response?.data?.error || "This login link is invalid or has expired")
+      );
+  }, [searchParams, navigate, authContext]);
+
+  return (
+    <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+      <h2 className="text-2xl mb-4">Login Link</h2>
+      {error ? (
+        <>
+          <p className="text-red-500">{error}</p>
+          <Link to="/login" className="block mt-4 text-blue-500">
+            Back to login
+          </Link>
+        </>
+      ) : (
+        <p>Signing you in...</p>
+      )}
+    </div>
+  );
+};
+
+export default MagicLink;
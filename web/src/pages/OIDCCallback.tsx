@@ -0,0 +1,57 @@
+// src/pages/OIDCCallback.tsx
+import React, { useContext, useEffect, useRef, useState } from "react";
+import { Link, useNavigate, useSearchParams } from "react-router-dom";
+import { completeOIDCLogin } from "../api/api";
+import { AuthContext } from "../context/AuthContext";
+
+const OIDCCallback: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [error, setError] = useState("");
+  const navigate = useNavigate();
+  const authContext = useContext(AuthContext);
+  const started = useRef(false); // the state can be used only once
+
+  useEffect(() => {
+    if (started.current) return;
+    started.current = true;
+
+    const providerError = searchParams.get("error");
+    if (providerError) {
+      setError(searchParams.get("error_description") || providerError);
+      return;
+    }
+
+    completeOIDCLogin(searchParams.get("state") || "", searchParams.get("code") || "")
+      .then((response) => {
+        if (response.result.mfa_required) {
+          navigate("/login", {
+            state: { challengeToken: response.result.challenge_token },
+          });
+          return;
+        }
+        if (authContext && response.result.token) {
+          authContext.login(response.result.token, response.result.refresh_token);
+        }
+        navigate("/");
+      })
+      .catch((err) => setError(err.response?.data?.error || "Single sign-on failed"));
+  }, [searchParams, navigate, authContext]);
+
+  return (
+    <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+      <h2 className="text-2xl mb-4">Single Sign-On</h2>
+      {error ? (
+        <>
+          <p className="text-red-500">{error}</p>
+          <Link to="/login" className="block mt-4 text-blue-500">
+            Back to login
+          </Link>
+        </>
+      ) : (
+        <p>Signing you in...</p>
+      )}
+    </div>
+  );
+};
+
+export default OIDCCallback;
@@ -0,0 +1,52 @@
+// src/pages/ResetPassword.tsx
+import React, { useContext, useState } from "react";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import { resetPassword } from "../api/api";
+import { AuthContext } from "../context/AuthContext";
+
+const ResetPassword: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [password, setPassword] = useState("");
+  const [error, setError] = useState("");
+  const navigate = useNavigate();
+  const authContext = useContext(AuthContext);
+  const token = searchParams.get("token") || "";
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    try {
+      await resetPassword(token, password);
+      // All sessions were revoked, so sign in again with the new password.
+      if (authContext?.isAuthenticated) {
+        await authContext.logout();
+      }
+      navigate("/login");
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Password reset failed");
+    }
+  };
+
+  return (
+    <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+      <h2 className="text-2xl mb-4">Reset Password</h2>
+      {error && <p className="text-red-500">{error}</p>}
+      <form onSubmit={handleSubmit}>
+        <input
+          type="password"
+          placeholder="New password"
+          value={password}
+          onChange={(e) => setPassword(e.target.value)}
+          className="w-full mb-4 p-2 border rounded"
+        />
+        <button
+          type="submit"
+          className="w-full bg-blue-500 text-white p-2 rounded"
+        >
+          Reset password
+        </button>
+      </form>
+    </div>
+  );
+};
+
+export default ResetPassword;
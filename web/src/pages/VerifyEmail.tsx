@@ -0,0 +1,49 @@
+// src/pages/VerifyEmail.tsx
+import React, { useContext, useEffect, useState } from "react";
+import { useSearchParams } from "react-router-dom";
+import { resendVerificationEmail, verifyEmail } from "../api/api";
+import { AuthContext } from "../context/AuthContext";
+
+const VerifyEmail: React.FC = () => {
+  const [searchParams] = useSearchParams();
+  const [message, setMessage] = useState("");
+  const [error, setError] = useState("");
+  const authContext = useContext(AuthContext);
+  const token = searchParams.get("token") || "";
+
+  useEffect(() => {
+    verifyEmail(token)
+      .then((response) => setMessage(response.result.message))
+      .catch((err) =>
+        setError(err.response?.data?.error || "Verification failed")
+      );
+  }, [token]);
+
+  const handleResend = async () => {
+    try {
+      const response = await resendVerificationEmail();
+      setError("");
+      setMessage(response.result.message);
+    } catch (err: any) {
+      setError(err.response?.data?.error || "Failed to send a new link");
+    }
+  };
+
+  return (
+    <div className="max-w-md mx-auto bg-white p-8 rounded shadow">
+      <h2 className="text-2xl mb-4">Email Verification</h2>
+      {message && <p className="text-green-600">{message}</p>}
+      {error && <p className="text-red-500">{error}</p>}
+      {error && authContext?.isAuthenticated && (
+        <button
+          onClick={handleResend}
+          className="w-full mt-4 bg-blue-500 text-white p-2 rounded"
+        >
+          Send a new link
+        </button>
+      )}
+    </div>
+  );
+};
+
+export default VerifyEmail;